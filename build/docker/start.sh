@@ -16,9 +16,14 @@ check_crond_up() {
     log INFO "crond is running"
 }
 
-log INFO "starting crond in the background"
-crond -f -p -m off & # add -x pars,proc if we need to debug the container
-check_crond_up
+# The server fires the tasks itself, so crond would run them twice
+if [ "${SCHEDULER_MODE:-crond}" = "in-process" ]; then
+    log INFO "tasks are run by the server, not starting crond"
+else
+    log INFO "starting crond in the background"
+    crond -f -p -m off & # add -x pars,proc if we need to debug the container
+    check_crond_up
+fi
 
 if [ "$#" -gt 0 ]; then
     exec "$@"
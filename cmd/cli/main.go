@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	coco_cli "github.com/captainmango/coco-cron-parser/internal/cli"
 	"github.com/captainmango/coco-cron-parser/internal/config"
@@ -14,9 +16,14 @@ func main() {
 		config.WithDotEnv(),
 	)
 
+	// A newer run of a task with the Replace concurrency policy stops this one
+	// with SIGTERM, so the command gets the chance to wind down
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	cmd := coco_cli.CreateCLI()
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+	if err := cmd.Run(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,23 +1,81 @@
 package coco_cli
 
 import (
+	"context"
+	"io"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/urfave/cli/v3"
 
 	"github.com/captainmango/coco-cron-parser/internal/config"
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources"
 )
 
+type taskRunTracker interface {
+	ExecuteTaskRun(context.Context, uuid.UUID, string, time.Time, crontab.AttemptFn) error
+}
+
+// The tail of what the command logged, kept with its run record
+var runOutput = &crontab.OutputExcerpt{}
+
 func CreateCLI() *cli.Command {
 	config.BootstrapConfig(
 		config.WithDotEnv(),
 	)
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	logger := slog.New(slog.NewJSONHandler(io.MultiWriter(os.Stdout, runOutput), nil))
 	slog.SetDefault(logger)
 
+	res := resources.CreateResources()
+	registry := NewCommandRegistry(res, cliActor())
+
+	commands := registry.All()
+	for _, c := range commands {
+		trackTaskRuns(c, res.TaskResource.As(cliActor()))
+	}
+
 	return &cli.Command{
-		Commands: CommandRegistry.All(),
+		Commands: commands,
+	}
+}
+
+// Cron starts tasks with their ID in the environment. The task resource
+// decides whether the run may go ahead, retries it if it fails, records it in
+// the run history and cleans up after it.
+func trackTaskRuns(c *cli.Command, runTracker taskRunTracker) {
+	for _, sub := range c.Commands {
+		trackTaskRuns(sub, runTracker)
+	}
+
+	if c.Action == nil {
+		return
+	}
+
+	action := c.Action
+	c.Action = func(ctx context.Context, c *cli.Command) error {
+		raw := os.Getenv(crontab.TASK_ID_ENV)
+		if raw == "" {
+			return action(ctx, c)
+		}
+
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			slog.Error("invalid task id in environment", slog.String("id", raw))
+			return action(ctx, c)
+		}
+
+		// Cron fires on the minute, so that is when the run was due
+		scheduledAt := time.Now().Truncate(time.Minute)
+
+		return runTracker.ExecuteTaskRun(ctx, id, crontab.RUN_SOURCE_CLI, scheduledAt, func(ctx context.Context) (string, error) {
+			runOutput.Reset()
+			err := action(ctx, c)
+
+			return runOutput.String(), err
+		})
 	}
 }
@@ -2,11 +2,12 @@ package coco_cli
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/urfave/cli/v3"
-)
 
-var CommandRegistry *RegistryContainer = &RegistryContainer{}
+	"github.com/captainmango/coco-cron-parser/internal/resources"
+)
 
 type CommandFinder interface {
 	Find(string) (*cli.Command, error)
@@ -15,28 +16,89 @@ type CommandFinder interface {
 
 type RegistryContainer struct {
 	Commands []*cli.Command // should be a slice of cli.Commands from urfav/cli
+	builders map[string]func() *cli.Command
 }
 
-func (r *RegistryContainer) Register(cmd *cli.Command) {
-	r.Commands = append(r.Commands, cmd)
-}
+// Builds every command against the given resources, so the CLI and the API
+// server each run them with the resources they already hold. actor is who
+// the commands' changes are recorded as.
+func NewCommandRegistry(res resources.Resources, actor string) *RegistryContainer {
+	r := &RegistryContainer{}
+	tR := res.TaskResource.As(actor)
 
-func (r *RegistryContainer) Find(key string) (*cli.Command, error) {
-	var c *cli.Command
+	r.Register(func() *cli.Command { return createStartGameCommand(tR) })
+	r.Register(func() *cli.Command { return createScheduleCronCommand(tR, r) })
+	r.Register(func() *cli.Command { return createScheduleOnceCommand(tR, r) })
+	r.Register(func() *cli.Command { return createPullMessagesCommand(tR) })
+	r.Register(func() *cli.Command { return createPauseTaskCommand(tR) })
+	r.Register(func() *cli.Command { return createResumeTaskCommand(tR) })
+	r.Register(func() *cli.Command { return createMaintenanceCommand(tR) })
+	r.Register(func() *cli.Command { return createMigrateCrontabCommand(tR) })
+	r.Register(func() *cli.Command { return createRevisionsCommand(tR) })
+	r.Register(func() *cli.Command { return createDriftCommand(tR) })
+	r.Register(func() *cli.Command { return createImportCommand(tR) })
+	r.Register(func() *cli.Command { return createBatchCommand(tR) })
+	r.Register(func() *cli.Command { return createRoomTasksCommand(tR) })
+	r.Register(func() *cli.Command { return createArchiveCommand(tR) })
+	r.Register(func() *cli.Command { return createLogsCommand(res.Logs) })
+	r.Register(func() *cli.Command { return createRunsCommand(tR) })
 
-	for _, cmd := range r.Commands {
-		if cmd.Name == key {
-			c = cmd
-		}
+	return r
+}
+
+func (r *RegistryContainer) Register(build func() *cli.Command) {
+	if r.builders == nil {
+		r.builders = map[string]func() *cli.Command{}
 	}
 
-	if c == nil {
+	cmd := build()
+	r.Commands = append(r.Commands, cmd)
+	r.builders[cmd.Name] = build
+}
+
+// Builds a new copy of the command each time. A cli.Command keeps the
+// arguments it parsed on itself, so runs that overlap need one each.
+func (r *RegistryContainer) Find(key string) (*cli.Command, error) {
+	build, ok := r.builders[key]
+	if !ok {
 		return nil, errors.New("command not found")
 	}
 
-	return c, nil
+	return build(), nil
 }
 
 func (r *RegistryContainer) All() []*cli.Command {
 	return r.Commands
 }
+
+// Names the positional arguments of a scheduled command line such as
+// "cli start-game 123", using the arguments the command declares. Returns
+// nil when the line is not one of our commands.
+func (r *RegistryContainer) ArgsFor(task string) map[string]string {
+	fields := strings.Fields(task)
+	if len(fields) < 2 || fields[0] != "cli" {
+		return nil
+	}
+
+	cmd, err := r.Find(fields[1])
+	if err != nil {
+		return nil
+	}
+
+	args := map[string]string{}
+	for i, value := range fields[2:] {
+		if i >= len(cmd.Arguments) {
+			break
+		}
+
+		if arg, ok := cmd.Arguments[i].(*cli.StringArg); ok {
+			args[arg.Name] = value
+		}
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	return args
+}
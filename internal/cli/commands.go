@@ -4,14 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
 
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
 	"github.com/captainmango/coco-cron-parser/internal/msq"
 	"github.com/captainmango/coco-cron-parser/internal/resources"
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
 )
 
 func createStartGameCommand(tR resources.TaskResource) *cli.Command {
@@ -42,6 +54,64 @@ func createStartGameCommand(tR resources.TaskResource) *cli.Command {
 	}
 }
 
+func createScheduleOnceCommand(tR resources.TaskResource, registry *RegistryContainer) *cli.Command {
+	return &cli.Command{
+		Name:        "schedule-once",
+		Description: "Schedules a task to run once, at an RFC 3339 time or a relative one like \"in 15m\". The task removes itself after it has fired.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name: "when",
+			},
+			&cli.StringArg{
+				Name: "task",
+			},
+		},
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "name"},
+			&cli.StringFlag{Name: "description"},
+			&cli.StringFlag{Name: "owner"},
+			&cli.StringSliceFlag{Name: "tag", Usage: "can be repeated"},
+		}, append(taskEnvFlags(), retryFlags()...)...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			whenString := c.StringArg("when")
+			taskString := c.StringArg("task")
+
+			if whenString == "" || taskString == "" {
+				return cli.Exit("when and task arguments are required", 1)
+			}
+
+			env, err := envFromFlags(c)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			ctbE, err := tR.ScheduleOneShotTask(whenString, taskString,
+				resources.WithMetadata(crontab.Metadata{
+					Name:        c.String("name"),
+					Description: c.String("description"),
+					Owner:       c.String("owner"),
+					Tags:        c.StringSlice("tag"),
+				}),
+				resources.WithArgs(registry.ArgsFor(taskString)),
+				resources.WithEnv(env),
+				resources.WithWorkDir(c.String("work-dir")),
+				resources.WithRetryPolicy(retryPolicyFromFlags(c)),
+			)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			slog.Info("Scheduled one-shot task",
+				slog.String("id", ctbE.ID.String()),
+				slog.Time("run_at", *ctbE.RunAt),
+				slog.String("task", taskString),
+			)
+
+			return nil
+		},
+	}
+}
+
 func createPullMessagesCommand(tR resources.TaskResource) *cli.Command {
 	return &cli.Command{
 		Name:        "pull-messages",
@@ -87,7 +157,7 @@ func createPullMessagesCommand(tR resources.TaskResource) *cli.Command {
 	}
 }
 
-func createScheduleCronCommand(tR resources.TaskResource) *cli.Command {
+func createScheduleCronCommand(tR resources.TaskResource, registry *RegistryContainer) *cli.Command {
 	return &cli.Command{
 		Name:        "schedule-task",
 		Description: "Schedules a task to be run via the scheduler.",
@@ -99,6 +169,17 @@ func createScheduleCronCommand(tR resources.TaskResource) *cli.Command {
 				Name: "task",
 			},
 		},
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "name"},
+			&cli.StringFlag{Name: "description"},
+			&cli.StringFlag{Name: "owner"},
+			&cli.StringSliceFlag{Name: "tag", Usage: "can be repeated"},
+			&cli.StringFlag{Name: "start-at", Usage: "RFC 3339 time before which the task does not fire"},
+			&cli.StringFlag{Name: "end-at", Usage: "RFC 3339 time after which the task is removed"},
+			&cli.IntFlag{Name: "max-runs", Usage: "remove the task after it has fired this many times"},
+			&cli.StringFlag{Name: "concurrency-policy", Usage: "when the last run is still going, Allow runs to overlap, Forbid skips the new run and Replace stops the old one"},
+			&cli.StringFlag{Name: "misfire-policy", Usage: "runs missed while the service was down are skipped (skip), the latest is run (run_once) or all are run (run_all)"},
+		}, append(taskEnvFlags(), retryFlags()...)...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 			cronString := c.StringArg("cron")
 			taskString := c.StringArg("task")
@@ -107,7 +188,37 @@ func createScheduleCronCommand(tR resources.TaskResource) *cli.Command {
 				return cli.Exit("cron and task arguments are required", 1)
 			}
 
-			_, err := tR.ScheduleTask(cronString, taskString)
+			startAt, err := parseOptionalTime(c.String("start-at"))
+			if err != nil {
+				return cli.Exit("start-at must be an RFC 3339 time", 1)
+			}
+
+			endAt, err := parseOptionalTime(c.String("end-at"))
+			if err != nil {
+				return cli.Exit("end-at must be an RFC 3339 time", 1)
+			}
+
+			env, err := envFromFlags(c)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			_, err = tR.ScheduleTask(cronString, taskString,
+				resources.WithMetadata(crontab.Metadata{
+					Name:        c.String("name"),
+					Description: c.String("description"),
+					Owner:       c.String("owner"),
+					Tags:        c.StringSlice("tag"),
+				}),
+				resources.WithValidity(startAt, endAt),
+				resources.WithMaxRuns(int(c.Int("max-runs"))),
+				resources.WithArgs(registry.ArgsFor(taskString)),
+				resources.WithEnv(env),
+				resources.WithWorkDir(c.String("work-dir")),
+				resources.WithRetryPolicy(retryPolicyFromFlags(c)),
+				resources.WithConcurrencyPolicy(c.String("concurrency-policy")),
+				resources.WithMisfirePolicy(c.String("misfire-policy")),
+			)
 			if err != nil {
 				return err
 			}
@@ -122,9 +233,670 @@ func createScheduleCronCommand(tR resources.TaskResource) *cli.Command {
 	}
 }
 
-func init() {
-	taskResource := resources.CreateResources().TaskResource
-	CommandRegistry.Register(createStartGameCommand(taskResource))
-	CommandRegistry.Register(createScheduleCronCommand(taskResource))
-	CommandRegistry.Register(createPullMessagesCommand(taskResource))
+func createPauseTaskCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "pause-task",
+		Description: "Pauses a scheduled task without removing it from the crontab.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name: "uuid",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			id, err := uuid.Parse(c.StringArg("uuid"))
+			if err != nil {
+				return cli.Exit("a valid uuid argument is required", 1)
+			}
+
+			if err = tR.PauseTaskByID(id); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			slog.Info("Paused task", slog.String("id", id.String()))
+
+			return nil
+		},
+	}
+}
+
+func createResumeTaskCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "resume-task",
+		Description: "Resumes a previously paused task.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name: "uuid",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			id, err := uuid.Parse(c.StringArg("uuid"))
+			if err != nil {
+				return cli.Exit("a valid uuid argument is required", 1)
+			}
+
+			if err = tR.ResumeTaskByID(id); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			slog.Info("Resumed task", slog.String("id", id.String()))
+
+			return nil
+		},
+	}
+}
+
+func createMaintenanceCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "maintenance",
+		Description: "Suspends every scheduled task at once and restores them afterwards.",
+		Commands: []*cli.Command{
+			{
+				Name:        "enable",
+				Description: "Pauses all active tasks until maintenance is disabled or expires.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "by",
+						Usage: "who is enabling maintenance",
+						Value: os.Getenv("USER"),
+					},
+					&cli.DurationFlag{
+						Name:  "expires-in",
+						Usage: "automatically disable maintenance after this long",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					var expiresAt *time.Time
+					if d := c.Duration("expires-in"); d > 0 {
+						at := time.Now().Add(d).UTC()
+						expiresAt = &at
+					}
+
+					state, err := tR.EnableMaintenance(c.String("by"), expiresAt)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Maintenance enabled",
+						slog.String("enabled_by", state.EnabledBy),
+						slog.Int("suspended", len(state.SuspendedIDs)),
+					)
+
+					return nil
+				},
+			},
+			{
+				Name:        "disable",
+				Description: "Resumes the tasks that maintenance suspended.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					if _, err := tR.DisableMaintenance(); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Maintenance disabled")
+
+					return nil
+				},
+			},
+			{
+				Name:        "status",
+				Description: "Shows whether maintenance is on, who enabled it and when.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					state, err := tR.GetMaintenance()
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					out, err := json.MarshalIndent(state, "", "  ")
+					if err != nil {
+						return err
+					}
+
+					fmt.Println(string(out))
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func createMigrateCrontabCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "migrate-crontab",
+		Description: "Imports managed crontab lines into the task store, keeping their IDs.",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			imported, err := tR.MigrateCrontab()
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			slog.Info("Migrated crontab", slog.Int("imported", imported))
+
+			return nil
+		},
+	}
+}
+
+func createRevisionsCommand(tR resources.TaskResource) *cli.Command {
+	revisionArg := func(c *cli.Command, name string) (int, error) {
+		id, err := strconv.Atoi(c.StringArg(name))
+		if err != nil {
+			return 0, cli.Exit(fmt.Sprintf("%s must be a revision number", name), 1)
+		}
+
+		return id, nil
+	}
+
+	return &cli.Command{
+		Name:        "revisions",
+		Description: "Lists, compares and restores earlier versions of the crontab.",
+		Commands: []*cli.Command{
+			{
+				Name:        "list",
+				Description: "Lists revisions, newest first.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					revs, err := tR.GetRevisions()
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					for _, rev := range revs {
+						fmt.Printf("%d\t%s\t%s\t%d tasks\t%s\n",
+							rev.ID,
+							rev.CreatedAt.Format(time.RFC3339),
+							rev.Author,
+							len(rev.Entries),
+							rev.Reason,
+						)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:        "diff",
+				Description: "Shows the crontab lines that changed between two revisions.",
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "from"},
+					&cli.StringArg{Name: "to"},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					from, err := revisionArg(c, "from")
+					if err != nil {
+						return err
+					}
+
+					to, err := revisionArg(c, "to")
+					if err != nil {
+						return err
+					}
+
+					diff, err := tR.DiffRevisions(from, to)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					for _, line := range diff {
+						fmt.Println(line.String())
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:        "rollback",
+				Description: "Rewrites the crontab as it was at the given revision.",
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "id"},
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "reason",
+						Usage: "why the rollback is needed",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id, err := revisionArg(c, "id")
+					if err != nil {
+						return err
+					}
+
+					rev, err := tR.RollbackToRevision(id, c.String("reason"))
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Rolled back crontab",
+						slog.Int("revision", rev.ID),
+						slog.Int("tasks", len(rev.Entries)),
+					)
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func createImportCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "import",
+		Description: "Converts the jobs in an existing crontab into managed tasks.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name: "file",
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "user (five fields and a command) or system (with a user column)",
+				Value: crontab.IMPORT_FORMAT_USER,
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "show what would be written without changing anything",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			path := c.StringArg("file")
+			if path == "" {
+				return cli.Exit("file argument is required", 1)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			defer file.Close()
+
+			dryRun := c.Bool("dry-run")
+			plan, err := tR.ImportCrontab(file, c.String("format"), dryRun)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			for _, item := range plan.Accepted {
+				fmt.Printf("+ %s\n", item.Line)
+			}
+
+			for _, item := range plan.Rejected {
+				fmt.Printf("! line %d: %s (%s)\n", item.LineNo, item.Source, item.Reason)
+			}
+
+			if dryRun {
+				slog.Info("Dry run, nothing was written",
+					slog.Int("accepted", len(plan.Accepted)),
+					slog.Int("rejected", len(plan.Rejected)),
+				)
+			}
+
+			return nil
+		},
+	}
+}
+
+func createBatchCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "batch",
+		Description: "Schedules and removes tasks from a JSON or YAML file in one crontab write. Nothing is written if any item is invalid.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name: "file",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			path := c.StringArg("file")
+			if path == "" {
+				return cli.Exit("file argument is required", 1)
+			}
+
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			var batch resources.Batch
+			if strings.EqualFold(filepath.Ext(path), ".json") {
+				err = json.Unmarshal(raw, &batch)
+			} else {
+				err = yaml.Unmarshal(raw, &batch)
+			}
+
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("unable to read batch: %s", err), 1)
+			}
+
+			result, batchErr := tR.ApplyBatch(batch)
+
+			out, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(out))
+
+			if batchErr != nil {
+				return cli.Exit(batchErr.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
+
+func createDriftCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "drift",
+		Description: "Compares the crontab on disk with the task store.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "repair",
+				Usage: "rewrite the crontab from the task store if it has drifted",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			report, err := tR.CheckDrift(c.Bool("repair"))
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(out))
+
+			return nil
+		},
+	}
+}
+
+func createArchiveCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "archive",
+		Description: "Lists, restores and purges removed tasks.",
+		Commands: []*cli.Command{
+			{
+				Name:        "list",
+				Description: "Lists archived tasks, most recently removed first.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					archived, err := tR.GetArchivedTasks()
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					for _, task := range archived {
+						fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n",
+							task.Entry.ID,
+							task.DeletedAt.Format(time.RFC3339),
+							task.DeletedBy,
+							task.Entry.Cron.String(),
+							task.Entry.Cmd,
+							task.Reason,
+						)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:        "restore",
+				Description: "Schedules an archived task again with the same ID.",
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "uuid"},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					id, err := uuid.Parse(c.StringArg("uuid"))
+					if err != nil {
+						return cli.Exit("a valid uuid argument is required", 1)
+					}
+
+					ctbE, err := tR.RestoreTask(id)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Restored task",
+						slog.String("id", ctbE.ID.String()),
+						slog.String("cron", ctbE.Cron.String()),
+						slog.String("task", ctbE.Cmd),
+					)
+
+					return nil
+				},
+			},
+			{
+				Name:        "purge",
+				Description: "Drops archived tasks older than ARCHIVE_RETENTION.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					purged, err := tR.PurgeArchive()
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Purged archived tasks", slog.Int("count", purged))
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func createLogsCommand(logs *tasklog.Logs) *cli.Command {
+	return &cli.Command{
+		Name:        "logs",
+		Description: "Prints the output of a scheduled task.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{Name: "uuid"},
+		},
+		Flags: []cli.Flag{
+			&cli.IntFlag{Name: "tail", Value: 100, Usage: "number of lines to print, 0 prints everything"},
+			&cli.BoolFlag{Name: "follow", Aliases: []string{"f"}, Usage: "keep printing new output until interrupted"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			id, err := uuid.Parse(c.StringArg("uuid"))
+			if err != nil {
+				return cli.Exit("a valid uuid argument is required", 1)
+			}
+
+			follow := c.Bool("follow")
+
+			lines, err := logs.Tail(id, int(c.Int("tail")))
+			if err != nil && !(follow && errors.Is(err, tasklog.ErrNoLogs)) {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+
+			if !follow {
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return logs.Follow(ctx, id, func(line string) error {
+				_, err := fmt.Println(line)
+				return err
+			})
+		},
+	}
+}
+
+func createRunsCommand(tR resources.TaskResource) *cli.Command {
+	return &cli.Command{
+		Name:        "runs",
+		Description: "Lists the recorded runs of a task, newest first.",
+		Arguments: []cli.Argument{
+			&cli.StringArg{Name: "uuid"},
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "output", Usage: "also print the end of each run's output"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			id, err := uuid.Parse(c.StringArg("uuid"))
+			if err != nil {
+				return cli.Exit("a valid uuid argument is required", 1)
+			}
+
+			runs, err := tR.GetTaskRuns(id)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+
+			for _, run := range runs {
+				fmt.Printf("%s\t%s\t%s\t%s\texit %d\t%s\t%s\n",
+					run.ScheduledAt.Format(time.RFC3339),
+					run.StartedAt.Format(time.RFC3339),
+					run.Outcome,
+					run.Duration().Round(time.Millisecond),
+					run.ExitCode,
+					run.Source,
+					run.Error,
+				)
+
+				if c.Bool("output") && run.Output != "" {
+					fmt.Println(strings.TrimRight(run.Output, "\n"))
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func createRoomTasksCommand(tR resources.TaskResource) *cli.Command {
+	roomArg := []cli.Argument{
+		&cli.StringArg{
+			Name: "room_id",
+		},
+	}
+
+	return &cli.Command{
+		Name:        "room-tasks",
+		Description: "Lists or cancels the tasks scheduled for a room.",
+		Commands: []*cli.Command{
+			{
+				Name:        "list",
+				Description: "Lists the tasks scheduled for the room.",
+				Arguments:   roomArg,
+				Action: func(ctx context.Context, c *cli.Command) error {
+					roomID := c.StringArg("room_id")
+					if roomID == "" {
+						return cli.Exit("room_id argument is required", 1)
+					}
+
+					entries, err := tR.GetRoomTasks(roomID)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					for _, ctbE := range entries {
+						fmt.Printf("%s  %-15s  %s\n", ctbE.ID, ctbE.Cron.String(), ctbE.Cmd)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:        "cancel",
+				Description: "Removes every task scheduled for the room in one crontab write.",
+				Arguments:   roomArg,
+				Action: func(ctx context.Context, c *cli.Command) error {
+					roomID := c.StringArg("room_id")
+					if roomID == "" {
+						return cli.Exit("room_id argument is required", 1)
+					}
+
+					result, err := tR.CancelRoomTasks(roomID)
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+
+					slog.Info("Cancelled room tasks",
+						slog.String("room_id", roomID),
+						slog.Int("count", len(result.Items)),
+					)
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// Flags hold their parsed values, so every command gets its own
+func taskEnvFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{Name: "env", Usage: "NAME=value set for the task, can be repeated"},
+		&cli.StringSliceFlag{Name: "secret-env", Usage: "like --env, but the value is redacted in the API"},
+		&cli.StringFlag{Name: "work-dir", Usage: "absolute directory the task runs from"},
+	}
+}
+
+func envFromFlags(c *cli.Command) ([]crontab.EnvVar, error) {
+	var env []crontab.EnvVar
+
+	for _, flag := range []string{"env", "secret-env"} {
+		for _, raw := range c.StringSlice(flag) {
+			name, value, ok := strings.Cut(raw, "=")
+			if !ok {
+				return nil, fmt.Errorf("--%s must be NAME=value, got %q", flag, raw)
+			}
+
+			env = append(env, crontab.EnvVar{Name: name, Value: value, Secret: flag == "secret-env"})
+		}
+	}
+
+	return env, nil
+}
+
+func retryFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{Name: "retry-attempts", Usage: "how many times a failed run is attempted in all, 0 or 1 does not retry"},
+		&cli.DurationFlag{Name: "retry-delay", Usage: "how long to wait before the first retry"},
+		&cli.FloatFlag{Name: "retry-multiplier", Usage: "how much each delay grows by, 2 if not set"},
+		&cli.DurationFlag{Name: "retry-max-delay", Usage: "the longest to wait between attempts"},
+		&cli.FloatFlag{Name: "retry-jitter", Usage: "spread each delay by up to this fraction either way, 0 to 1"},
+	}
+}
+
+func retryPolicyFromFlags(c *cli.Command) *crontab.RetryPolicy {
+	if c.Int("retry-attempts") == 0 {
+		return nil
+	}
+
+	return &crontab.RetryPolicy{
+		MaxAttempts:  int(c.Int("retry-attempts")),
+		InitialDelay: c.Duration("retry-delay"),
+		Multiplier:   c.Float("retry-multiplier"),
+		MaxDelay:     c.Duration("retry-max-delay"),
+		Jitter:       c.Float("retry-jitter"),
+	}
+}
+
+func parseOptionalTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func cliActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+
+	return "cli"
 }
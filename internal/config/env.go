@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
@@ -12,6 +13,81 @@ var Config config
 type config struct {
 	CrontabFile  string `env:"CRONTAB_FILE" envDefault:"./e2e/storage/crontab"`
 	RabbitMQHost string `env:"RABBITMQ_HOST" envDefault:"localhost:5672/"`
+	StateDir     string `env:"STATE_DIR" envDefault:"./e2e/storage"`
+
+	// Splits the crontab into coco-<shard> files next to CRONTAB_FILE, one per
+	// task type (command) or owner (tenant). Empty writes a single crontab.
+	CrontabShardBy string `env:"CRONTAB_SHARD_BY"`
+
+	// Signs every crontab line with an HMAC so lines added or edited outside
+	// the service are caught and quarantined. Empty disables signing.
+	CrontabSigningKey string `env:"CRONTAB_SIGNING_KEY"`
+
+	// Where tasks are scheduled: crontab, systemd to write a timer and service
+	// per task into SYSTEMD_UNIT_DIR, or kubernetes to write a CronJob
+	// manifest per task into KUBERNETES_MANIFEST_DIR. With SYSTEMD_RELOAD set,
+	// systemctl is run to pick up every change.
+	ScheduleBackend string `env:"SCHEDULE_BACKEND" envDefault:"crontab"`
+	SystemdUnitDir  string `env:"SYSTEMD_UNIT_DIR" envDefault:"./e2e/storage/systemd"`
+	SystemdReload   bool   `env:"SYSTEMD_RELOAD" envDefault:"false"`
+
+	// Who fires the scheduled tasks: crond, or in-process for the API server to
+	// run the commands itself
+	SchedulerMode string `env:"SCHEDULER_MODE" envDefault:"crond"`
+
+	KubernetesManifestDir       string `env:"KUBERNETES_MANIFEST_DIR" envDefault:"./e2e/storage/kubernetes"`
+	KubernetesImage             string `env:"KUBERNETES_IMAGE" envDefault:"coco-task-manager:latest"`
+	KubernetesNamespace         string `env:"KUBERNETES_NAMESPACE"`
+	KubernetesTimeZone          string `env:"KUBERNETES_TIME_ZONE"`
+	KubernetesConcurrencyPolicy string `env:"KUBERNETES_CONCURRENCY_POLICY" envDefault:"Allow"`
+	KubernetesSecretName        string `env:"KUBERNETES_SECRET_NAME"`
+
+	// What to do with new schedules while maintenance mode is on: reject or queue
+	MaintenanceSchedulePolicy string `env:"MAINTENANCE_SCHEDULE_POLICY" envDefault:"reject"`
+
+	// How many crontab revisions to keep, oldest are dropped first
+	RevisionLimit int `env:"REVISION_LIMIT" envDefault:"50"`
+
+	// How often the crontab on disk is compared with the task store, 0 disables
+	// the periodic check. With DRIFT_REPAIR set, drift is fixed as it is found.
+	DriftCheckInterval time.Duration `env:"DRIFT_CHECK_INTERVAL" envDefault:"5m"`
+	DriftRepair        bool          `env:"DRIFT_REPAIR" envDefault:"false"`
+
+	// How long an Idempotency-Key is remembered, and what to do when an
+	// identical cron and command is scheduled again: allow, reject or existing
+	IdempotencyWindow time.Duration `env:"IDEMPOTENCY_WINDOW" envDefault:"24h"`
+	DuplicatePolicy   string        `env:"DUPLICATE_POLICY" envDefault:"allow"`
+
+	// How long removed tasks stay in the archive before they are purged, 0
+	// keeps them forever
+	ArchiveRetention time.Duration `env:"ARCHIVE_RETENTION" envDefault:"720h"`
+
+	// How many runs of each task are kept in the run history, and for how long.
+	// A retention of 0 keeps them until the limit pushes them out.
+	RunHistoryLimit     int           `env:"RUN_HISTORY_LIMIT" envDefault:"50"`
+	RunHistoryRetention time.Duration `env:"RUN_HISTORY_RETENTION" envDefault:"720h"`
+
+	// How long a run being replaced by a newer one, under the Replace
+	// concurrency policy, gets to stop after SIGTERM before it is killed
+	ReplaceGracePeriod time.Duration `env:"REPLACE_GRACE_PERIOD" envDefault:"30s"`
+
+	// How far back runs missed while the service was down are looked for on
+	// startup, 0 disables the check
+	MisfireLookback time.Duration `env:"MISFIRE_LOOKBACK" envDefault:"24h"`
+
+	// Each task's output is appended to TASK_LOG_DIR/<id>.log. Logs bigger than
+	// TASK_LOG_MAX_SIZE bytes are rotated, keeping TASK_LOG_MAX_FILES of them,
+	// and logs not written to for TASK_LOG_MAX_AGE are deleted.
+	TaskLogDir      string        `env:"TASK_LOG_DIR" envDefault:"./e2e/storage/logs"`
+	TaskLogMaxSize  int64         `env:"TASK_LOG_MAX_SIZE" envDefault:"10485760"`
+	TaskLogMaxFiles int           `env:"TASK_LOG_MAX_FILES" envDefault:"5"`
+	TaskLogMaxAge   time.Duration `env:"TASK_LOG_MAX_AGE" envDefault:"720h"`
+
+	// The crontab watcher waits WATCH_DEBOUNCE after the last change before
+	// re-reading. Polling is used when inotify is unavailable or WATCH_POLLING is set.
+	WatchDebounce     time.Duration `env:"WATCH_DEBOUNCE" envDefault:"500ms"`
+	WatchPollInterval time.Duration `env:"WATCH_POLL_INTERVAL" envDefault:"5s"`
+	WatchPolling      bool          `env:"WATCH_POLLING" envDefault:"false"`
 }
 
 type ConfigOptFn func(o *opts)
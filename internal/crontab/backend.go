@@ -0,0 +1,128 @@
+package crontab
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The CrontabHandler methods every backend shares. Tasks live in the store
+// and render is called after each change to write out whatever the backend
+// schedules from, e.g. the crontab file or systemd units.
+type storeBackend struct {
+	store  TaskStore
+	render func() error
+	// Optional, rejects entries the backend cannot schedule before they are stored
+	validate func(CrontabEntry) error
+}
+
+// Stores the entries and regenerates the backend's files
+func (sb *storeBackend) WriteCrontabEntries(crontabs []CrontabEntry) error {
+	if err := sb.check(crontabs); err != nil {
+		return err
+	}
+
+	if err := sb.store.Put(crontabs...); err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+func (sb *storeBackend) GetAllCrontabEntries() ([]CrontabEntry, error) {
+	return sb.store.All()
+}
+
+func (sb *storeBackend) GetCrontabEntryByID(id uuid.UUID) (CrontabEntry, error) {
+	return sb.store.Get(id)
+}
+
+func (sb *storeBackend) RemoveCrontabEntryByID(id uuid.UUID) error {
+	if err := sb.store.Delete(id); err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+func (sb *storeBackend) PauseCrontabEntryByID(id uuid.UUID) error {
+	return sb.setPausedByID(id, true)
+}
+
+func (sb *storeBackend) ResumeCrontabEntryByID(id uuid.UUID) error {
+	return sb.setPausedByID(id, false)
+}
+
+func (sb *storeBackend) UpdateCrontabEntryByID(id uuid.UUID, fn func(ctbE *CrontabEntry) error) error {
+	err := sb.store.Update(id, func(ctbE *CrontabEntry) error {
+		if err := fn(ctbE); err != nil {
+			return err
+		}
+
+		return sb.check([]CrontabEntry{*ctbE})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+func (sb *storeBackend) UpdateCrontabEntries(fn func(entries *[]CrontabEntry) error) error {
+	err := sb.store.UpdateAll(func(entries *[]CrontabEntry) error {
+		if err := fn(entries); err != nil {
+			return err
+		}
+
+		return sb.check(*entries)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+func (sb *storeBackend) setPausedByID(id uuid.UUID, paused bool) error {
+	err := sb.store.Update(id, func(ctbE *CrontabEntry) error {
+		ctbE.Paused = paused
+		ctbE.Meta.UpdatedAt = time.Now().UTC()
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+// Replaces every stored entry and regenerates the backend's files in one go
+func (sb *storeBackend) ReplaceCrontabEntries(entries []CrontabEntry) error {
+	if err := sb.check(entries); err != nil {
+		return err
+	}
+
+	if err := sb.store.Replace(entries); err != nil {
+		return err
+	}
+
+	return sb.render()
+}
+
+func (sb *storeBackend) check(entries []CrontabEntry) error {
+	if sb.validate == nil {
+		return nil
+	}
+
+	for _, ctbE := range entries {
+		if err := sb.validate(ctbE); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
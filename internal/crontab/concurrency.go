@@ -0,0 +1,26 @@
+package crontab
+
+import "errors"
+
+// What happens when a task fires while its last run is still going. Named
+// after the Kubernetes CronJob policies, which behave the same way.
+const (
+	// The runs overlap
+	CONCURRENCY_ALLOW = "Allow"
+	// The new run is skipped
+	CONCURRENCY_FORBID = "Forbid"
+	// The running one is cancelled and the new run goes ahead
+	CONCURRENCY_REPLACE = "Replace"
+)
+
+var ErrInvalidConcurrencyPolicy = errors.New("concurrency policy must be Allow, Forbid or Replace")
+
+// Empty is valid and allows overlapping runs
+func ValidateConcurrencyPolicy(policy string) error {
+	switch policy {
+	case "", CONCURRENCY_ALLOW, CONCURRENCY_FORBID, CONCURRENCY_REPLACE:
+		return nil
+	}
+
+	return ErrInvalidConcurrencyPolicy
+}
@@ -2,7 +2,10 @@ package crontab
 
 import (
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -10,15 +13,82 @@ import (
 )
 
 type CrontabEntry struct {
-	ID   uuid.UUID
-	Cron parser.Cron
-	Cmd  string
+	ID     uuid.UUID   `json:"id"`
+	Cron   parser.Cron `json:"cron"`
+	Cmd    string      `json:"cmd"`
+	Paused bool        `json:"paused,omitempty"`
+	Meta   Metadata    `json:"meta,omitzero"`
+	// Set for commands imported from another crontab, which run exactly as
+	// written instead of from /app
+	Imported bool `json:"imported,omitempty"`
+	// The named arguments the command was scheduled with, e.g. room_id
+	Args map[string]string `json:"args,omitempty"`
+	// Set in the command's environment and working directory when it runs
+	Env     []EnvVar `json:"env,omitempty"`
+	WorkDir string   `json:"work_dir,omitempty"`
+	// Set for one-shot tasks, which fire once at this time and are then removed
+	RunAt *time.Time `json:"run_at,omitempty"`
+	// Optional window the task may fire in, and a cap on how often it fires.
+	// Runs counts the times it has fired so far.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+	MaxRuns int        `json:"max_runs,omitempty"`
+	Runs    int        `json:"runs,omitempty"`
+	// How failed runs are retried, nil runs each fire once
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// What happens when the task fires while its last run is still going,
+	// empty allows the runs to overlap
+	ConcurrencyPolicy string `json:"concurrency_policy,omitempty"`
+	// What happens to runs missed while nothing was firing the task, empty
+	// skips them
+	MisfirePolicy string `json:"misfire_policy,omitempty"`
+}
+
+func (ctbE CrontabEntry) IsOneShot() bool {
+	return ctbE.RunAt != nil
+}
+
+// Whether the CLI has to check in with the task store when the task fires
+func (ctbE CrontabEntry) IsTracked() bool {
+	return ctbE.IsOneShot() || ctbE.StartAt != nil || ctbE.EndAt != nil || ctbE.MaxRuns > 0
+}
+
+// How many more times the task may fire. ok is false when it is unlimited.
+func (ctbE CrontabEntry) RemainingRuns() (remaining int, ok bool) {
+	if ctbE.MaxRuns == 0 {
+		return 0, false
+	}
+
+	return max(ctbE.MaxRuns-ctbE.Runs, 0), true
+}
+
+// Why the task must not fire at the given time, or an empty string if it may
+func (ctbE CrontabEntry) SuppressedReason(now time.Time) string {
+	switch {
+	case ctbE.StartAt != nil && now.Before(*ctbE.StartAt):
+		return "before start_at"
+	case ctbE.EndAt != nil && !now.Before(*ctbE.EndAt):
+		return "after end_at"
+	}
+
+	if remaining, ok := ctbE.RemainingRuns(); ok && remaining == 0 {
+		return "max_runs reached"
+	}
+
+	return ""
 }
 
 func NewCrontabEntryFromString(input string) (CrontabEntry, error) {
 	var err error
 	var ctbE CrontabEntry
-	parts := strings.Split(input, " root ")
+
+	line := input
+	if strings.HasPrefix(line, pausedPrefix) {
+		ctbE.Paused = true
+		line = strings.TrimPrefix(line, pausedPrefix)
+	}
+
+	parts := strings.Split(line, " root ")
 
 	if len(parts) != 2 {
 		return ctbE, invalidCronTabEntry(input)
@@ -26,17 +96,8 @@ func NewCrontabEntryFromString(input string) (CrontabEntry, error) {
 
 	cronPart := parts[0]
 
-	p, err := parser.NewParser(
-		parser.WithInput(cronPart, true),
-	)
-
-	if err != nil {
-		return ctbE, err
-	}
-
-	// create unmarshaltext so we can just read it from the string?
-	cron, err := p.Parse()
-	if err != nil {
+	var cron parser.Cron
+	if err = cron.UnmarshalText([]byte(cronPart)); err != nil {
 		return ctbE, err
 	}
 
@@ -45,18 +106,136 @@ func NewCrontabEntryFromString(input string) (CrontabEntry, error) {
 		return ctbE, invalidCronTabEntry(input)
 	}
 
-	uuID, err := uuid.Parse(moreParts[1])
+	tags := strings.Fields(moreParts[1])
+	if len(tags) == 0 {
+		return ctbE, invalidCronTabEntry(input)
+	}
+
+	uuID, err := uuid.Parse(tags[0])
 	if err != nil {
 		return ctbE, err
 	}
 
+	var secrets []string
+	for _, tag := range tags[1:] {
+		if encoded, ok := strings.CutPrefix(tag, metaTagPrefix); ok {
+			if ctbE.Meta, err = decodeMetadata(encoded); err != nil {
+				return ctbE, invalidCronTabEntry(input)
+			}
+		}
+
+		if at, ok := strings.CutPrefix(tag, onceTagPrefix); ok {
+			runAt, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return ctbE, invalidCronTabEntry(input)
+			}
+
+			ctbE.RunAt = &runAt
+		}
+
+		if names, ok := strings.CutPrefix(tag, secretTagPrefix); ok {
+			secrets = strings.Split(names, ",")
+		}
+
+		if tag == importedTag {
+			ctbE.Imported = true
+		}
+	}
+
+	workDir, env, cmd, err := parseCommandPrefix(moreParts[0])
+	if err != nil {
+		return ctbE, invalidCronTabEntry(input)
+	}
+
+	for i := range env {
+		env[i].Secret = slices.Contains(secrets, env[i].Name)
+	}
+
+	if !ctbE.Imported {
+		cmd = strings.TrimPrefix(cmd, cmdPathPrefix)
+	}
+	cmd, _, _ = strings.Cut(cmd, cmdLogSeparator)
+
 	ctbE.Cron = cron
 	ctbE.ID = uuID
-	ctbE.Cmd = moreParts[0]
+	ctbE.Cmd = cmd
+	ctbE.Env = env
+	ctbE.WorkDir = workDir
 
 	return ctbE, nil
 }
 
+// Renders the entry as a line for the crontab file, with its output going to
+// the shared log. Paused entries are commented out so cron skips them but we
+// can still read them back. Lines are signed when a signing key is
+// configured.
+func (ctbE CrontabEntry) String() string {
+	return ctbE.renderLine(signingKey(), "")
+}
+
+// Renders the line with the output appended to the task's own log in
+// logDir. An empty logDir uses the shared log.
+func (ctbE CrontabEntry) renderLine(key []byte, logDir string) string {
+	ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+
+	trailer := ctbE.ID.String()
+	if !ctbE.Meta.IsZero() {
+		encoded, err := encodeMetadata(ctbE.Meta)
+		if err != nil {
+			slog.Error(err.Error())
+		} else {
+			trailer += " " + metaTagPrefix + encoded
+		}
+	}
+
+	if ctbE.RunAt != nil {
+		trailer += " " + onceTagPrefix + ctbE.RunAt.UTC().Format(time.RFC3339)
+	}
+
+	if secrets := ctbE.secretNames(); len(secrets) > 0 {
+		trailer += " " + secretTagPrefix + strings.Join(secrets, ",")
+	}
+
+	if ctbE.Imported {
+		trailer += " " + importedTag
+	}
+
+	line := fmt.Sprintf(cronFormat, ctbE.Cron, ctbE.commandPrefix(), ctbE.execCommand(), ctbE.logFile(logDir), trailer)
+
+	if ctbE.Paused {
+		line = pausedPrefix + line
+	}
+
+	if len(key) == 0 {
+		return line
+	}
+
+	return signLine(strings.TrimSuffix(line, "\n"), key) + "\n"
+}
+
+// The command line as it is run. Ours live in /app, while imported commands
+// and absolute paths run as written.
+func (ctbE CrontabEntry) execCommand() string {
+	if ctbE.Imported || strings.HasPrefix(ctbE.Cmd, "/") {
+		return ctbE.Cmd
+	}
+
+	return cmdPathPrefix + ctbE.Cmd
+}
+
+// Each task appends to its own log, keyed by ID
+func (ctbE CrontabEntry) logFile(logDir string) string {
+	if logDir == "" {
+		return sharedLogFile
+	}
+
+	return shellQuote(taskLogPath(logDir, ctbE.ID))
+}
+
 func invalidCronTabEntry(input string) error {
 	return fmt.Errorf("%s is not a valid crontab entry", input)
 }
+
+func crontabEntryNotFound(id uuid.UUID) error {
+	return fmt.Errorf("%w with ID of %s", ErrCrontabEntryNotFound, id)
+}
@@ -2,169 +2,251 @@ package crontab
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
-
-	"github.com/google/uuid"
+	"time"
 
 	"github.com/captainmango/coco-cron-parser/internal/config"
-	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
 )
 
+// Keeps tasks in a TaskStore and renders the crontab file from it. Nothing
+// reads the crontab back during normal operation.
 type CrontabManager struct {
+	storeBackend
 	mu sync.Mutex
+	// Set to split the crontab into shard files, see WithShards
+	shardBy string
+	// Where each task's own log goes, see WithTaskLogDir
+	logDir string
+	// Where lines failing their signature check go, see WithQuarantine
+	quarantine *store.JSONFile[[]TamperedLine]
+	alert      func(TamperedLine)
 }
 
-func NewCrontabManager() *CrontabManager {
-	return &CrontabManager{}
-}
-
-// Sets the cron printing mode to RAW_EXPRESSION and writes to the configured crontab file
-func (cM *CrontabManager) WriteCrontabEntries(crontabs []CrontabEntry) error {
-	err := cM.withCrontab(func(f *os.File) error {
-		for _, ctbE := range crontabs {
-			currPrintingMode := ctbE.Cron.PrintingMode
-			ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+type CrontabManagerOptFn func(cM *CrontabManager)
 
-			_, err := fmt.Fprintf(f, cronFormat, ctbE.Cron, ctbE.Cmd, ctbE.ID)
-			if err != nil {
-				return err
-			}
+func NewCrontabManager(opts ...CrontabManagerOptFn) *CrontabManager {
+	cM := &CrontabManager{}
 
-			ctbE.Cron.PrintingMode = currPrintingMode
-		}
-
-		return nil
-	})
+	for _, fn := range opts {
+		fn(cM)
+	}
 
-	if err != nil {
-		return err
+	if cM.store == nil {
+		cM.store = NewFileTaskStore(filepath.Join(config.Config.StateDir, "tasks.json"))
 	}
 
-	return nil
+	cM.render = cM.renderCrontab
+
+	return cM
 }
 
-func (cM *CrontabManager) GetAllCrontabEntries() ([]CrontabEntry, error) {
-	var out []CrontabEntry
+func WithTaskStore(ts TaskStore) CrontabManagerOptFn {
+	return func(cM *CrontabManager) {
+		cM.store = ts
+	}
+}
 
-	err := cM.withCrontab(func(f *os.File) error {
-		scanner := bufio.NewScanner(f)
+// Appends each task's output to its own log in dir instead of the shared log
+func WithTaskLogDir(dir string) CrontabManagerOptFn {
+	return func(cM *CrontabManager) {
+		cM.logDir = absLogDir(dir)
+	}
+}
 
-		for scanner.Scan() {
-			line := scanner.Text()
+// The entry's line as this manager writes it
+func (cM *CrontabManager) RenderLine(ctbE CrontabEntry) string {
+	return ctbE.renderLine(signingKey(), cM.logDir)
+}
 
-			ctbE, err := NewCrontabEntryFromString(line)
-			if err != nil {
-				return err
-			}
+// Parses the managed lines currently in the crontab file. Blank lines are
+// skipped, anything else that is not a managed line is an error.
+func (cM *CrontabManager) ReadCrontabFile() ([]CrontabEntry, error) {
+	lines, err := cM.ReadCrontabLines()
+	if err != nil {
+		return nil, err
+	}
 
-			out = append(out, ctbE)
+	var out []CrontabEntry
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
 		}
 
-		return nil
-	})
+		ctbE, err := NewCrontabEntryFromString(line)
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil {
-		return nil, err
+		out = append(out, ctbE)
 	}
 
 	return out, nil
 }
 
-func (cM *CrontabManager) GetCrontabEntryByID(id uuid.UUID) (CrontabEntry, error) {
-	var ctbE CrontabEntry
-	allEntries, err := cM.GetAllCrontabEntries()
-	if err != nil {
-		return ctbE, nil
+// Reads the crontab file, followed by the shard files when sharding
+func (cM *CrontabManager) ReadCrontabLines() ([]string, error) {
+	cM.mu.Lock()
+	defer cM.mu.Unlock()
+	file := config.Config.CrontabFile
+	if file == "" {
+		return nil, errCrontabFileNotSet
 	}
 
-	for _, item := range allEntries {
-		if item.ID == id {
-			ctbE = item
+	files := []string{file}
+	if cM.shardBy != "" {
+		shards, err := shardFiles(file)
+		if err != nil {
+			return nil, err
 		}
+
+		files = append(files, shards...)
 	}
 
-	if ctbE.ID == uuid.Nil {
-		return ctbE, fmt.Errorf("did not find ctbE with ID of %s", id)
+	var out []string
+	for _, path := range files {
+		lines, err := readLines(path)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, lines...)
 	}
 
-	return ctbE, nil
+	return out, nil
 }
 
-func (cM *CrontabManager) RemoveCrontabEntryByID(id uuid.UUID) error {
-	allEntries, err := cM.GetAllCrontabEntries()
+func readLines(path string) ([]string, error) {
+	crontab, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer crontab.Close()
 
-	var entriesToKeep []CrontabEntry
+	var out []string
+	scanner := bufio.NewScanner(crontab)
 
-	for _, item := range allEntries {
-		if item.ID == id {
-			continue
-		}
-
-		entriesToKeep = append(entriesToKeep, item)
+	for scanner.Scan() {
+		out = append(out, scanner.Text())
 	}
 
-	if err = cM.emptyCrontab(); err != nil {
-		return err
+	if err = scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	err = cM.withCrontab(func(f *os.File) error {
-		for _, item := range entriesToKeep {
-			_, err = fmt.Fprintf(f, cronFormat, item.Cron, item.Cmd, item.ID)
-			if err != nil {
-				return err
-			}
-		}
+	return out, nil
+}
 
-		return nil
-	})
+// Reports how the crontab file differs from the task store
+func (cM *CrontabManager) DetectDrift() (DriftReport, error) {
+	expected, err := cM.store.All()
+	if err != nil {
+		return DriftReport{}, err
+	}
 
+	actual, err := cM.ReadCrontabLines()
 	if err != nil {
-		return err
+		return DriftReport{}, err
 	}
 
-	return nil
+	return DriftReport{
+		CheckedAt: time.Now().UTC(),
+		Items:     diffCrontab(expected, actual, cM.RenderLine),
+	}, nil
 }
 
-func (cM *CrontabManager) withCrontab(fn func(f *os.File) error) error {
-	cM.mu.Lock()
-	defer cM.mu.Unlock() // Slow, but am OK with it locking for the
-	file := config.Config.CrontabFile
-	if file == "" {
-		return errCrontabFileNotSet
+// Re-renders the crontab from the task store when it has drifted. The report
+// describes the drift that was found before the repair.
+func (cM *CrontabManager) RepairDrift() (DriftReport, error) {
+	report, err := cM.DetectDrift()
+	if err != nil || report.InSync() {
+		return report, err
 	}
 
-	crontab, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
-	if err != nil {
-		return err
+	if err = cM.renderCrontab(); err != nil {
+		return report, err
 	}
-	defer crontab.Close()
 
-	err = fn(crontab)
+	report.Repaired = true
+
+	return report, nil
+}
 
+// Copies managed lines from the crontab file into the store, keeping their
+// IDs. Entries the store already knows about are left alone.
+func (cM *CrontabManager) ImportCrontabFile() (int, error) {
+	fileEntries, err := cM.ReadCrontabFile()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
-}
+	var toImport []CrontabEntry
+	for _, item := range fileEntries {
+		_, err = cM.store.Get(item.ID)
+		if errors.Is(err, ErrCrontabEntryNotFound) {
+			toImport = append(toImport, item)
+			continue
+		}
 
-func (cM *CrontabManager) emptyCrontab() error {
-	cM.mu.Lock()
-	defer cM.mu.Unlock()
-	file := config.Config.CrontabFile
-	if file == "" {
-		return errCrontabFileNotSet
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	err := os.Truncate(file, 0)
-	if err != nil {
-		return err
+	if len(toImport) == 0 {
+		return 0, nil
 	}
 
-	return nil
+	if err = cM.store.Put(toImport...); err != nil {
+		return 0, err
+	}
+
+	slog.Info("imported crontab entries into task store",
+		slog.Int("count", len(toImport)),
+	)
+
+	return len(toImport), cM.renderCrontab()
+}
+
+// Regenerates the crontab, or its shards, from the store. Files are swapped in
+// atomically so cron never sees a half written crontab.
+func (cM *CrontabManager) renderCrontab() error {
+	// Rendered under the store's lock, so an older render never lands last
+	return cM.store.View(func(entries []CrontabEntry) error {
+		cM.mu.Lock()
+		defer cM.mu.Unlock()
+
+		file := config.Config.CrontabFile
+		if file == "" {
+			return errCrontabFileNotSet
+		}
+
+		// tee will not create the directory the task logs go in
+		if cM.logDir != "" {
+			if err := os.MkdirAll(cM.logDir, 0755); err != nil {
+				return err
+			}
+		}
+
+		if cM.shardBy != "" {
+			return cM.renderShards(file, entries)
+		}
+
+		var builder strings.Builder
+		for _, item := range entries {
+			builder.WriteString(cM.RenderLine(item))
+		}
+
+		return store.WriteFileAtomic(file, []byte(builder.String()), store.PRIVATE_FILE_MODE)
+	})
 }
@@ -0,0 +1,64 @@
+package crontab
+
+const (
+	DIFF_SAME    = " "
+	DIFF_ADDED   = "+"
+	DIFF_REMOVED = "-"
+)
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Line string `json:"line"`
+}
+
+func (dl DiffLine) String() string {
+	return dl.Op + " " + dl.Line
+}
+
+// Line diff between two renderings of the crontab, based on the longest
+// common subsequence. Crontabs are small so the quadratic table is fine.
+func DiffLines(from, to []string) []DiffLine {
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+				continue
+			}
+
+			lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+		}
+	}
+
+	var out []DiffLine
+	i, j := 0, 0
+
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			out = append(out, DiffLine{DIFF_SAME, from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{DIFF_REMOVED, from[i]})
+			i++
+		default:
+			out = append(out, DiffLine{DIFF_ADDED, to[j]})
+			j++
+		}
+	}
+
+	for ; i < len(from); i++ {
+		out = append(out, DiffLine{DIFF_REMOVED, from[i]})
+	}
+
+	for ; j < len(to); j++ {
+		out = append(out, DiffLine{DIFF_ADDED, to[j]})
+	}
+
+	return out
+}
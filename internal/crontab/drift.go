@@ -0,0 +1,107 @@
+package crontab
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DriftKind string
+
+const (
+	DRIFT_ADDED    DriftKind = "added"    // on disk but not in the task store
+	DRIFT_REMOVED  DriftKind = "removed"  // in the task store but missing on disk
+	DRIFT_MODIFIED DriftKind = "modified" // on disk under a known ID, but changed
+)
+
+type DriftItem struct {
+	Kind DriftKind `json:"kind"`
+	// Unset for added lines that are not managed entries
+	ID       uuid.UUID `json:"id"`
+	Expected string    `json:"expected,omitempty"`
+	Actual   string    `json:"actual,omitempty"`
+}
+
+type DriftReport struct {
+	CheckedAt time.Time   `json:"checked_at"`
+	Items     []DriftItem `json:"items"`
+	Repaired  bool        `json:"repaired"`
+}
+
+func (dr DriftReport) InSync() bool {
+	return len(dr.Items) == 0
+}
+
+// Implemented by handlers that can compare what they expect to be scheduled
+// with what is actually on disk, and put the disk back when asked
+type DriftReconciler interface {
+	DetectDrift() (DriftReport, error)
+	RepairDrift() (DriftReport, error)
+}
+
+// Compares the expected lines, as render writes them, with the ones found on
+// disk. Lines are matched by task ID, so a reordered file is not drift.
+func diffCrontab(expected []CrontabEntry, actual []string, render func(CrontabEntry) string) []DriftItem {
+	items := []DriftItem{}
+	seen := make(map[uuid.UUID]bool, len(actual))
+
+	type expectedLine struct {
+		line     string
+		redacted string
+	}
+
+	byID := make(map[uuid.UUID]expectedLine, len(expected))
+	for _, ctbE := range expected {
+		byID[ctbE.ID] = expectedLine{
+			line:     strings.TrimSpace(render(ctbE)),
+			redacted: strings.TrimSpace(render(ctbE.Redacted())),
+		}
+	}
+
+	for _, line := range actual {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		ctbE, err := NewCrontabEntryFromString(line)
+		if err != nil {
+			items = append(items, DriftItem{Kind: DRIFT_ADDED, Actual: line})
+			continue
+		}
+
+		want, known := byID[ctbE.ID]
+		switch {
+		case !known || seen[ctbE.ID]:
+			items = append(items, DriftItem{Kind: DRIFT_ADDED, ID: ctbE.ID, Actual: redactLine(ctbE, line, render)})
+		case want.line != line:
+			items = append(items, DriftItem{
+				Kind:     DRIFT_MODIFIED,
+				ID:       ctbE.ID,
+				Expected: want.redacted,
+				Actual:   redactLine(ctbE, line, render),
+			})
+		}
+
+		seen[ctbE.ID] = true
+	}
+
+	for _, ctbE := range expected {
+		if !seen[ctbE.ID] {
+			items = append(items, DriftItem{Kind: DRIFT_REMOVED, ID: ctbE.ID, Expected: byID[ctbE.ID].redacted})
+		}
+	}
+
+	return items
+}
+
+// Lines with secrets in them are shown as they would be rendered redacted,
+// since the line itself cannot be safely reported
+func redactLine(parsed CrontabEntry, line string, render func(CrontabEntry) string) string {
+	if len(parsed.secretNames()) == 0 {
+		return line
+	}
+
+	return strings.TrimSpace(render(parsed.Redacted()))
+}
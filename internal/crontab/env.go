@@ -0,0 +1,183 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Shown in place of secret values anywhere an entry leaves the service
+const REDACTED = "[redacted]"
+
+var (
+	ErrInvalidEnvName   = errors.New("environment variable names must be letters, digits and underscores, not starting with a digit")
+	ErrInvalidEnvValue  = errors.New("environment variable values must not contain newlines, '%', ' # ' or ' root '")
+	ErrDuplicateEnvName = errors.New("environment variable declared more than once")
+	ErrInvalidWorkDir   = errors.New("working directory must be a clean absolute path without newlines, '%', ' # ' or ' root '")
+
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// An environment variable set for a single task. Secret values are redacted
+// in API responses and in revision and idempotency snapshots, but are written
+// to the crontab as they are.
+type EnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// Checks the variables can be rendered into a crontab line and read back
+func ValidateEnv(env []EnvVar) error {
+	seen := make(map[string]bool, len(env))
+
+	for _, ev := range env {
+		if !envNamePattern.MatchString(ev.Name) || ev.Name == TASK_ID_ENV {
+			return fmt.Errorf("%w: %q", ErrInvalidEnvName, ev.Name)
+		}
+
+		if seen[ev.Name] {
+			return fmt.Errorf("%w: %s", ErrDuplicateEnvName, ev.Name)
+		}
+		seen[ev.Name] = true
+
+		if !safeInCrontab(ev.Value) {
+			return fmt.Errorf("%w: %s", ErrInvalidEnvValue, ev.Name)
+		}
+	}
+
+	return nil
+}
+
+func ValidateWorkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) != dir || !safeInCrontab(dir) {
+		return fmt.Errorf("%w: %q", ErrInvalidWorkDir, dir)
+	}
+
+	return nil
+}
+
+// Cron turns '%' into a newline, and the rest would break reading the line back
+func safeInCrontab(s string) bool {
+	return !strings.ContainsAny(s, "\n\r%\x00") &&
+		!strings.Contains(s, " # ") &&
+		!strings.Contains(s, " root ")
+}
+
+// A copy of the entry with secret values replaced, safe to show to clients
+func (ctbE CrontabEntry) Redacted() CrontabEntry {
+	if len(ctbE.Env) == 0 {
+		return ctbE
+	}
+
+	env := make([]EnvVar, len(ctbE.Env))
+	for i, ev := range ctbE.Env {
+		if ev.Secret {
+			ev.Value = REDACTED
+		}
+
+		env[i] = ev
+	}
+	ctbE.Env = env
+
+	return ctbE
+}
+
+func (ctbE CrontabEntry) secretNames() []string {
+	var names []string
+	for _, ev := range ctbE.Env {
+		if ev.Secret {
+			names = append(names, ev.Name)
+		}
+	}
+
+	return names
+}
+
+// Wraps s in single quotes for the shell cron runs the command with
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Reads a word written by shellQuote off the front of s
+func shellUnquote(s string) (word, rest string, ok bool) {
+	var b strings.Builder
+
+	for strings.HasPrefix(s, "'") {
+		end := strings.Index(s[1:], "'")
+		if end == -1 {
+			return "", "", false
+		}
+
+		b.WriteString(s[1 : end+1])
+		s = s[end+2:]
+
+		if !strings.HasPrefix(s, `\''`) {
+			return b.String(), s, true
+		}
+
+		b.WriteString("'")
+		s = s[2:]
+	}
+
+	return "", "", false
+}
+
+// The part of the command line before the command itself: the working
+// directory, the task ID and the task's own variables
+func (ctbE CrontabEntry) commandPrefix() string {
+	var b strings.Builder
+
+	if ctbE.WorkDir != "" {
+		b.WriteString("cd " + shellQuote(ctbE.WorkDir) + " && ")
+	}
+
+	fmt.Fprintf(&b, "%s=%s ", TASK_ID_ENV, ctbE.ID)
+
+	for _, ev := range ctbE.Env {
+		b.WriteString(ev.Name + "=" + shellQuote(ev.Value) + " ")
+	}
+
+	return b.String()
+}
+
+// Undoes commandPrefix, returning what is left of the command line
+func parseCommandPrefix(cmd string) (workDir string, env []EnvVar, rest string, err error) {
+	if after, ok := strings.CutPrefix(cmd, "cd "); ok {
+		dir, after, ok := shellUnquote(after)
+		if !ok {
+			return "", nil, "", errors.New("unterminated working directory")
+		}
+
+		if after, ok = strings.CutPrefix(after, " && "); !ok {
+			return "", nil, "", errors.New("working directory is not followed by &&")
+		}
+
+		workDir, cmd = dir, after
+	}
+
+	if strings.HasPrefix(cmd, TASK_ID_ENV+"=") {
+		_, cmd, _ = strings.Cut(cmd, " ")
+	}
+
+	for {
+		name, after, found := strings.Cut(cmd, "=")
+		if !found || !envNamePattern.MatchString(name) || !strings.HasPrefix(after, "'") {
+			return workDir, env, cmd, nil
+		}
+
+		value, after, ok := shellUnquote(after)
+		if !ok {
+			return "", nil, "", fmt.Errorf("unterminated value for %s", name)
+		}
+
+		env = append(env, EnvVar{Name: name, Value: value})
+		cmd = strings.TrimPrefix(after, " ")
+	}
+}
@@ -0,0 +1,108 @@
+package crontab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envEntry(t *testing.T) CrontabEntry {
+	t.Helper()
+
+	var ctbE CrontabEntry
+	require.NoError(t, ctbE.Cron.UnmarshalText([]byte("*/5 * * * *")))
+	ctbE.ID, _ = uuid.NewV7()
+	ctbE.Cmd = "cli start-game 1"
+	ctbE.WorkDir = "/srv/coco games"
+	ctbE.Env = []EnvVar{
+		{Name: "RABBITMQ_HOST", Value: "rabbit:5672/"},
+		{Name: "GREETING", Value: "it's $HOME; `rm -rf /`"},
+		{Name: "API_TOKEN", Value: "s3cret", Secret: true},
+	}
+
+	return ctbE
+}
+
+func Test_ItRendersTaskEnvironmentQuoted(t *testing.T) {
+	t.Parallel()
+
+	ctbE := envEntry(t)
+	line := strings.TrimSuffix(ctbE.String(), "\n")
+
+	assert.Contains(t, line, ` root cd '/srv/coco games' && `+TASK_ID_ENV+`=`+ctbE.ID.String()+` RABBITMQ_HOST='rabbit:5672/' GREETING='it'\''s $HOME; `+"`rm -rf /`"+`' API_TOKEN='s3cret' /app/cli start-game 1 `)
+	assert.True(t, strings.HasSuffix(line, " "+secretTagPrefix+"API_TOKEN"))
+
+	parsed, err := NewCrontabEntryFromString(line)
+	require.NoError(t, err)
+	assert.Equal(t, ctbE.Cmd, parsed.Cmd)
+	assert.Equal(t, ctbE.WorkDir, parsed.WorkDir)
+	assert.Equal(t, ctbE.Env, parsed.Env)
+	assert.Equal(t, line, strings.TrimSuffix(parsed.String(), "\n"))
+}
+
+func Test_ItReadsBackEnvironmentOfTrackedTasks(t *testing.T) {
+	t.Parallel()
+
+	ctbE := envEntry(t)
+	ctbE.MaxRuns = 3
+
+	line := strings.TrimSuffix(ctbE.String(), "\n")
+	assert.Contains(t, line, "&& "+TASK_ID_ENV+"="+ctbE.ID.String()+" RABBITMQ_HOST=")
+
+	parsed, err := NewCrontabEntryFromString(line)
+	require.NoError(t, err)
+	assert.Equal(t, ctbE.Env, parsed.Env)
+	assert.Equal(t, ctbE.Cmd, parsed.Cmd)
+}
+
+func Test_ItRedactsSecretValues(t *testing.T) {
+	t.Parallel()
+
+	ctbE := envEntry(t)
+	redacted := ctbE.Redacted()
+
+	assert.Equal(t, REDACTED, redacted.Env[2].Value)
+	assert.Equal(t, "rabbit:5672/", redacted.Env[0].Value)
+	assert.Equal(t, "s3cret", ctbE.Env[2].Value, "the original entry is left alone")
+	assert.NotContains(t, redacted.String(), "s3cret")
+
+	rev := Revision{Entries: []CrontabEntry{ctbE}}
+	assert.NotContains(t, strings.Join(rev.Lines(), "\n"), "s3cret")
+}
+
+func Test_ItKeepsSecretsOutOfDriftReports(t *testing.T) {
+	t.Parallel()
+
+	ctbE := envEntry(t)
+	edited := strings.Replace(strings.TrimSpace(ctbE.String()), "s3cret", "leaked", 1)
+
+	items := diffCrontab([]CrontabEntry{ctbE}, []string{edited}, CrontabEntry.String)
+
+	require.Len(t, items, 1)
+	assert.Equal(t, DRIFT_MODIFIED, items[0].Kind)
+	assert.NotContains(t, items[0].Expected, "s3cret")
+	assert.NotContains(t, items[0].Actual, "leaked")
+}
+
+func Test_ItValidatesTaskEnvironment(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateEnv([]EnvVar{{Name: "FEATURE_X", Value: "on"}, {Name: "_Y", Value: ""}}))
+
+	assert.ErrorIs(t, ValidateEnv([]EnvVar{{Name: "1BAD", Value: "x"}}), ErrInvalidEnvName)
+	assert.ErrorIs(t, ValidateEnv([]EnvVar{{Name: "BAD-NAME", Value: "x"}}), ErrInvalidEnvName)
+	assert.ErrorIs(t, ValidateEnv([]EnvVar{{Name: TASK_ID_ENV, Value: "x"}}), ErrInvalidEnvName)
+	assert.ErrorIs(t, ValidateEnv([]EnvVar{{Name: "A", Value: "1"}, {Name: "A", Value: "2"}}), ErrDuplicateEnvName)
+
+	for _, value := range []string{"50%", "a\nb", "x # y", "x root y"} {
+		assert.ErrorIs(t, ValidateEnv([]EnvVar{{Name: "A", Value: value}}), ErrInvalidEnvValue, value)
+	}
+
+	assert.NoError(t, ValidateWorkDir(""))
+	assert.NoError(t, ValidateWorkDir("/srv/app"))
+	assert.ErrorIs(t, ValidateWorkDir("srv/app"), ErrInvalidWorkDir)
+	assert.ErrorIs(t, ValidateWorkDir("/srv/../etc"), ErrInvalidWorkDir)
+}
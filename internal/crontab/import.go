@@ -0,0 +1,193 @@
+package crontab
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+)
+
+const (
+	IMPORT_FORMAT_USER   = "user"   // min hour dom month dow command
+	IMPORT_FORMAT_SYSTEM = "system" // min hour dom month dow user command
+)
+
+const cronFieldCount = 5
+
+var envLinePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+type ImportedLine struct {
+	LineNo int          `json:"line_no"`
+	Source string       `json:"source"`
+	Entry  CrontabEntry `json:"entry"`
+	// The line the entry is written to the crontab as
+	Line string `json:"line"`
+}
+
+type RejectedLine struct {
+	LineNo int    `json:"line_no"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// What importing a crontab would do. Comments and blank lines are neither
+// accepted nor rejected.
+type ImportPlan struct {
+	Format   string         `json:"format"`
+	Accepted []ImportedLine `json:"accepted"`
+	Rejected []RejectedLine `json:"rejected"`
+}
+
+func (ip ImportPlan) Entries() []CrontabEntry {
+	out := make([]CrontabEntry, 0, len(ip.Accepted))
+	for _, item := range ip.Accepted {
+		out = append(out, item.Entry)
+	}
+
+	return out
+}
+
+// Reads a crontab that was not written by us and turns each job into a
+// managed entry with a fresh ID. Lines we cannot represent are rejected
+// with the reason, nothing is written.
+func ParseForeignCrontab(r io.Reader, format string) (ImportPlan, error) {
+	if format == "" {
+		format = IMPORT_FORMAT_USER
+	}
+
+	if format != IMPORT_FORMAT_USER && format != IMPORT_FORMAT_SYSTEM {
+		return ImportPlan{}, fmt.Errorf("unknown crontab format %q, expected %s or %s", format, IMPORT_FORMAT_USER, IMPORT_FORMAT_SYSTEM)
+	}
+
+	plan := ImportPlan{
+		Format:   format,
+		Accepted: []ImportedLine{},
+		Rejected: []RejectedLine{},
+	}
+
+	now := time.Now().UTC()
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		source := scanner.Text()
+		line := strings.TrimSpace(source)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ctbE, reason := importLine(line, format)
+		if reason != "" {
+			plan.Rejected = append(plan.Rejected, RejectedLine{LineNo: lineNo, Source: source, Reason: reason})
+			continue
+		}
+
+		id, err := uuid.NewV7()
+		if err != nil {
+			return ImportPlan{}, err
+		}
+
+		ctbE.ID = id
+		ctbE.Meta.CreatedAt = now
+		ctbE.Meta.UpdatedAt = now
+
+		plan.Accepted = append(plan.Accepted, ImportedLine{LineNo: lineNo, Source: source, Entry: ctbE})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return ImportPlan{}, err
+	}
+
+	return plan, nil
+}
+
+// Returns the entry for the line, or why it cannot be imported
+func importLine(line, format string) (CrontabEntry, string) {
+	if _, err := NewCrontabEntryFromString(line); err == nil {
+		return CrontabEntry{}, "already a managed entry, use migrate-crontab to adopt it"
+	}
+
+	if strings.HasPrefix(line, "@") {
+		macro, _, _ := strings.Cut(line, " ")
+		return CrontabEntry{}, fmt.Sprintf("%s is not supported, use a five field schedule instead", macro)
+	}
+
+	if envLinePattern.MatchString(line) {
+		return CrontabEntry{}, "environment variable lines are not supported"
+	}
+
+	want := cronFieldCount
+	if format == IMPORT_FORMAT_SYSTEM {
+		want++
+	}
+
+	fields, cmd := cutFields(line, want)
+	if len(fields) < want || cmd == "" {
+		if format == IMPORT_FORMAT_SYSTEM {
+			return CrontabEntry{}, "expected five schedule fields, a user and a command"
+		}
+
+		return CrontabEntry{}, "expected five schedule fields and a command"
+	}
+
+	if format == IMPORT_FORMAT_SYSTEM && fields[cronFieldCount] != "root" {
+		return CrontabEntry{}, fmt.Sprintf("runs as %q but managed tasks run as root", fields[cronFieldCount])
+	}
+
+	switch {
+	case strings.Contains(cmd, "%"):
+		return CrontabEntry{}, "command contains %, which cron treats as a newline"
+	case strings.Contains(cmd, " # "), strings.Contains(cmd, " root "):
+		return CrontabEntry{}, "command cannot be told apart from the managed line format"
+	}
+
+	p, err := parser.NewParser(parser.WithInput(strings.Join(fields[:cronFieldCount], " "), true))
+	if err != nil {
+		return CrontabEntry{}, err.Error()
+	}
+
+	cron, err := p.Parse()
+	if err == nil {
+		err = cron.Validate()
+	}
+
+	if err != nil {
+		return CrontabEntry{}, err.Error()
+	}
+
+	return CrontabEntry{Cron: cron, Cmd: cmd, Imported: true}, ""
+}
+
+// Splits off the first n whitespace separated fields and returns the rest of
+// the line untouched, so spacing inside the command survives
+func cutFields(line string, n int) ([]string, string) {
+	var fields []string
+	rest := line
+
+	for len(fields) < n {
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			break
+		}
+
+		end := strings.IndexAny(rest, " \t")
+		if end == -1 {
+			fields = append(fields, rest)
+			rest = ""
+			break
+		}
+
+		fields = append(fields, rest[:end])
+		rest = rest[end:]
+	}
+
+	return fields, strings.TrimSpace(rest)
+}
@@ -0,0 +1,88 @@
+package crontab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItPlansAnImportOfAUserCrontab(t *testing.T) {
+	t.Parallel()
+
+	legacy := strings.Join([]string{
+		"# nightly jobs",
+		"SHELL=/bin/bash",
+		"",
+		"*/15 * * * * /usr/local/bin/backup.sh  --full",
+		"@reboot /usr/bin/warmup",
+		"0 3 * * * echo 50% done",
+		"61 * * * * /usr/bin/never",
+		"* * *",
+	}, "\n")
+
+	plan, err := ParseForeignCrontab(strings.NewReader(legacy), IMPORT_FORMAT_USER)
+	require.NoError(t, err)
+
+	require.Len(t, plan.Accepted, 1)
+	accepted := plan.Accepted[0]
+	assert.Equal(t, 4, accepted.LineNo)
+	assert.Equal(t, "/usr/local/bin/backup.sh  --full", accepted.Entry.Cmd)
+	assert.Equal(t, uuid.Version(7), accepted.Entry.ID.Version())
+	assert.False(t, accepted.Entry.Meta.CreatedAt.IsZero())
+
+	// The rendered line must read back as the same task
+	parsed, err := NewCrontabEntryFromString(strings.TrimSuffix(accepted.Entry.String(), "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, accepted.Entry.Cmd, parsed.Cmd)
+	assert.Equal(t, accepted.Entry.ID, parsed.ID)
+
+	reasons := map[int]string{}
+	for _, item := range plan.Rejected {
+		reasons[item.LineNo] = item.Reason
+	}
+
+	assert.Len(t, reasons, 5)
+	assert.Contains(t, reasons[2], "environment variable")
+	assert.Contains(t, reasons[5], "@reboot")
+	assert.Contains(t, reasons[6], "%")
+	assert.NotEmpty(t, reasons[7])
+	assert.Contains(t, reasons[8], "five schedule fields")
+}
+
+func Test_ItPlansAnImportOfASystemCrontab(t *testing.T) {
+	t.Parallel()
+
+	legacy := "0 4 * * * root run-parts /etc/cron.daily\n0 5 * * * www-data /usr/bin/php cleanup.php\n"
+
+	plan, err := ParseForeignCrontab(strings.NewReader(legacy), IMPORT_FORMAT_SYSTEM)
+	require.NoError(t, err)
+
+	require.Len(t, plan.Accepted, 1)
+	imported := plan.Accepted[0].Entry
+	assert.Equal(t, "run-parts /etc/cron.daily", imported.Cmd)
+	assert.True(t, imported.Imported)
+
+	// Runs as written, not from /app
+	line := imported.String()
+	assert.Contains(t, line, " run-parts /etc/cron.daily"+cmdLogSeparator)
+	assert.NotContains(t, line, cmdPathPrefix)
+	assert.Contains(t, imported.serviceUnit(""), `ExecStart=`+unitShell+`"run-parts /etc/cron.daily"`)
+
+	parsed, err := NewCrontabEntryFromString(strings.TrimSuffix(line, "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, imported.Cmd, parsed.Cmd)
+	assert.True(t, parsed.Imported)
+
+	require.Len(t, plan.Rejected, 1)
+	assert.Contains(t, plan.Rejected[0].Reason, "www-data")
+}
+
+func Test_ItRejectsUnknownImportFormats(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseForeignCrontab(strings.NewReader(""), "bsd")
+	assert.Error(t, err)
+}
@@ -0,0 +1,498 @@
+package crontab
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/captainmango/coco-cron-parser/internal/config"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+const (
+	manifestPrefix       = "coco-"
+	manifestSuffix       = ".yaml"
+	kustomizationFile    = "kustomization.yaml"
+	manifestCLI          = cmdPathPrefix + "cli"
+	managedByLabel       = "app.kubernetes.io/managed-by"
+	taskIDLabel          = "coco/task-id"
+	metaAnnotation       = "coco/meta"
+	importedAnnotation   = "coco/imported"
+	containerName        = "task"
+	defaultImage         = "coco-task-manager:latest"
+	kubernetesMaxWeekday = 6 // Kubernetes counts weekdays 0-6, Sunday is 0
+)
+
+// Pods can't reach the task store, so nothing would count runs or remove a
+// one-shot task once it fired
+var ErrTaskNotRepresentable = errors.New("task cannot be represented by the schedule backend")
+
+// Commands made of plain words can be passed to the cli as container args
+// and read back unchanged. Anything else runs through a shell.
+var plainCLICommand = regexp.MustCompile(`^cli( [A-Za-z0-9_./:=@,+-]+)*$`)
+
+// Schedules tasks as Kubernetes CronJobs. Each task is rendered from the
+// TaskStore as coco-<id>.yaml in the manifest directory, alongside a
+// kustomization.yaml listing them, ready to be applied by kustomize or a
+// GitOps controller. Nothing talks to a cluster.
+type KubernetesManager struct {
+	storeBackend
+	mu                sync.Mutex
+	dir               string
+	image             string
+	namespace         string
+	timeZone          string
+	concurrencyPolicy string
+	secretName        string
+}
+
+type KubernetesManagerOptFn func(kM *KubernetesManager)
+
+func NewKubernetesManager(dir string, opts ...KubernetesManagerOptFn) *KubernetesManager {
+	kM := &KubernetesManager{
+		dir:               dir,
+		image:             defaultImage,
+		concurrencyPolicy: CONCURRENCY_ALLOW,
+	}
+
+	for _, fn := range opts {
+		fn(kM)
+	}
+
+	if kM.store == nil {
+		kM.store = NewFileTaskStore(filepath.Join(config.Config.StateDir, "tasks.json"))
+	}
+
+	kM.render = kM.renderManifests
+	kM.validate = kM.validateEntry
+
+	return kM
+}
+
+func WithKubernetesTaskStore(ts TaskStore) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		kM.store = ts
+	}
+}
+
+// The image the jobs run, it needs the cli at /app/cli
+func WithImage(image string) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		if image != "" {
+			kM.image = image
+		}
+	}
+}
+
+func WithNamespace(namespace string) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		kM.namespace = namespace
+	}
+}
+
+// The IANA time zone schedules are evaluated in. Left out, the cluster uses
+// the time zone of kube-controller-manager.
+func WithTimeZone(tz string) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		kM.timeZone = tz
+	}
+}
+
+// What Kubernetes does when a run is due while the last one is still going,
+// for tasks that do not set their own policy
+func WithConcurrencyPolicy(policy string) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		switch policy {
+		case CONCURRENCY_ALLOW, CONCURRENCY_FORBID, CONCURRENCY_REPLACE:
+			kM.concurrencyPolicy = policy
+		case "":
+		default:
+			slog.Error("unknown concurrency policy, using Allow",
+				slog.String("policy", policy),
+			)
+		}
+	}
+}
+
+// The Secret, managed outside coco, that secret variables are read from.
+// Each variable is the key of the same name. Tasks with secret variables are
+// rejected while it is not set.
+func WithSecretName(name string) KubernetesManagerOptFn {
+	return func(kM *KubernetesManager) {
+		kM.secretName = name
+	}
+}
+
+// Rejects what a CronJob can't do on its own. Limits on when or how often a
+// task runs are kept in the task store, which the pods can't reach.
+func (kM *KubernetesManager) validateEntry(ctbE CrontabEntry) error {
+	switch {
+	case ctbE.IsOneShot():
+		return fmt.Errorf("%w: one-shot tasks are not supported", ErrTaskNotRepresentable)
+	case ctbE.MaxRuns > 0:
+		return fmt.Errorf("%w: max_runs is not supported", ErrTaskNotRepresentable)
+	case ctbE.StartAt != nil || ctbE.EndAt != nil:
+		return fmt.Errorf("%w: start_at and end_at are not supported", ErrTaskNotRepresentable)
+	}
+
+	if kM.secretName == "" && slices.ContainsFunc(ctbE.Env, func(ev EnvVar) bool { return ev.Secret }) {
+		return fmt.Errorf("%w: secret variables need KUBERNETES_SECRET_NAME", ErrTaskNotRepresentable)
+	}
+
+	return validateKubernetesSchedule(ctbE.Cron)
+}
+
+// Kubernetes only accepts weekdays 0-6, while ours run 1-7 with Sunday as 7
+func validateKubernetesSchedule(cron parser.Cron) error {
+	for _, cf := range cron.Data {
+		if cf.FragmentType != parser.WEEKDAY || cf.Kind == parser.WILDCARD {
+			continue
+		}
+
+		values, err := cf.GetPossibleValues()
+		if err != nil {
+			return err
+		}
+
+		if slices.Max(values) > kubernetesMaxWeekday {
+			return fmt.Errorf("%w: %q uses weekday 7, write Sunday as a separate schedule or use *", ErrCronNotRepresentable, cron)
+		}
+	}
+
+	return nil
+}
+
+// The subset of the batch/v1 CronJob we write
+type kubernetesObject struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   kubernetesMeta `yaml:"metadata"`
+	Spec       *cronJobSpec   `yaml:"spec,omitempty"`
+}
+
+type kubernetesMeta struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type cronJobSpec struct {
+	Schedule          string `yaml:"schedule"`
+	TimeZone          string `yaml:"timeZone,omitempty"`
+	ConcurrencyPolicy string `yaml:"concurrencyPolicy"`
+	Suspend           bool   `yaml:"suspend"`
+	JobTemplate       struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					RestartPolicy string      `yaml:"restartPolicy"`
+					Containers    []container `yaml:"containers"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	} `yaml:"jobTemplate"`
+}
+
+type container struct {
+	Name       string         `yaml:"name"`
+	Image      string         `yaml:"image"`
+	Command    []string       `yaml:"command"`
+	Args       []string       `yaml:"args,omitempty"`
+	WorkingDir string         `yaml:"workingDir,omitempty"`
+	Env        []containerEnv `yaml:"env,omitempty"`
+}
+
+type containerEnv struct {
+	Name      string          `yaml:"name"`
+	Value     string          `yaml:"value,omitempty"`
+	ValueFrom *envValueSource `yaml:"valueFrom,omitempty"`
+}
+
+type envValueSource struct {
+	SecretKeyRef secretKeyRef `yaml:"secretKeyRef"`
+}
+
+type secretKeyRef struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+}
+
+func manifestName(id uuid.UUID) string {
+	return manifestPrefix + id.String()
+}
+
+// The CronJob for the entry. Secret variables are referenced from the
+// operator's Secret, their values are never written.
+func (kM *KubernetesManager) manifest(ctbE CrontabEntry) ([]byte, error) {
+	ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+	name := manifestName(ctbE.ID)
+
+	cronJob := kubernetesObject{
+		APIVersion: "batch/v1",
+		Kind:       "CronJob",
+		Metadata: kubernetesMeta{
+			Name:      name,
+			Namespace: kM.namespace,
+			Labels: map[string]string{
+				managedByLabel: "coco",
+				taskIDLabel:    ctbE.ID.String(),
+			},
+		},
+		Spec: &cronJobSpec{
+			Schedule:          ctbE.Cron.String(),
+			TimeZone:          kM.timeZone,
+			ConcurrencyPolicy: cmp.Or(ctbE.ConcurrencyPolicy, kM.concurrencyPolicy),
+			Suspend:           ctbE.Paused,
+		},
+	}
+
+	annotations := map[string]string{}
+	if !ctbE.Meta.IsZero() {
+		encoded, err := encodeMetadata(ctbE.Meta)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations[metaAnnotation] = encoded
+	}
+
+	if ctbE.Imported {
+		annotations[importedAnnotation] = "true"
+	}
+
+	if len(annotations) > 0 {
+		cronJob.Metadata.Annotations = annotations
+	}
+
+	task := container{
+		Name:       containerName,
+		Image:      kM.image,
+		WorkingDir: ctbE.WorkDir,
+	}
+
+	if !ctbE.Imported && plainCLICommand.MatchString(ctbE.Cmd) {
+		task.Command = []string{manifestCLI}
+		task.Args = strings.Fields(ctbE.Cmd)[1:]
+	} else {
+		task.Command = []string{"/bin/sh", "-c"}
+		task.Args = []string{ctbE.execCommand()}
+	}
+
+	task.Env = append(task.Env, containerEnv{Name: TASK_ID_ENV, Value: ctbE.ID.String()})
+
+	for _, ev := range ctbE.Env {
+		env := containerEnv{Name: ev.Name, Value: ev.Value}
+
+		if ev.Secret {
+			env.Value = ""
+			env.ValueFrom = &envValueSource{SecretKeyRef: secretKeyRef{Name: kM.secretName, Key: ev.Name}}
+		}
+
+		task.Env = append(task.Env, env)
+	}
+
+	podSpec := &cronJob.Spec.JobTemplate.Spec.Template.Spec
+	podSpec.RestartPolicy = "Never"
+	podSpec.Containers = []container{task}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(cronJob); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Reads an entry back from the CronJob written by manifest. Secret variables
+// come back without their values, which only the cluster has.
+func newCrontabEntryFromManifest(raw []byte) (CrontabEntry, error) {
+	var ctbE CrontabEntry
+	var cronJob *kubernetesObject
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	for {
+		var obj kubernetesObject
+		err := dec.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return ctbE, err
+		}
+
+		if obj.Kind == "CronJob" {
+			cronJob = &obj
+		}
+	}
+
+	if cronJob == nil || cronJob.Spec == nil {
+		return ctbE, errors.New("manifest has no CronJob")
+	}
+
+	containers := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers
+	if len(containers) != 1 {
+		return ctbE, errors.New("CronJob must run a single container")
+	}
+	task := containers[0]
+
+	var err error
+	if ctbE.ID, err = uuid.Parse(cronJob.Metadata.Labels[taskIDLabel]); err != nil {
+		return ctbE, fmt.Errorf("label %s: %w", taskIDLabel, err)
+	}
+
+	if err = ctbE.Cron.UnmarshalText([]byte(cronJob.Spec.Schedule)); err != nil {
+		return ctbE, err
+	}
+
+	ctbE.Paused = cronJob.Spec.Suspend
+	ctbE.WorkDir = task.WorkingDir
+
+	if encoded, ok := cronJob.Metadata.Annotations[metaAnnotation]; ok {
+		if ctbE.Meta, err = decodeMetadata(encoded); err != nil {
+			return ctbE, fmt.Errorf("annotation %s: %w", metaAnnotation, err)
+		}
+	}
+
+	ctbE.Imported = cronJob.Metadata.Annotations[importedAnnotation] == "true"
+
+	switch {
+	case slices.Equal(task.Command, []string{manifestCLI}):
+		ctbE.Cmd = strings.Join(append([]string{"cli"}, task.Args...), " ")
+	case slices.Equal(task.Command, []string{"/bin/sh", "-c"}) && len(task.Args) == 1 && ctbE.Imported:
+		ctbE.Cmd = task.Args[0]
+	case slices.Equal(task.Command, []string{"/bin/sh", "-c"}) && len(task.Args) == 1:
+		ctbE.Cmd = strings.TrimPrefix(task.Args[0], cmdPathPrefix)
+	default:
+		return ctbE, errors.New("container command was not written by coco")
+	}
+
+	for _, env := range task.Env {
+		switch {
+		case env.Name == TASK_ID_ENV:
+		case env.ValueFrom != nil:
+			ctbE.Env = append(ctbE.Env, EnvVar{Name: env.Name, Secret: true})
+		default:
+			ctbE.Env = append(ctbE.Env, EnvVar{Name: env.Name, Value: env.Value})
+		}
+	}
+
+	return ctbE, nil
+}
+
+// Parses every managed manifest in the directory
+func (kM *KubernetesManager) ReadManifests() ([]CrontabEntry, error) {
+	kM.mu.Lock()
+	defer kM.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(kM.dir, manifestPrefix+"*"+manifestSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []CrontabEntry
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		ctbE, err := newCrontabEntryFromManifest(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+
+		out = append(out, ctbE)
+	}
+
+	return out, nil
+}
+
+// Writes a manifest for every stored entry, removes those of tasks that are
+// gone and rewrites the kustomization to list what is left. Each file is
+// swapped in atomically.
+func (kM *KubernetesManager) renderManifests() error {
+	// Rendered under the store's lock, so an older render never lands last
+	return kM.store.View(func(entries []CrontabEntry) error {
+		kM.mu.Lock()
+		defer kM.mu.Unlock()
+
+		err := os.MkdirAll(kM.dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		wanted := make(map[string]bool, len(entries))
+		resources := []string{}
+
+		for _, ctbE := range entries {
+			raw, err := kM.manifest(ctbE)
+			if err != nil {
+				return err
+			}
+
+			file := manifestName(ctbE.ID) + manifestSuffix
+			if err = store.WriteFileAtomic(filepath.Join(kM.dir, file), raw, 0644); err != nil {
+				return err
+			}
+
+			wanted[file] = true
+			resources = append(resources, file)
+		}
+
+		existing, err := filepath.Glob(filepath.Join(kM.dir, manifestPrefix+"*"+manifestSuffix))
+		if err != nil {
+			return err
+		}
+
+		for _, path := range existing {
+			if wanted[filepath.Base(path)] {
+				continue
+			}
+
+			if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		slices.Sort(resources)
+		raw, err := yaml.Marshal(kustomization{
+			APIVersion: "kustomize.config.k8s.io/v1beta1",
+			Kind:       "Kustomization",
+			Resources:  resources,
+		})
+		if err != nil {
+			return err
+		}
+
+		return store.WriteFileAtomic(filepath.Join(kM.dir, kustomizationFile), raw, 0644)
+	})
+}
@@ -0,0 +1,177 @@
+package crontab
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+)
+
+func newTestKubernetesManager(t *testing.T, opts ...KubernetesManagerOptFn) (*KubernetesManager, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	opts = append([]KubernetesManagerOptFn{
+		WithKubernetesTaskStore(NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))),
+	}, opts...)
+
+	return NewKubernetesManager(dir, opts...), dir
+}
+
+func Test_ItWritesCronJobManifests(t *testing.T) {
+	kM, dir := newTestKubernetesManager(t,
+		WithImage("registry.local/coco:1.2.3"),
+		WithNamespace("games"),
+		WithTimeZone("Europe/London"),
+		WithConcurrencyPolicy(CONCURRENCY_FORBID),
+		WithSecretName("coco-tasks"),
+	)
+	id, _ := uuid.NewV7()
+
+	err := kM.WriteCrontabEntries([]CrontabEntry{{
+		ID:   id,
+		Cron: mustParseCron(t, "*/5 * * * *"),
+		Cmd:  "cli start-game 123",
+		Env:  []EnvVar{{Name: "TOKEN", Value: "s3cret", Secret: true}},
+	}})
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(filepath.Join(dir, manifestName(id)+manifestSuffix))
+	require.NoError(t, err)
+
+	var cronJob kubernetesObject
+	require.NoError(t, yaml.Unmarshal(raw, &cronJob))
+
+	assert.Equal(t, "CronJob", cronJob.Kind)
+	assert.Equal(t, "games", cronJob.Metadata.Namespace)
+	assert.Equal(t, "*/5 * * * *", cronJob.Spec.Schedule)
+	assert.Equal(t, "Europe/London", cronJob.Spec.TimeZone)
+	assert.Equal(t, CONCURRENCY_FORBID, cronJob.Spec.ConcurrencyPolicy)
+
+	task := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.local/coco:1.2.3", task.Image)
+	assert.Equal(t, []string{"/app/cli"}, task.Command)
+	assert.Equal(t, []string{"start-game", "123"}, task.Args)
+	assert.Equal(t, containerEnv{Name: TASK_ID_ENV, Value: id.String()}, task.Env[0])
+	assert.Empty(t, task.Env[1].Value, "secret values stay out of the CronJob")
+	assert.Equal(t, secretKeyRef{Name: "coco-tasks", Key: "TOKEN"}, task.Env[1].ValueFrom.SecretKeyRef)
+	assert.NotContains(t, string(raw), "s3cret")
+	assert.NotContains(t, string(raw), "kind: Secret")
+
+	kustomization, err := os.ReadFile(filepath.Join(dir, kustomizationFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(kustomization), "- "+manifestName(id)+manifestSuffix)
+}
+
+func Test_ItPrefersTheTasksOwnConcurrencyPolicy(t *testing.T) {
+	kM, dir := newTestKubernetesManager(t, WithConcurrencyPolicy(CONCURRENCY_FORBID))
+	own, _ := uuid.NewV7()
+	inherited, _ := uuid.NewV7()
+
+	err := kM.WriteCrontabEntries([]CrontabEntry{
+		{ID: own, Cron: mustParseCron(t, "*/5 * * * *"), Cmd: "cli start-game 1", ConcurrencyPolicy: CONCURRENCY_REPLACE},
+		{ID: inherited, Cron: mustParseCron(t, "*/5 * * * *"), Cmd: "cli start-game 2"},
+	})
+	require.NoError(t, err)
+
+	for id, expected := range map[uuid.UUID]string{own: CONCURRENCY_REPLACE, inherited: CONCURRENCY_FORBID} {
+		raw, err := os.ReadFile(filepath.Join(dir, manifestName(id)+manifestSuffix))
+		require.NoError(t, err)
+
+		var cronJob kubernetesObject
+		require.NoError(t, yaml.Unmarshal(raw, &cronJob))
+		assert.Equal(t, expected, cronJob.Spec.ConcurrencyPolicy)
+	}
+}
+
+func Test_ItReadsManifestsBackAsTheSameEntries(t *testing.T) {
+	kM, dir := newTestKubernetesManager(t, WithSecretName("coco-tasks"))
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	idOne, _ := uuid.NewV7()
+	idTwo, _ := uuid.NewV7()
+
+	err := kM.WriteCrontabEntries([]CrontabEntry{
+		{
+			ID:      idOne,
+			Cron:    mustParseCron(t, "0 9-17 * * 1-5"),
+			Cmd:     "cli start-game 123",
+			Meta:    Metadata{Name: "game", CreatedAt: created},
+			Env:     []EnvVar{{Name: "PLAIN", Value: "a value"}, {Name: "TOKEN", Value: "s3cret", Secret: true}},
+			WorkDir: "/srv/coco",
+		},
+		{
+			ID:   idTwo,
+			Cron: mustParseCron(t, "0 12 1 6 *"),
+			Cmd:  `./cleanup --dry-run "all" | tee out`,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, kM.PauseCrontabEntryByID(idOne))
+
+	read, err := kM.ReadManifests()
+	require.NoError(t, err)
+	require.Len(t, read, 2)
+
+	stored, err := kM.GetAllCrontabEntries()
+	require.NoError(t, err)
+
+	for i := range read {
+		assert.Equal(t, stored[i].Cron.String(), read[i].Cron.String())
+		read[i].Cron, stored[i].Cron = parser.Cron{}, parser.Cron{}
+	}
+
+	// Only the cluster knows the secret's value
+	stored[0].Env[1].Value = ""
+
+	assert.Equal(t, stored, read)
+	assert.True(t, read[0].Paused)
+
+	require.NoError(t, kM.RemoveCrontabEntryByID(idTwo))
+	assert.NoFileExists(t, filepath.Join(dir, manifestName(idTwo)+manifestSuffix))
+
+	kustomization, err := os.ReadFile(filepath.Join(dir, kustomizationFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(kustomization), idTwo.String())
+}
+
+func Test_ItRejectsSundayWrittenAsSeven(t *testing.T) {
+	kM, _ := newTestKubernetesManager(t)
+	id, _ := uuid.NewV7()
+
+	err := kM.WriteCrontabEntries([]CrontabEntry{{ID: id, Cron: mustParseCron(t, "0 8 * * 5-7"), Cmd: "cli start-game 1"}})
+	assert.ErrorIs(t, err, ErrCronNotRepresentable)
+
+	err = kM.WriteCrontabEntries([]CrontabEntry{{ID: id, Cron: mustParseCron(t, "0 8 * * 1-5"), Cmd: "cli start-game 1"}})
+	assert.NoError(t, err)
+}
+
+func Test_ItRejectsTasksThePodsCannotKeepTrackOf(t *testing.T) {
+	kM, _ := newTestKubernetesManager(t)
+
+	runAt := time.Now().Add(time.Hour)
+	endAt := time.Now().Add(24 * time.Hour)
+
+	for name, ctbE := range map[string]CrontabEntry{
+		"one-shot":        {RunAt: &runAt},
+		"max_runs":        {MaxRuns: 3},
+		"end_at":          {EndAt: &endAt},
+		"secret, no name": {Env: []EnvVar{{Name: "TOKEN", Value: "s3cret", Secret: true}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctbE.ID, _ = uuid.NewV7()
+			ctbE.Cron = mustParseCron(t, "*/5 * * * *")
+			ctbE.Cmd = "cli start-game 1"
+
+			err := kM.WriteCrontabEntries([]CrontabEntry{ctbE})
+			assert.ErrorIs(t, err, ErrTaskNotRepresentable)
+		})
+	}
+}
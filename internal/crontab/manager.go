@@ -2,14 +2,35 @@ package crontab
 
 import (
 	"errors"
+	"log/slog"
+	"path/filepath"
 
 	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
+)
+
+const (
+	cronFormat      = "%s root %s%s" + cmdLogSeparator + "%s # %s\n" // cron, env, command, log, trailer
+	cmdPathPrefix   = "/app/"
+	cmdLogSeparator = " 2>&1 | tee -a "
+	sharedLogFile   = "/tmp/log" // where output goes without a task log dir
+	pausedPrefix    = "#paused# "
+	metaTagPrefix   = "meta="
+	onceTagPrefix   = "once="
+	secretTagPrefix = "secret="  // names the env vars whose values are secret
+	importedTag     = "imported" // the command runs as written, not from /app
+	sigTagPrefix    = "sig="     // HMAC of the rest of the line, always the last tag
 )
 
-const cronFormat = "%s root /app/%s 2>&1 | tee -a /tmp/log # %s\n"
+// Set on the command line of tasks the CLI has to look after when they fire,
+// e.g. one-shot tasks that remove themselves or tasks with a run limit
+const TASK_ID_ENV = "COCO_TASK_ID"
 
 var (
 	errCrontabFileNotSet = errors.New("crontab file not set")
+
+	ErrCrontabEntryNotFound = errors.New("did not find crontab entry")
 )
 
 type CrontabHandler interface {
@@ -17,4 +38,50 @@ type CrontabHandler interface {
 	GetAllCrontabEntries() ([]CrontabEntry, error)
 	GetCrontabEntryByID(uuid.UUID) (CrontabEntry, error)
 	RemoveCrontabEntryByID(uuid.UUID) error
+	ReplaceCrontabEntries([]CrontabEntry) error
+	PauseCrontabEntryByID(uuid.UUID) error
+	ResumeCrontabEntryByID(uuid.UUID) error
+	// Changes an entry under the store's lock. An error from fn leaves it as
+	// it was.
+	UpdateCrontabEntryByID(uuid.UUID, func(ctbE *CrontabEntry) error) error
+	// Changes, adds or removes entries under the store's lock, in a single
+	// rewrite. An error from fn leaves them as they were.
+	UpdateCrontabEntries(fn func(entries *[]CrontabEntry) error) error
+}
+
+// Implemented by handlers that write crontab lines, so callers can show the
+// exact line an entry is written as
+type LineRenderer interface {
+	RenderLine(CrontabEntry) string
+}
+
+// Implemented by handlers that can adopt the managed lines already sitting in
+// a crontab file, e.g. when moving to the task store for the first time
+type CrontabImporter interface {
+	ImportCrontabFile() (int, error)
+}
+
+// Where a task's output goes: its own log in logDir, or the shared log
+func taskLogPath(logDir string, id uuid.UUID) string {
+	if logDir == "" {
+		return sharedLogFile
+	}
+
+	return tasklog.Path(logDir, id)
+}
+
+// Cron and systemd do not run from our working directory, so the task log
+// dir is made absolute before it is written anywhere
+func absLogDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		slog.Error(err.Error())
+		return dir
+	}
+
+	return abs
 }
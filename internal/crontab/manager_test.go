@@ -3,7 +3,10 @@ package crontab
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -11,10 +14,12 @@ import (
 
 	"github.com/captainmango/coco-cron-parser/internal/config"
 	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
 	"github.com/captainmango/coco-cron-parser/internal/utils"
 )
 
-const expectedCrontabFormat = cronFormat
+const expectedCrontabFormat = "%[1]s root " + TASK_ID_ENV + "=%[3]s /app/%[2]s 2>&1 | tee -a /tmp/log # %[3]s\n"
 
 type CronTabManagerTestSuite struct {
 	suite.Suite
@@ -30,8 +35,11 @@ func Test_RunManagerTestSuite(t *testing.T) {
 func (s *CronTabManagerTestSuite) SetupTest() {
 	config.BootstrapConfig()
 	config.Config.CrontabFile = utils.BasePath("e2e/storage/crontab")
+	config.Config.CrontabSigningKey = ""
 	s.cron = exampleTestCron()
-	s.cM = &CrontabManager{}
+	s.cM = NewCrontabManager(
+		WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json"))),
+	)
 }
 
 func (s *CronTabManagerTestSuite) TearDownTest() {
@@ -55,6 +63,10 @@ func (s *CronTabManagerTestSuite) Test_ItWritesToCrontabFile() {
 	out := readFromPath(s.T(), config.Config.CrontabFile)
 
 	assert.Equal(s.T(), expected, out)
+
+	info, err := os.Stat(config.Config.CrontabFile)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), store.PRIVATE_FILE_MODE, info.Mode().Perm(), "secret values are only readable by root")
 }
 
 func (s *CronTabManagerTestSuite) Test_ItGetsCrontabsInFile() {
@@ -101,6 +113,373 @@ func (s *CronTabManagerTestSuite) Test_ItDeletesCrontabByID() {
 	assert.Len(s.T(), entries, 1)
 }
 
+func (s *CronTabManagerTestSuite) Test_ItReplacesAllCrontabs() {
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne))
+	assert.NoError(s.T(), err)
+
+	replacement := fixtureCrontabs(fakeUuIDTwo)
+	replacement[0].Paused = true
+
+	err = s.cM.ReplaceCrontabEntries(replacement)
+	assert.NoError(s.T(), err)
+
+	entries, err := s.cM.GetAllCrontabEntries()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 1)
+	assert.Equal(s.T(), fakeUuIDTwo, entries[0].ID)
+	assert.True(s.T(), entries[0].Paused)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItPausesCrontabByID() {
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne, fakeUuIDTwo))
+	assert.NoError(s.T(), err)
+
+	err = s.cM.PauseCrontabEntryByID(fakeUuIDTwo)
+	assert.NoError(s.T(), err)
+
+	lines := strings.Split(readFromPath(s.T(), config.Config.CrontabFile), "\n")
+	assert.Equal(s.T(), strings.TrimSuffix(fmt.Sprintf(expectedCrontabFormat, s.cron, "./test-command", fakeUuIDOne.String()), "\n"), lines[0])
+	assert.True(s.T(), strings.HasPrefix(lines[1], pausedPrefix+s.cron.String()))
+
+	ctbE, err := s.cM.GetCrontabEntryByID(fakeUuIDTwo)
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), ctbE.Paused)
+	assert.Equal(s.T(), "./test-command", ctbE.Cmd)
+	assert.False(s.T(), ctbE.Meta.UpdatedAt.IsZero())
+}
+
+func (s *CronTabManagerTestSuite) Test_ItResumesCrontabByID() {
+	fakeUuID, _ := uuid.NewUUID()
+
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuID))
+	assert.NoError(s.T(), err)
+
+	assert.NoError(s.T(), s.cM.PauseCrontabEntryByID(fakeUuID))
+	assert.NoError(s.T(), s.cM.ResumeCrontabEntryByID(fakeUuID))
+
+	out := readFromPath(s.T(), config.Config.CrontabFile)
+	assert.True(s.T(), strings.HasPrefix(out, s.cron.String()+" root "))
+
+	ctbE, err := s.cM.GetCrontabEntryByID(fakeUuID)
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), ctbE.Paused)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItRoundTripsMetadata() {
+	fakeUuID, _ := uuid.NewUUID()
+	createdAt := time.Date(2026, 11, 2, 19, 30, 0, 0, time.UTC)
+
+	entries := fixtureCrontabs(fakeUuID)
+	entries[0].Meta = Metadata{
+		Name:        "Final table",
+		Description: "Starts the # final game",
+		Owner:       "tournaments",
+		Tags:        []string{"finals", "room 123"},
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+
+	err := s.cM.WriteCrontabEntries(entries)
+	assert.NoError(s.T(), err)
+
+	out := readFromPath(s.T(), config.Config.CrontabFile)
+	assert.Equal(s.T(), 1, strings.Count(out, " # "))
+
+	ctbE, err := s.cM.GetCrontabEntryByID(fakeUuID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), entries[0].Meta, ctbE.Meta)
+	assert.Equal(s.T(), "./test-command", ctbE.Cmd)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItErrorsWhenPausingMissingEntry() {
+	fakeUuID, _ := uuid.NewUUID()
+
+	err := s.cM.PauseCrontabEntryByID(fakeUuID)
+
+	assert.ErrorIs(s.T(), err, ErrCrontabEntryNotFound)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItImportsExistingCrontabLines() {
+	fakeUuIDOne, _ := uuid.Parse("00000000-0000-0000-0000-000000000001")
+	fakeUuIDTwo, _ := uuid.Parse("00000000-0000-0000-0000-000000000002")
+
+	legacy := fmt.Sprintf(expectedCrontabFormat, "*/5 * * * *", "cli start-game 1", fakeUuIDOne) +
+		"\n" +
+		pausedPrefix + fmt.Sprintf(expectedCrontabFormat, "0 20 * * *", "cli start-game 2", fakeUuIDTwo)
+
+	err := os.WriteFile(config.Config.CrontabFile, []byte(legacy), 0644)
+	assert.NoError(s.T(), err)
+
+	importer := s.cM.(CrontabImporter)
+	imported, err := importer.ImportCrontabFile()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, imported)
+
+	entries, err := s.cM.GetAllCrontabEntries()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 2)
+	assert.Equal(s.T(), fakeUuIDOne, entries[0].ID)
+	assert.Equal(s.T(), "cli start-game 1", entries[0].Cmd)
+	assert.Equal(s.T(), "*/5 * * * *", entries[0].Cron.String())
+	assert.True(s.T(), entries[1].Paused)
+
+	imported, err = importer.ImportCrontabFile()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 0, imported)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItRegeneratesCrontabFromStore() {
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne, fakeUuIDTwo))
+	assert.NoError(s.T(), err)
+
+	// Someone clobbers the file, the next write puts back everything we know about
+	resetFileFromPath(s.T(), config.Config.CrontabFile)
+
+	err = s.cM.PauseCrontabEntryByID(fakeUuIDOne)
+	assert.NoError(s.T(), err)
+
+	entries, err := s.cM.(*CrontabManager).ReadCrontabFile()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 2)
+	assert.True(s.T(), entries[0].Paused)
+	assert.Equal(s.T(), fakeUuIDTwo, entries[1].ID)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItSendsEachTasksOutputToItsOwnLog() {
+	logDir := filepath.Join(s.T().TempDir(), "logs")
+	cM := NewCrontabManager(
+		WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json"))),
+		WithTaskLogDir(logDir),
+	)
+
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+
+	err := cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne, fakeUuIDTwo))
+	assert.NoError(s.T(), err)
+
+	out := readFromPath(s.T(), config.Config.CrontabFile)
+	assert.Contains(s.T(), out, " 2>&1 | tee -a '"+tasklog.Path(logDir, fakeUuIDOne)+"' # "+fakeUuIDOne.String())
+	assert.Contains(s.T(), out, " 2>&1 | tee -a '"+tasklog.Path(logDir, fakeUuIDTwo)+"' # "+fakeUuIDTwo.String())
+	assert.DirExists(s.T(), logDir)
+
+	report, err := cM.DetectDrift()
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), report.InSync(), "drift is checked against the lines as this manager writes them")
+
+	entries, err := NewCrontabManager(WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json")))).ReadCrontabFile()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 2)
+	assert.Equal(s.T(), "./test-command", entries[0].Cmd)
+
+	err = os.WriteFile(config.Config.CrontabFile, []byte(strings.Replace(out, "./test-command", "./edited", 1)), 0644)
+	assert.NoError(s.T(), err)
+
+	report, err = cM.DetectDrift()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), report.Items, 1) {
+		assert.Equal(s.T(), DRIFT_MODIFIED, report.Items[0].Kind)
+		assert.Contains(s.T(), report.Items[0].Expected, tasklog.Path(logDir, report.Items[0].ID), "the expected line is the one this manager writes")
+	}
+}
+
+func (s *CronTabManagerTestSuite) Test_ItDetectsAndRepairsDrift() {
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+	fakeUuIDThree, _ := uuid.NewUUID()
+
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne, fakeUuIDTwo))
+	assert.NoError(s.T(), err)
+
+	// Hand edits: one line changed, one dropped, one foreign job and one
+	// unknown managed line added
+	hand := fmt.Sprintf(
+		"0 3 * * * root /app/./test-command 2>&1 | tee -a /tmp/log # %s\n"+
+			"* * * * * root /app/./test-command 2>&1 | tee -a /tmp/log # %s\n"+
+			"@reboot /usr/bin/something\n",
+		fakeUuIDOne, fakeUuIDThree,
+	)
+	err = os.WriteFile(config.Config.CrontabFile, []byte(hand), 0644)
+	assert.NoError(s.T(), err)
+
+	reconciler := s.cM.(DriftReconciler)
+	report, err := reconciler.DetectDrift()
+	assert.NoError(s.T(), err)
+
+	kinds := map[DriftKind][]uuid.UUID{}
+	for _, item := range report.Items {
+		kinds[item.Kind] = append(kinds[item.Kind], item.ID)
+	}
+
+	assert.Equal(s.T(), []uuid.UUID{fakeUuIDOne}, kinds[DRIFT_MODIFIED])
+	assert.Equal(s.T(), []uuid.UUID{fakeUuIDThree, uuid.Nil}, kinds[DRIFT_ADDED])
+	assert.Equal(s.T(), []uuid.UUID{fakeUuIDTwo}, kinds[DRIFT_REMOVED])
+	assert.False(s.T(), report.Repaired)
+
+	report, err = reconciler.RepairDrift()
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), report.Repaired)
+
+	report, err = reconciler.DetectDrift()
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), report.InSync())
+}
+
+func (s *CronTabManagerTestSuite) Test_ItShardsEntriesAcrossFiles() {
+	dir := s.T().TempDir()
+	config.Config.CrontabFile = filepath.Join(dir, "root")
+
+	// Lines written before sharding was turned on are cleared out
+	err := os.WriteFile(config.Config.CrontabFile, []byte("* * * * * root /app/cli old 2>&1 | tee -a /tmp/log # "+uuid.NewString()+"\n"), 0644)
+	assert.NoError(s.T(), err)
+
+	cM := NewCrontabManager(
+		WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json"))),
+		WithShards(SHARD_BY_COMMAND),
+	)
+
+	ids := make([]uuid.UUID, 3)
+	for i := range ids {
+		ids[i], _ = uuid.NewV7()
+	}
+
+	err = cM.WriteCrontabEntries([]CrontabEntry{
+		{ID: ids[0], Cron: s.cron, Cmd: "cli start-game 1"},
+		{ID: ids[1], Cron: s.cron, Cmd: "cli start-game 2"},
+		{ID: ids[2], Cron: s.cron, Cmd: "cli end.game 3"},
+	})
+	assert.NoError(s.T(), err)
+
+	startGame := readFromPath(s.T(), filepath.Join(dir, "coco-start-game"))
+	assert.Contains(s.T(), startGame, ids[0].String())
+	assert.Contains(s.T(), startGame, ids[1].String())
+	assert.Contains(s.T(), readFromPath(s.T(), filepath.Join(dir, "coco-end-game")), ids[2].String())
+	assert.Empty(s.T(), readFromPath(s.T(), config.Config.CrontabFile))
+
+	for _, id := range ids {
+		ctbE, err := cM.GetCrontabEntryByID(id)
+		assert.NoError(s.T(), err)
+		assert.Equal(s.T(), id, ctbE.ID)
+	}
+
+	entries, err := cM.ReadCrontabFile()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 3)
+
+	report, err := cM.DetectDrift()
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), report.InSync())
+
+	err = cM.RemoveCrontabEntryByID(ids[2])
+	assert.NoError(s.T(), err)
+	assert.NoFileExists(s.T(), filepath.Join(dir, "coco-end-game"))
+}
+
+func (s *CronTabManagerTestSuite) Test_ItShardsEntriesByTenant() {
+	dir := s.T().TempDir()
+	config.Config.CrontabFile = filepath.Join(dir, "root")
+
+	cM := NewCrontabManager(
+		WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json"))),
+		WithShards(SHARD_BY_TENANT),
+	)
+
+	owned, _ := uuid.NewV7()
+	unowned, _ := uuid.NewV7()
+
+	err := cM.WriteCrontabEntries([]CrontabEntry{
+		{ID: owned, Cron: s.cron, Cmd: "cli start-game 1", Meta: Metadata{Owner: "Tournaments Team"}},
+		{ID: unowned, Cron: s.cron, Cmd: "cli start-game 2"},
+	})
+	assert.NoError(s.T(), err)
+
+	assert.Contains(s.T(), readFromPath(s.T(), filepath.Join(dir, "coco-tournaments-team")), owned.String())
+	assert.Contains(s.T(), readFromPath(s.T(), filepath.Join(dir, "coco-default")), unowned.String())
+	assert.NoFileExists(s.T(), config.Config.CrontabFile, "the single crontab is not created")
+
+	// TearDownTest truncates the crontab file
+	assert.NoError(s.T(), os.WriteFile(config.Config.CrontabFile, nil, 0644))
+}
+
+func (s *CronTabManagerTestSuite) Test_ItQuarantinesLinesThatFailTheirSignature() {
+	config.Config.CrontabSigningKey = "test-key"
+	quarantinePath := filepath.Join(s.T().TempDir(), "quarantine.json")
+
+	var alerts []TamperedLine
+	cM := NewCrontabManager(
+		WithTaskStore(NewFileTaskStore(filepath.Join(s.T().TempDir(), "tasks.json"))),
+		WithQuarantine(quarantinePath, func(line TamperedLine) {
+			alerts = append(alerts, line)
+		}),
+	)
+
+	fakeUuIDOne, _ := uuid.NewUUID()
+	fakeUuIDTwo, _ := uuid.NewUUID()
+	err := cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne, fakeUuIDTwo))
+	assert.NoError(s.T(), err)
+
+	signed := readFromPath(s.T(), config.Config.CrontabFile)
+	assert.Contains(s.T(), signed, " "+sigTagPrefix)
+
+	// One line edited to run something else and one injected
+	tampered := strings.Replace(signed, "/app/./test-command", "/app/./evil", 1) +
+		"* * * * * root /usr/bin/evil\n"
+	err = os.WriteFile(config.Config.CrontabFile, []byte(tampered), 0644)
+	assert.NoError(s.T(), err)
+
+	entries, err := cM.GetAllCrontabEntries()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), entries, 2)
+	assert.Equal(s.T(), tampered, readFromPath(s.T(), config.Config.CrontabFile), "reading the tasks leaves the crontab alone")
+	assert.Empty(s.T(), alerts)
+
+	found, err := cM.CheckSignatures()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), found, 2)
+
+	assert.Equal(s.T(), signed, readFromPath(s.T(), config.Config.CrontabFile), "the crontab is rewritten from the store")
+	assert.Len(s.T(), alerts, 2)
+	assert.Equal(s.T(), fakeUuIDOne, alerts[0].ID)
+	assert.Equal(s.T(), ErrInvalidSignature.Error(), alerts[0].Reason)
+	assert.Equal(s.T(), uuid.Nil, alerts[1].ID)
+	assert.Equal(s.T(), ErrUnsignedLine.Error(), alerts[1].Reason)
+
+	quarantined, err := store.NewJSONFile[[]TamperedLine](quarantinePath).Load()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), quarantined, 2)
+
+	remaining, err := cM.CheckSignatures()
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), remaining)
+}
+
+func (s *CronTabManagerTestSuite) Test_ItSignsLinesWrittenBeforeSigningWasEnabled() {
+	fakeUuIDOne, _ := uuid.NewUUID()
+	err := s.cM.WriteCrontabEntries(fixtureCrontabs(fakeUuIDOne))
+	assert.NoError(s.T(), err)
+	assert.NotContains(s.T(), readFromPath(s.T(), config.Config.CrontabFile), sigTagPrefix)
+
+	config.Config.CrontabSigningKey = "test-key"
+
+	tampered, err := s.cM.(*CrontabManager).CheckSignatures()
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), tampered)
+
+	lines, err := s.cM.(*CrontabManager).ReadCrontabLines()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), lines, 1)
+	assert.NoError(s.T(), VerifyLine(lines[0], []byte("test-key")))
+}
+
 func exampleTestCron() parser.Cron {
 	return parser.Cron{
 		Data: []parser.CronFragment{
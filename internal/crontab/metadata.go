@@ -0,0 +1,53 @@
+package crontab
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Descriptive information about a task. None of it affects when or how the
+// task runs.
+type Metadata struct {
+	Name        string    `json:"name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+func (m Metadata) IsZero() bool {
+	return m.Name == "" &&
+		m.Description == "" &&
+		m.Owner == "" &&
+		len(m.Tags) == 0 &&
+		m.CreatedAt.IsZero() &&
+		m.UpdatedAt.IsZero()
+}
+
+// Metadata lives in the trailing comment of the crontab line, so it has to be
+// a single token without spaces or '#'. URL safe base64 of the JSON is both.
+func encodeMetadata(m Metadata) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeMetadata(encoded string) (Metadata, error) {
+	var m Metadata
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return m, err
+	}
+
+	if err = json.Unmarshal(data, &m); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
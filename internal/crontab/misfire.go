@@ -0,0 +1,129 @@
+package crontab
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+// What happens to the runs a task missed while nothing was firing it, e.g.
+// because the container was down
+const (
+	// The missed runs are reported and recorded as skipped
+	MISFIRE_SKIP = "skip"
+	// Only the latest missed run is run
+	MISFIRE_RUN_ONCE = "run_once"
+	// Every missed run is run, oldest first
+	MISFIRE_RUN_ALL = "run_all"
+)
+
+var ErrInvalidMisfirePolicy = errors.New("misfire policy must be skip, run_once or run_all")
+
+// Empty is valid and skips missed runs
+func ValidateMisfirePolicy(policy string) error {
+	switch policy {
+	case "", MISFIRE_SKIP, MISFIRE_RUN_ONCE, MISFIRE_RUN_ALL:
+		return nil
+	}
+
+	return ErrInvalidMisfirePolicy
+}
+
+// A time a task was due while nothing was firing it
+type MissedRun struct {
+	Task        CrontabEntry
+	ScheduledAt time.Time
+	// Whether the task's misfire policy runs it now
+	CatchUp bool
+}
+
+// Whether the task runs one of our cli commands. Only their runs are tracked,
+// anything else is left to cron.
+func (ctbE CrontabEntry) RunsCLI() bool {
+	fields := strings.Fields(ctbE.Cmd)
+	return len(fields) > 1 && fields[0] == "cli"
+}
+
+// The times the task was due after since, up to and including until, in
+// since's location. Times outside its validity window are left out.
+func (ctbE CrontabEntry) FiresBetween(since, until time.Time) []time.Time {
+	var fires []time.Time
+
+	for t := since; ; {
+		next, ok := ctbE.Cron.Next(t)
+		if !ok || next.After(until) {
+			return fires
+		}
+
+		if (ctbE.StartAt == nil || !next.Before(*ctbE.StartAt)) && (ctbE.EndAt == nil || next.Before(*ctbE.EndAt)) {
+			fires = append(fires, next)
+		}
+
+		t = next
+	}
+}
+
+// Remembers when each task last fired, so the runs it missed while nothing
+// was firing it can be worked out
+type FireLog struct {
+	file *store.JSONFile[map[uuid.UUID]time.Time]
+}
+
+func NewFireLog(path string) *FireLog {
+	return &FireLog{
+		file: store.NewJSONFile[map[uuid.UUID]time.Time](path),
+	}
+}
+
+// Moves the task's last fire forward to at. Earlier times are ignored, so a
+// late catch-up run does not undo a newer fire.
+func (fl *FireLog) Record(id uuid.UUID, at time.Time) error {
+	return fl.file.Update(func(fires *map[uuid.UUID]time.Time) error {
+		if *fires == nil {
+			*fires = map[uuid.UUID]time.Time{}
+		}
+
+		if last, ok := (*fires)[id]; !ok || at.After(last) {
+			(*fires)[id] = at.UTC()
+		}
+
+		return nil
+	})
+}
+
+// The last fire of every task. ok is false when nothing has been recorded
+// yet, e.g. the first time the service starts with the log.
+func (fl *FireLog) All() (fires map[uuid.UUID]time.Time, ok bool, err error) {
+	fires, err = fl.file.Load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return fires, fires != nil, nil
+}
+
+// Hands fn the last fire of every task while holding the log's lock, so a
+// run recorded by another process is not lost, and saves the map fn leaves
+// behind. ok is false when nothing has been recorded yet.
+func (fl *FireLog) Update(fn func(fires map[uuid.UUID]time.Time, ok bool) error) error {
+	return fl.file.Update(func(fires *map[uuid.UUID]time.Time) error {
+		ok := *fires != nil
+		if !ok {
+			*fires = map[uuid.UUID]time.Time{}
+		}
+
+		if err := fn(*fires, ok); err != nil {
+			return err
+		}
+
+		for id, at := range *fires {
+			(*fires)[id] = at.UTC()
+		}
+
+		return nil
+	})
+}
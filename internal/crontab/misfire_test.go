@@ -0,0 +1,67 @@
+package crontab
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItListsTheFiresInsideTheWindow(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2026, 6, 1, 18, 30, 0, 0, time.UTC)
+	until := time.Date(2026, 6, 2, 20, 0, 0, 0, time.UTC)
+	startAt := time.Date(2026, 6, 1, 20, 30, 0, 0, time.UTC)
+
+	ctbE := CrontabEntry{Cron: mustParseCron(t, "0 19-21 * * *"), Cmd: "cli start-game 1", StartAt: &startAt}
+
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 6, 1, 21, 0, 0, 0, time.UTC),
+		time.Date(2026, 6, 2, 19, 0, 0, 0, time.UTC),
+		time.Date(2026, 6, 2, 20, 0, 0, 0, time.UTC),
+	}, ctbE.FiresBetween(since, until), "fires before start_at are left out and until is included")
+
+	assert.Empty(t, ctbE.FiresBetween(until, until))
+}
+
+func Test_ItOnlyMovesTheLastFireForward(t *testing.T) {
+	t.Parallel()
+
+	fl := NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	id, _ := uuid.NewV7()
+
+	_, ok, err := fl.All()
+	require.NoError(t, err)
+	assert.False(t, ok, "nothing is recorded before the first fire")
+
+	later := time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)
+	require.NoError(t, fl.Record(id, later))
+	require.NoError(t, fl.Record(id, later.Add(-time.Hour)))
+
+	fires, ok, err := fl.All()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, later.Equal(fires[id]))
+}
+
+func Test_ItValidatesMisfirePolicies(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range []string{"", MISFIRE_SKIP, MISFIRE_RUN_ONCE, MISFIRE_RUN_ALL} {
+		assert.NoError(t, ValidateMisfirePolicy(policy))
+	}
+
+	assert.ErrorIs(t, ValidateMisfirePolicy("catch_up"), ErrInvalidMisfirePolicy)
+}
+
+func Test_ItOnlyTracksCLICommands(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, CrontabEntry{Cmd: "cli start-game 1"}.RunsCLI())
+	assert.False(t, CrontabEntry{Cmd: "/usr/local/bin/backup.sh"}.RunsCLI())
+	assert.False(t, CrontabEntry{Cmd: "cli"}.RunsCLI())
+}
@@ -0,0 +1,62 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cron has no year field, so a one-shot task further out than this could
+// fire a year early
+const maxOneShotLead = 365 * 24 * time.Hour
+
+var (
+	ErrRunAtInPast    = errors.New("run time must be in the future")
+	ErrRunAtTooFarOut = errors.New("run time must be within the next year")
+)
+
+// Accepts an RFC 3339 timestamp or a relative time such as "in 15m". The
+// result is rounded up to the next whole minute, the finest cron can do.
+func ParseRunAt(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+
+	var at time.Time
+	if rel, ok := strings.CutPrefix(input, "in "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rel))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a valid relative time: %w", input, err)
+		}
+
+		at = now.Add(d)
+	} else {
+		parsed, err := time.Parse(time.RFC3339, input)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a relative time like \"in 15m\"", input)
+		}
+
+		at = parsed
+	}
+
+	if truncated := at.Truncate(time.Minute); !truncated.Equal(at) {
+		at = truncated.Add(time.Minute)
+	}
+
+	if !at.After(now) {
+		return time.Time{}, ErrRunAtInPast
+	}
+
+	if at.Sub(now) > maxOneShotLead {
+		return time.Time{}, ErrRunAtTooFarOut
+	}
+
+	return at.UTC(), nil
+}
+
+// The cron expression that fires at the given minute. Cron runs on the
+// host's local time, so that is what the fields are in.
+func OneShotCron(at time.Time) string {
+	local := at.In(time.Local)
+
+	return fmt.Sprintf("%d %d %d %d *", local.Minute(), local.Hour(), local.Day(), int(local.Month()))
+}
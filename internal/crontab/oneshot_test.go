@@ -0,0 +1,57 @@
+package crontab
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItParsesRunTimes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 11, 2, 19, 14, 30, 0, time.UTC)
+
+	at, err := ParseRunAt("in 15m", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 2, 19, 30, 0, 0, time.UTC), at)
+
+	at, err = ParseRunAt("2026-11-02T21:30:00+02:00", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 2, 19, 30, 0, 0, time.UTC), at)
+
+	_, err = ParseRunAt("2026-11-01T19:30:00Z", now)
+	assert.ErrorIs(t, err, ErrRunAtInPast)
+
+	_, err = ParseRunAt("in 9000h", now)
+	assert.ErrorIs(t, err, ErrRunAtTooFarOut)
+
+	_, err = ParseRunAt("next tuesday", now)
+	assert.Error(t, err)
+}
+
+func Test_ItRendersOneShotTasks(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	runAt := time.Date(2026, 11, 2, 19, 30, 0, 0, time.UTC)
+
+	var ctbE CrontabEntry
+	require.NoError(t, ctbE.Cron.UnmarshalText([]byte(OneShotCron(runAt))))
+	ctbE.ID = id
+	ctbE.Cmd = "cli start-game 1"
+	ctbE.RunAt = &runAt
+
+	line := strings.TrimSuffix(ctbE.String(), "\n")
+	assert.Contains(t, line, " root "+TASK_ID_ENV+"="+id.String()+" /app/cli start-game 1 ")
+
+	parsed, err := NewCrontabEntryFromString(line)
+	require.NoError(t, err)
+	assert.Equal(t, "cli start-game 1", parsed.Cmd)
+	require.True(t, parsed.IsOneShot())
+	assert.True(t, runAt.Equal(*parsed.RunAt))
+	assert.Equal(t, line, strings.TrimSuffix(parsed.String(), "\n"))
+}
@@ -0,0 +1,117 @@
+package crontab
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Used when a policy does not set its own multiplier
+const defaultRetryMultiplier = 2
+
+var ErrInvalidRetryPolicy = errors.New("retry policy needs max_attempts of at least 1, a positive initial_delay to retry, a multiplier of at least 1, a max_delay no shorter than initial_delay and jitter between 0 and 1")
+
+// How a failed run is retried. The delay after each failed attempt starts at
+// InitialDelay and grows by Multiplier up to MaxDelay. Jitter spreads each
+// delay by up to that fraction either way, so retries of many tasks that
+// failed together do not all land at once.
+type RetryPolicy struct {
+	MaxAttempts  int           `json:"max_attempts" yaml:"max_attempts"`
+	InitialDelay time.Duration `json:"initial_delay" yaml:"initial_delay"`
+	Multiplier   float64       `json:"multiplier,omitempty" yaml:"multiplier"`
+	MaxDelay     time.Duration `json:"max_delay,omitempty" yaml:"max_delay"`
+	Jitter       float64       `json:"jitter,omitempty" yaml:"jitter"`
+}
+
+func (rp RetryPolicy) Validate() error {
+	switch {
+	case rp.MaxAttempts < 1,
+		rp.MaxAttempts > 1 && rp.InitialDelay <= 0,
+		rp.Multiplier != 0 && rp.Multiplier < 1,
+		rp.MaxDelay != 0 && rp.MaxDelay < rp.InitialDelay,
+		rp.Jitter < 0 || rp.Jitter > 1:
+		return ErrInvalidRetryPolicy
+	}
+
+	return nil
+}
+
+// How long to wait after the given failed attempt, counting from 1. ok is
+// false once the attempts are used up.
+func (rp RetryPolicy) Delay(attempt int) (delay time.Duration, ok bool) {
+	if attempt >= rp.MaxAttempts {
+		return 0, false
+	}
+
+	multiplier := rp.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	d := float64(rp.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if rp.MaxDelay > 0 && d > float64(rp.MaxDelay) {
+		d = float64(rp.MaxDelay)
+	}
+
+	if rp.Jitter > 0 {
+		d += d * rp.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d), true
+}
+
+// Delays are written as durations like "30s" rather than nanoseconds
+type retryPolicyJSON struct {
+	MaxAttempts  int     `json:"max_attempts"`
+	InitialDelay string  `json:"initial_delay"`
+	Multiplier   float64 `json:"multiplier,omitempty"`
+	MaxDelay     string  `json:"max_delay,omitempty"`
+	Jitter       float64 `json:"jitter,omitempty"`
+}
+
+func (rp RetryPolicy) MarshalJSON() ([]byte, error) {
+	out := retryPolicyJSON{
+		MaxAttempts:  rp.MaxAttempts,
+		InitialDelay: rp.InitialDelay.String(),
+		Multiplier:   rp.Multiplier,
+		Jitter:       rp.Jitter,
+	}
+
+	if rp.MaxDelay > 0 {
+		out.MaxDelay = rp.MaxDelay.String()
+	}
+
+	return json.Marshal(out)
+}
+
+func (rp *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var in retryPolicyJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	policy := RetryPolicy{
+		MaxAttempts: in.MaxAttempts,
+		Multiplier:  in.Multiplier,
+		Jitter:      in.Jitter,
+	}
+
+	var err error
+	if in.InitialDelay != "" {
+		if policy.InitialDelay, err = time.ParseDuration(in.InitialDelay); err != nil {
+			return err
+		}
+	}
+
+	if in.MaxDelay != "" {
+		if policy.MaxDelay, err = time.ParseDuration(in.MaxDelay); err != nil {
+			return err
+		}
+	}
+
+	*rp = policy
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package crontab
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItBacksOffExponentiallyUpToTheMaxDelay(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 5, InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	var delays []time.Duration
+	for attempt := 1; ; attempt++ {
+		delay, ok := policy.Delay(attempt)
+		if !ok {
+			break
+		}
+
+		delays = append(delays, delay)
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, delays)
+
+	policy.Multiplier = 3
+	delay, _ := policy.Delay(2)
+	assert.Equal(t, 3*time.Second, delay)
+}
+
+func Test_ItSpreadsDelaysByTheJitter(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 2, InitialDelay: 10 * time.Second, Jitter: 0.2}
+
+	for range 100 {
+		delay, ok := policy.Delay(1)
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, delay, 8*time.Second)
+		assert.LessOrEqual(t, delay, 12*time.Second)
+	}
+}
+
+func Test_ItValidatesRetryPolicies(t *testing.T) {
+	t.Parallel()
+
+	invalid := []RetryPolicy{
+		{},
+		{MaxAttempts: 2},
+		{MaxAttempts: 2, InitialDelay: time.Second, Multiplier: 0.5},
+		{MaxAttempts: 2, InitialDelay: time.Minute, MaxDelay: time.Second},
+		{MaxAttempts: 2, InitialDelay: time.Second, Jitter: 1.5},
+	}
+
+	for _, policy := range invalid {
+		assert.ErrorIs(t, policy.Validate(), ErrInvalidRetryPolicy, "%+v", policy)
+	}
+
+	assert.NoError(t, RetryPolicy{MaxAttempts: 1}.Validate())
+	assert.NoError(t, RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 2, MaxDelay: time.Minute, Jitter: 0.1}.Validate())
+}
+
+func Test_ItWritesRetryDelaysAsDurations(t *testing.T) {
+	t.Parallel()
+
+	in := `{"max_attempts":3,"initial_delay":"30s","multiplier":2,"max_delay":"5m0s","jitter":0.1}`
+
+	var policy RetryPolicy
+	require.NoError(t, json.Unmarshal([]byte(in), &policy))
+	assert.Equal(t, RetryPolicy{MaxAttempts: 3, InitialDelay: 30 * time.Second, Multiplier: 2, MaxDelay: 5 * time.Minute, Jitter: 0.1}, policy)
+
+	out, err := json.Marshal(policy)
+	require.NoError(t, err)
+	assert.JSONEq(t, in, string(out))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"max_attempts":3,"initial_delay":"soon"}`), &policy))
+}
@@ -0,0 +1,121 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+var ErrRevisionNotFound = errors.New("did not find revision")
+
+// A snapshot of every managed entry, taken after a change was written
+type Revision struct {
+	ID        int            `json:"id"`
+	Author    string         `json:"author"`
+	Reason    string         `json:"reason"`
+	CreatedAt time.Time      `json:"created_at"`
+	Entries   []CrontabEntry `json:"entries"`
+}
+
+// The crontab as it was rendered for this revision, with secrets redacted
+func (r Revision) Lines() []string {
+	var out []string
+	for _, ctbE := range r.Entries {
+		out = append(out, strings.TrimSuffix(ctbE.Redacted().String(), "\n"))
+	}
+
+	return out
+}
+
+type revisionDocument struct {
+	NextID    int        `json:"next_id"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// Keeps the most recent revisions in a JSON file, dropping the oldest once
+// the limit is reached
+type RevisionHistory struct {
+	file  *store.JSONFile[revisionDocument]
+	limit int
+}
+
+func NewRevisionHistory(path string, limit int) *RevisionHistory {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &RevisionHistory{
+		file:  store.NewJSONFile[revisionDocument](path),
+		limit: limit,
+	}
+}
+
+func (rh *RevisionHistory) Record(author, reason string, entries []CrontabEntry) (Revision, error) {
+	var rev Revision
+
+	err := rh.file.Update(func(doc *revisionDocument) error {
+		doc.NextID++
+		rev = Revision{
+			ID:        doc.NextID,
+			Author:    author,
+			Reason:    reason,
+			CreatedAt: time.Now().UTC(),
+			Entries:   redactedEntries(entries),
+		}
+
+		doc.Revisions = append(doc.Revisions, rev)
+		if len(doc.Revisions) > rh.limit {
+			doc.Revisions = slices.Clone(doc.Revisions[len(doc.Revisions)-rh.limit:])
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
+
+// Snapshots are kept with secret values redacted, so the history does not
+// hold a copy of every secret that was ever set
+func redactedEntries(entries []CrontabEntry) []CrontabEntry {
+	out := make([]CrontabEntry, len(entries))
+	for i, ctbE := range entries {
+		out[i] = ctbE.Redacted()
+	}
+
+	return out
+}
+
+// Newest first
+func (rh *RevisionHistory) List() ([]Revision, error) {
+	doc, err := rh.file.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(doc.Revisions)
+
+	return doc.Revisions, nil
+}
+
+func (rh *RevisionHistory) Get(id int) (Revision, error) {
+	doc, err := rh.file.Load()
+	if err != nil {
+		return Revision{}, err
+	}
+
+	for _, rev := range doc.Revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+
+	return Revision{}, fmt.Errorf("%w with ID of %d", ErrRevisionNotFound, id)
+}
@@ -0,0 +1,76 @@
+package crontab
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItKeepsABoundedRevisionHistory(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	rh := NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 2)
+
+	for _, reason := range []string{"first", "second", "third"} {
+		_, err := rh.Record("ops", reason, fixtureCrontabs(id))
+		require.NoError(t, err)
+	}
+
+	revs, err := rh.List()
+
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, 3, revs[0].ID)
+	assert.Equal(t, "third", revs[0].Reason)
+	assert.Equal(t, "ops", revs[0].Author)
+	assert.Equal(t, 2, revs[1].ID)
+
+	_, err = rh.Get(1)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+}
+
+func Test_ItKeepsSecretValuesOutOfRevisions(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	entries := fixtureCrontabs(id)
+	entries[0].Env = []EnvVar{
+		{Name: "API_TOKEN", Value: "s3cret", Secret: true},
+		{Name: "REGION", Value: "eu-west-1"},
+	}
+
+	path := filepath.Join(t.TempDir(), "revisions.json")
+	rh := NewRevisionHistory(path, 2)
+
+	rev, err := rh.Record("ops", "schedule", entries)
+	require.NoError(t, err)
+
+	assert.Equal(t, "s3cret", entries[0].Env[0].Value, "the entries passed in are left alone")
+	assert.Equal(t, REDACTED, rev.Entries[0].Env[0].Value)
+	assert.Equal(t, "eu-west-1", rev.Entries[0].Env[1].Value)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "s3cret")
+}
+
+func Test_ItDiffsCrontabLines(t *testing.T) {
+	t.Parallel()
+
+	diff := DiffLines(
+		[]string{"a", "b", "c"},
+		[]string{"a", "c", "d"},
+	)
+
+	assert.Equal(t, []DiffLine{
+		{Op: DIFF_SAME, Line: "a"},
+		{Op: DIFF_REMOVED, Line: "b"},
+		{Op: DIFF_SAME, Line: "c"},
+		{Op: DIFF_ADDED, Line: "d"},
+	}, diff)
+}
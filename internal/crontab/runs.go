@@ -0,0 +1,199 @@
+package crontab
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+type RunOutcome string
+
+const (
+	RUN_SUCCEEDED RunOutcome = "succeeded"
+	RUN_FAILED    RunOutcome = "failed"
+	// The task was due but not run, e.g. it had used up its runs or its last
+	// run was still going
+	RUN_SKIPPED RunOutcome = "skipped"
+
+	// Started as a cli process by crond, or by a systemd timer or CronJob
+	RUN_SOURCE_CLI        = "cli"
+	RUN_SOURCE_IN_PROCESS = "in-process"
+	// Run on startup in place of a run that was missed while the service was down
+	RUN_SOURCE_CATCH_UP = "catch-up"
+
+	// How much of a run's output is kept with its record, from the end
+	RunOutputLimit = 4096
+)
+
+// One attempt at running a task's command, returning what it printed
+type AttemptFn func(ctx context.Context) (output string, err error)
+
+// One execution of a task
+type Run struct {
+	ID          uuid.UUID `json:"id"`
+	TaskID      uuid.UUID `json:"task_id"`
+	Source      string    `json:"source"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	// Counts from 1, retries of the same fire share its ScheduledAt
+	Attempt   int        `json:"attempt"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   time.Time  `json:"ended_at"`
+	Outcome   RunOutcome `json:"outcome"`
+	ExitCode  int        `json:"exit_code"`
+	Error     string     `json:"error,omitempty"`
+	Output    string     `json:"output,omitempty"`
+	// Why a skipped run was not started
+	Reason string `json:"reason,omitempty"`
+}
+
+func NewRun(taskID uuid.UUID, source string, scheduledAt time.Time) Run {
+	id, _ := uuid.NewV7()
+
+	return Run{
+		ID:          id,
+		TaskID:      taskID,
+		Source:      source,
+		ScheduledAt: scheduledAt.UTC(),
+		Attempt:     1,
+		StartedAt:   time.Now().UTC(),
+	}
+}
+
+// Records how the run ended. Errors carrying an exit code, like cli.Exit,
+// keep theirs and any other error exits with 1.
+func (r *Run) Finish(err error, output string) {
+	r.EndedAt = time.Now().UTC()
+	r.Output = output
+	r.Outcome = RUN_SUCCEEDED
+
+	if err == nil {
+		return
+	}
+
+	r.Outcome = RUN_FAILED
+	r.Error = err.Error()
+	r.ExitCode = 1
+
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		r.ExitCode = coder.ExitCode()
+	}
+}
+
+// Records a due run that was not started
+func (r *Run) Skip(reason string) {
+	r.EndedAt = r.StartedAt
+	r.Outcome = RUN_SKIPPED
+	r.Reason = reason
+}
+
+func (r Run) Duration() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// Keeps the most recent runs of each task in a JSON file. Runs beyond the
+// per task limit, or older than the retention, are dropped as new ones are
+// recorded.
+type RunHistory struct {
+	file      *store.JSONFile[[]Run]
+	limit     int
+	retention time.Duration
+}
+
+func NewRunHistory(path string, limit int, retention time.Duration) *RunHistory {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &RunHistory{
+		file:      store.NewJSONFile[[]Run](path),
+		limit:     limit,
+		retention: retention,
+	}
+}
+
+func (rh *RunHistory) Record(run Run) error {
+	return rh.file.Update(func(runs *[]Run) error {
+		*runs = append(*runs, run)
+
+		cutoff := time.Time{}
+		if rh.retention > 0 {
+			cutoff = time.Now().Add(-rh.retention)
+		}
+
+		perTask := map[uuid.UUID]int{}
+		kept := make([]Run, 0, len(*runs))
+
+		// Newest are at the end, so walk backwards to keep them
+		for _, r := range slices.Backward(*runs) {
+			if r.StartedAt.Before(cutoff) || perTask[r.TaskID] >= rh.limit {
+				continue
+			}
+
+			perTask[r.TaskID]++
+			kept = append(kept, r)
+		}
+
+		slices.Reverse(kept)
+		*runs = kept
+
+		return nil
+	})
+}
+
+// The task's runs, newest first
+func (rh *RunHistory) List(taskID uuid.UUID) ([]Run, error) {
+	runs, err := rh.file.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Run
+	for _, r := range slices.Backward(runs) {
+		if r.TaskID == taskID {
+			out = append(out, r)
+		}
+	}
+
+	return out, nil
+}
+
+// Keeps the last RunOutputLimit bytes written to it, so a chatty task does
+// not bloat its run record
+type OutputExcerpt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (oe *OutputExcerpt) Write(p []byte) (int, error) {
+	oe.mu.Lock()
+	defer oe.mu.Unlock()
+
+	oe.buf = append(oe.buf, p...)
+	if over := len(oe.buf) - RunOutputLimit; over > 0 {
+		oe.buf = slices.Clone(oe.buf[over:])
+	}
+
+	return len(p), nil
+}
+
+// Starts over, e.g. before a retry
+func (oe *OutputExcerpt) Reset() {
+	oe.mu.Lock()
+	defer oe.mu.Unlock()
+
+	oe.buf = nil
+}
+
+func (oe *OutputExcerpt) String() string {
+	oe.mu.Lock()
+	defer oe.mu.Unlock()
+
+	return string(oe.buf)
+}
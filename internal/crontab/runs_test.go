@@ -0,0 +1,67 @@
+package crontab
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string { return "exited" }
+func (e exitError) ExitCode() int { return e.code }
+
+func Test_ItKeepsABoundedRunHistoryPerTask(t *testing.T) {
+	t.Parallel()
+
+	rh := NewRunHistory(filepath.Join(t.TempDir(), "runs.json"), 2, time.Hour)
+	busy, _ := uuid.NewV7()
+	quiet, _ := uuid.NewV7()
+
+	var outcomes []RunOutcome
+	for _, err := range []error{nil, errors.New("boom"), exitError{code: 3}} {
+		run := NewRun(busy, RUN_SOURCE_CLI, time.Now().Truncate(time.Minute))
+		run.Finish(err, "")
+		outcomes = append(outcomes, run.Outcome)
+		require.NoError(t, rh.Record(run))
+	}
+
+	assert.Equal(t, []RunOutcome{RUN_SUCCEEDED, RUN_FAILED, RUN_FAILED}, outcomes)
+
+	stale := NewRun(quiet, RUN_SOURCE_IN_PROCESS, time.Now().Add(-2*time.Hour))
+	stale.StartedAt = time.Now().Add(-2 * time.Hour)
+	stale.Skip("max_runs reached")
+	require.NoError(t, rh.Record(stale))
+
+	runs, err := rh.List(busy)
+	require.NoError(t, err)
+	require.Len(t, runs, 2, "only the newest runs are kept")
+	assert.Equal(t, 3, runs[0].ExitCode)
+	assert.Equal(t, "exited", runs[0].Error)
+	assert.Equal(t, 1, runs[1].ExitCode)
+	assert.Equal(t, "boom", runs[1].Error)
+
+	runs, err = rh.List(quiet)
+	require.NoError(t, err)
+	assert.Empty(t, runs, "runs older than the retention are dropped")
+}
+
+func Test_ItKeepsTheEndOfARunsOutput(t *testing.T) {
+	t.Parallel()
+
+	var oe OutputExcerpt
+	_, _ = oe.Write([]byte(strings.Repeat("a", RunOutputLimit)))
+	_, _ = oe.Write([]byte("done\n"))
+
+	out := oe.String()
+	assert.Len(t, out, RunOutputLimit)
+	assert.True(t, strings.HasSuffix(out, "adone\n"))
+}
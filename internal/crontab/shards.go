@@ -0,0 +1,136 @@
+package crontab
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+const (
+	SHARD_BY_COMMAND = "command" // one file per task type, e.g. coco-start-game
+	SHARD_BY_TENANT  = "tenant"  // one file per owner, e.g. coco-tournaments
+
+	// Matches the shard file names, for watching them
+	SHARD_FILE_PATTERN = shardPrefix + "*"
+
+	shardPrefix  = "coco-"
+	defaultShard = "default"
+)
+
+// cron.d skips files with anything but letters, digits, '_' and '-' in
+// their names, dots included
+var invalidShardChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// Splits the crontab into one coco-<shard> file per task type or tenant,
+// next to the crontab file, e.g. /etc/cron.d/coco-start-game. A bad line or
+// a busy task type then only affects its own file.
+func WithShards(by string) CrontabManagerOptFn {
+	return func(cM *CrontabManager) {
+		switch by {
+		case SHARD_BY_COMMAND, SHARD_BY_TENANT:
+			cM.shardBy = by
+		case "":
+		default:
+			slog.Error("unknown crontab shard key, writing a single crontab",
+				slog.String("shard_by", by),
+			)
+		}
+	}
+}
+
+// The shard file the entry is written to
+func (cM *CrontabManager) shardFor(ctbE CrontabEntry) string {
+	var key string
+
+	switch cM.shardBy {
+	case SHARD_BY_COMMAND:
+		fields := strings.Fields(ctbE.Cmd)
+		if len(fields) > 1 && fields[0] == "cli" {
+			fields = fields[1:]
+		}
+
+		if len(fields) > 0 {
+			key = filepath.Base(fields[0])
+		}
+	case SHARD_BY_TENANT:
+		key = ctbE.Meta.Owner
+	}
+
+	key = strings.Trim(invalidShardChars.ReplaceAllString(strings.ToLower(key), "-"), "-")
+	if key == "" {
+		key = defaultShard
+	}
+
+	return shardPrefix + key
+}
+
+// The shard files currently next to the crontab, in name order
+func shardFiles(crontabFile string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(crontabFile), SHARD_FILE_PATTERN))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, path := range paths {
+		name := strings.TrimPrefix(filepath.Base(path), shardPrefix)
+		if name != "" && !invalidShardChars.MatchString(name) {
+			out = append(out, path)
+		}
+	}
+
+	slices.Sort(out)
+
+	return out, nil
+}
+
+// Writes each shard atomically and removes shards left empty. The single
+// crontab file is emptied so tasks written there before sharding do not run
+// twice.
+func (cM *CrontabManager) renderShards(crontabFile string, entries []CrontabEntry) error {
+	dir := filepath.Dir(crontabFile)
+	shards := map[string]*strings.Builder{}
+
+	for _, item := range entries {
+		name := cM.shardFor(item)
+		if shards[name] == nil {
+			shards[name] = &strings.Builder{}
+		}
+
+		shards[name].WriteString(cM.RenderLine(item))
+	}
+
+	for name, builder := range shards {
+		if err := store.WriteFileAtomic(filepath.Join(dir, name), []byte(builder.String()), store.PRIVATE_FILE_MODE); err != nil {
+			return err
+		}
+	}
+
+	existing, err := shardFiles(crontabFile)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range existing {
+		if shards[filepath.Base(path)] != nil {
+			continue
+		}
+
+		if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if _, err = os.Stat(crontabFile); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return store.WriteFileAtomic(crontabFile, nil, store.PRIVATE_FILE_MODE)
+}
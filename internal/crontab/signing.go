@@ -0,0 +1,170 @@
+package crontab
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/config"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+var (
+	ErrUnsignedLine     = errors.New("crontab line is not signed")
+	ErrInvalidSignature = errors.New("crontab line signature does not match")
+)
+
+// A crontab line that failed its signature check and was taken out of the
+// crontab
+type TamperedLine struct {
+	// Unset when the line is not a managed entry at all
+	ID      uuid.UUID `json:"id"`
+	Line    string    `json:"line"`
+	Reason  string    `json:"reason"`
+	FoundAt time.Time `json:"found_at"`
+}
+
+// Anything that can write the crontab can run commands as root, so with a
+// key configured every line carries an HMAC of itself
+func signingKey() []byte {
+	return []byte(config.Config.CrontabSigningKey)
+}
+
+func lineSignature(line string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(line))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Appends the signature of the line, covering the cron, the command with its
+// environment, the ID and every other tag
+func signLine(line string, key []byte) string {
+	return line + " " + sigTagPrefix + lineSignature(line, key)
+}
+
+// Checks a line written by String against the key
+func VerifyLine(line string, key []byte) error {
+	line = strings.TrimRight(line, "\n")
+
+	idx := strings.LastIndex(line, " "+sigTagPrefix)
+	if idx == -1 {
+		return ErrUnsignedLine
+	}
+
+	signed, sig := line[:idx], line[idx+len(sigTagPrefix)+1:]
+	if !hmac.Equal([]byte(sig), []byte(lineSignature(signed, key))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Keeps lines that fail their signature check in a JSON file and calls alert
+// for each of them
+func WithQuarantine(path string, alert func(TamperedLine)) CrontabManagerOptFn {
+	return func(cM *CrontabManager) {
+		cM.quarantine = store.NewJSONFile[[]TamperedLine](path)
+		cM.alert = alert
+	}
+}
+
+// Implemented by handlers that sign what they write, so tampering can be
+// caught whenever the files change
+type SignatureChecker interface {
+	CheckSignatures() ([]TamperedLine, error)
+}
+
+// Checks every line in the crontab carries a valid signature. Lines that do
+// not are quarantined and the crontab is rewritten from the task store
+// without them. Lines written before signing was turned on are signed in
+// place if they match the store. Does nothing without a signing key.
+func (cM *CrontabManager) CheckSignatures() ([]TamperedLine, error) {
+	key := signingKey()
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	lines, err := cM.ReadCrontabLines()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := cM.store.All()
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := make(map[uuid.UUID]string, len(entries))
+	for _, ctbE := range entries {
+		unsigned[ctbE.ID] = strings.TrimSpace(ctbE.renderLine(nil, cM.logDir))
+	}
+
+	var tampered []TamperedLine
+	rewrite := false
+	now := time.Now().UTC()
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || (strings.HasPrefix(line, "#") && !strings.HasPrefix(line, pausedPrefix)) {
+			continue
+		}
+
+		verr := VerifyLine(line, key)
+		if verr == nil {
+			continue
+		}
+		rewrite = true
+
+		ctbE, perr := NewCrontabEntryFromString(line)
+		if perr == nil && errors.Is(verr, ErrUnsignedLine) && unsigned[ctbE.ID] == line {
+			continue
+		}
+
+		item := TamperedLine{Line: line, Reason: verr.Error(), FoundAt: now}
+		if perr == nil {
+			item.ID = ctbE.ID
+			item.Line = redactLine(ctbE, line, cM.RenderLine)
+		}
+
+		tampered = append(tampered, item)
+	}
+
+	if !rewrite {
+		return nil, nil
+	}
+
+	if len(tampered) > 0 && cM.quarantine != nil {
+		err = cM.quarantine.Update(func(q *[]TamperedLine) error {
+			*q = append(*q, tampered...)
+			return nil
+		})
+
+		if err != nil {
+			return tampered, err
+		}
+	}
+
+	if err = cM.renderCrontab(); err != nil {
+		return tampered, err
+	}
+
+	for _, item := range tampered {
+		slog.Warn("quarantined crontab line that failed its signature check",
+			slog.String("task_id", item.ID.String()),
+			slog.String("reason", item.Reason),
+		)
+
+		if cM.alert != nil {
+			cM.alert(item)
+		}
+	}
+
+	return tampered, nil
+}
@@ -0,0 +1,32 @@
+package crontab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ItVerifiesSignedLines(t *testing.T) {
+	key := []byte("test-key")
+	id, _ := uuid.NewV7()
+
+	ctbE := CrontabEntry{ID: id, Cron: mustParseCron(t, "*/5 * * * *"), Cmd: "cli start-game 1", Paused: true}
+	line := ctbE.renderLine(key, "")
+
+	assert.NoError(t, VerifyLine(line, key))
+	assert.ErrorIs(t, VerifyLine(line, []byte("other-key")), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyLine(ctbE.renderLine(nil, ""), key), ErrUnsignedLine)
+
+	edited := strings.Replace(line, "start-game 1", "start-game 2", 1)
+	assert.ErrorIs(t, VerifyLine(edited, key), ErrInvalidSignature)
+
+	resumed := strings.TrimPrefix(line, pausedPrefix)
+	assert.ErrorIs(t, VerifyLine(resumed, key), ErrInvalidSignature, "un-pausing a line breaks its signature")
+
+	parsed, err := NewCrontabEntryFromString(strings.TrimSpace(line))
+	assert.NoError(t, err)
+	assert.Equal(t, "cli start-game 1", parsed.Cmd)
+	assert.True(t, parsed.Paused)
+}
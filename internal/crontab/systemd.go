@@ -0,0 +1,572 @@
+package crontab
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/config"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+const (
+	unitPrefix     = "coco-"
+	unitHeader     = "# Managed by coco, changes are overwritten\n"
+	unitShell      = "/bin/sh -c "
+	unitLogPrefix  = "append:"
+	unitIDKey      = "X-Coco-ID"
+	unitCronKey    = "X-Coco-Cron"
+	unitMetaKey    = "X-Coco-Meta"
+	unitOnceKey    = "X-Coco-Once"
+	unitSecretKey  = "X-Coco-Secret"
+	unitImportKey  = "X-Coco-Imported"
+	unitArgsKey    = "X-Coco-Args"
+	unitStartKey   = "X-Coco-Start"
+	unitEndKey     = "X-Coco-End"
+	unitMaxRunsKey = "X-Coco-Max-Runs"
+	unitRunsKey    = "X-Coco-Runs"
+	unitRetryKey   = "X-Coco-Retry"
+	unitConcurKey  = "X-Coco-Concurrency"
+	unitMisfireKey = "X-Coco-Misfire"
+	unitTimersWant = "timers.target"
+)
+
+var (
+	// The schedule backend has no way to express the cron expression
+	ErrCronNotRepresentable = errors.New("cron expression cannot be represented by the schedule backend")
+
+	systemdWeekdays = [...]string{"", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+	// Double quoted values in unit files take C style escapes and expand
+	// '%' specifiers. ExecStart also expands '$' variables.
+	unitQuoter   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`)
+	unitUnquoter = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `%%`, `%`)
+	execQuoter   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, `$`, `$$`)
+	execUnquoter = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `%%`, `%`, `$$`, `$`)
+)
+
+// Schedules tasks as systemd timers, for hosts without a cron daemon. Each
+// task is rendered from the TaskStore as a coco-<id>.service and a matching
+// coco-<id>.timer in the unit directory. Paused tasks keep their service but
+// lose their timer.
+type SystemdManager struct {
+	storeBackend
+	mu  sync.Mutex
+	dir string
+	// Where each task's own log goes, see WithSystemdTaskLogDir
+	logDir string
+	// Runs systemctl with the given arguments. Without it the units are only
+	// written to disk.
+	systemctl func(args ...string) error
+}
+
+type SystemdManagerOptFn func(sM *SystemdManager)
+
+func NewSystemdManager(dir string, opts ...SystemdManagerOptFn) *SystemdManager {
+	sM := &SystemdManager{dir: dir}
+
+	for _, fn := range opts {
+		fn(sM)
+	}
+
+	if sM.store == nil {
+		sM.store = NewFileTaskStore(filepath.Join(config.Config.StateDir, "tasks.json"))
+	}
+
+	sM.render = sM.renderUnits
+	sM.validate = func(ctbE CrontabEntry) error {
+		_, err := OnCalendar(ctbE.Cron)
+		return err
+	}
+
+	return sM
+}
+
+func WithSystemdTaskStore(ts TaskStore) SystemdManagerOptFn {
+	return func(sM *SystemdManager) {
+		sM.store = ts
+	}
+}
+
+// Appends each task's output to its own log in dir instead of the shared log
+func WithSystemdTaskLogDir(dir string) SystemdManagerOptFn {
+	return func(sM *SystemdManager) {
+		sM.logDir = absLogDir(dir)
+	}
+}
+
+// Reloads systemd and enables or disables timers after every render
+func WithSystemctl(fn func(args ...string) error) SystemdManagerOptFn {
+	return func(sM *SystemdManager) {
+		sM.systemctl = fn
+	}
+}
+
+// Runs the real systemctl, for use with WithSystemctl
+func RunSystemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Translates a cron expression into systemd's OnCalendar= syntax. Cron fires
+// when either a restricted day of month or a restricted weekday matches, but
+// systemd needs both to, so expressions restricting both are rejected.
+func OnCalendar(cron parser.Cron) (string, error) {
+	fields := make(map[parser.CronFragmentType]parser.CronFragment, len(cron.Data))
+	for _, cf := range cron.Data {
+		fields[cf.FragmentType] = cf
+	}
+
+	if len(fields) != 5 {
+		return "", fmt.Errorf("%w: %q does not have five fields", ErrCronNotRepresentable, cron)
+	}
+
+	if fields[parser.DAY].Kind != parser.WILDCARD && fields[parser.WEEKDAY].Kind != parser.WILDCARD {
+		return "", fmt.Errorf("%w: %q restricts both the day of month and the weekday", ErrCronNotRepresentable, cron)
+	}
+
+	var parts [5]string
+	for idx, fragmentType := range []parser.CronFragmentType{parser.MINUTE, parser.HOUR, parser.DAY, parser.MONTH, parser.WEEKDAY} {
+		part, err := calendarField(fields[fragmentType])
+		if err != nil {
+			return "", fmt.Errorf("%w: %q: %w", ErrCronNotRepresentable, cron, err)
+		}
+
+		parts[idx] = part
+	}
+
+	minute, hour, day, month, weekday := parts[0], parts[1], parts[2], parts[3], parts[4]
+	if weekday != "*" {
+		weekday += " "
+	} else {
+		weekday = ""
+	}
+
+	return fmt.Sprintf("%s*-%s-%s %s:%s:00", weekday, month, day, hour, minute), nil
+}
+
+func calendarField(cf parser.CronFragment) (string, error) {
+	values, err := cf.GetPossibleValues()
+	if err != nil {
+		return "", err
+	}
+
+	if cf.Kind == parser.WILDCARD {
+		return "*", nil
+	}
+
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s never matches", cf.Expr)
+	}
+
+	var out []string
+	if cf.FragmentType == parser.WEEKDAY {
+		for _, v := range values {
+			out = append(out, systemdWeekdays[v])
+		}
+
+		return strings.Join(out, ","), nil
+	}
+
+	switch cf.Kind {
+	case parser.RANGE:
+		return fmt.Sprintf("%02d..%02d", values[0], values[len(values)-1]), nil
+	case parser.DIVISOR:
+		return fmt.Sprintf("%02d/%d", values[0], cf.Factors[0]), nil
+	}
+
+	for _, v := range values {
+		out = append(out, fmt.Sprintf("%02d", v))
+	}
+
+	return strings.Join(out, ","), nil
+}
+
+func serviceUnitName(id uuid.UUID) string {
+	return unitPrefix + id.String() + ".service"
+}
+
+func timerUnitName(id uuid.UUID) string {
+	return unitPrefix + id.String() + ".timer"
+}
+
+// The service that runs the task, appending its output to its own log in
+// logDir. Everything needed to read the entry back is kept in X- keys, which
+// systemd ignores.
+func (ctbE CrontabEntry) serviceUnit(logDir string) string {
+	ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+
+	var b strings.Builder
+	b.WriteString(unitHeader)
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=coco task %s\n", ctbE.ID)
+	fmt.Fprintf(&b, "%s=%s\n", unitIDKey, ctbE.ID)
+	fmt.Fprintf(&b, "%s=%s\n", unitCronKey, ctbE.Cron)
+
+	if !ctbE.Meta.IsZero() {
+		if encoded, err := encodeMetadata(ctbE.Meta); err == nil {
+			fmt.Fprintf(&b, "%s=%s\n", unitMetaKey, encoded)
+		}
+	}
+
+	if ctbE.RunAt != nil {
+		fmt.Fprintf(&b, "%s=%s\n", unitOnceKey, ctbE.RunAt.UTC().Format(time.RFC3339))
+	}
+
+	if secrets := ctbE.secretNames(); len(secrets) > 0 {
+		fmt.Fprintf(&b, "%s=%s\n", unitSecretKey, strings.Join(secrets, ","))
+	}
+
+	if ctbE.Imported {
+		fmt.Fprintf(&b, "%s=true\n", unitImportKey)
+	}
+
+	if len(ctbE.Args) > 0 {
+		if encoded, err := encodeUnitValue(ctbE.Args); err == nil {
+			fmt.Fprintf(&b, "%s=%s\n", unitArgsKey, encoded)
+		}
+	}
+
+	if ctbE.StartAt != nil {
+		fmt.Fprintf(&b, "%s=%s\n", unitStartKey, ctbE.StartAt.UTC().Format(time.RFC3339))
+	}
+
+	if ctbE.EndAt != nil {
+		fmt.Fprintf(&b, "%s=%s\n", unitEndKey, ctbE.EndAt.UTC().Format(time.RFC3339))
+	}
+
+	if ctbE.MaxRuns > 0 {
+		fmt.Fprintf(&b, "%s=%d\n", unitMaxRunsKey, ctbE.MaxRuns)
+	}
+
+	if ctbE.Runs > 0 {
+		fmt.Fprintf(&b, "%s=%d\n", unitRunsKey, ctbE.Runs)
+	}
+
+	if ctbE.Retry != nil {
+		if encoded, err := encodeUnitValue(ctbE.Retry); err == nil {
+			fmt.Fprintf(&b, "%s=%s\n", unitRetryKey, encoded)
+		}
+	}
+
+	if ctbE.ConcurrencyPolicy != "" {
+		fmt.Fprintf(&b, "%s=%s\n", unitConcurKey, ctbE.ConcurrencyPolicy)
+	}
+
+	if ctbE.MisfirePolicy != "" {
+		fmt.Fprintf(&b, "%s=%s\n", unitMisfireKey, ctbE.MisfirePolicy)
+	}
+
+	b.WriteString("\n[Service]\nType=oneshot\n")
+
+	if ctbE.WorkDir != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", ctbE.WorkDir)
+	}
+
+	fmt.Fprintf(&b, "Environment=\"%s=%s\"\n", TASK_ID_ENV, ctbE.ID)
+
+	for _, ev := range ctbE.Env {
+		fmt.Fprintf(&b, "Environment=\"%s\"\n", unitQuoter.Replace(ev.Name+"="+ev.Value))
+	}
+
+	fmt.Fprintf(&b, "ExecStart=%s\"%s\"\n", unitShell, execQuoter.Replace(ctbE.execCommand()))
+	fmt.Fprintf(&b, "StandardOutput=%s%s\n", unitLogPrefix, taskLogPath(logDir, ctbE.ID))
+	b.WriteString("StandardError=inherit\n")
+
+	return b.String()
+}
+
+func (ctbE CrontabEntry) timerUnit(onCalendar string) string {
+	var b strings.Builder
+	b.WriteString(unitHeader)
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=Timer for coco task %s\n", ctbE.ID)
+	b.WriteString("\n[Timer]\n")
+	fmt.Fprintf(&b, "OnCalendar=%s\n", onCalendar)
+	// Cron fires on the minute, systemd would otherwise batch within a minute
+	b.WriteString("AccuracySec=1s\n")
+	fmt.Fprintf(&b, "Unit=%s\n", serviceUnitName(ctbE.ID))
+	b.WriteString("\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", unitTimersWant)
+
+	return b.String()
+}
+
+// Reads an entry back from the service unit written by serviceUnit
+func newCrontabEntryFromServiceUnit(unit string) (CrontabEntry, error) {
+	var ctbE CrontabEntry
+	var secrets []string
+	var sawCron, sawExec bool
+
+	scanner := bufio.NewScanner(strings.NewReader(unit))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return ctbE, fmt.Errorf("unit line %q is not a key and value", line)
+		}
+
+		var err error
+		switch key {
+		case unitIDKey:
+			ctbE.ID, err = uuid.Parse(value)
+		case unitCronKey:
+			err = ctbE.Cron.UnmarshalText([]byte(value))
+			sawCron = true
+		case unitMetaKey:
+			ctbE.Meta, err = decodeMetadata(value)
+		case unitOnceKey:
+			ctbE.RunAt, err = parseUnitTime(value)
+		case unitSecretKey:
+			secrets = strings.Split(value, ",")
+		case unitImportKey:
+			ctbE.Imported, err = strconv.ParseBool(value)
+		case unitArgsKey:
+			err = decodeUnitValue(value, &ctbE.Args)
+		case unitStartKey:
+			ctbE.StartAt, err = parseUnitTime(value)
+		case unitEndKey:
+			ctbE.EndAt, err = parseUnitTime(value)
+		case unitMaxRunsKey:
+			ctbE.MaxRuns, err = strconv.Atoi(value)
+		case unitRunsKey:
+			ctbE.Runs, err = strconv.Atoi(value)
+		case unitRetryKey:
+			ctbE.Retry = &RetryPolicy{}
+			err = decodeUnitValue(value, ctbE.Retry)
+		case unitConcurKey:
+			ctbE.ConcurrencyPolicy = value
+		case unitMisfireKey:
+			ctbE.MisfirePolicy = value
+		case "WorkingDirectory":
+			ctbE.WorkDir = value
+		case "Environment":
+			pair, ok := unquoteUnitValue(value, unitUnquoter)
+			if !ok {
+				return ctbE, fmt.Errorf("environment %s is not quoted", value)
+			}
+
+			name, val, _ := strings.Cut(pair, "=")
+			if name != TASK_ID_ENV {
+				ctbE.Env = append(ctbE.Env, EnvVar{Name: name, Value: val})
+			}
+		case "ExecStart":
+			cmd, ok := unquoteUnitValue(strings.TrimPrefix(value, unitShell), execUnquoter)
+			if !ok || !strings.HasPrefix(value, unitShell) {
+				return ctbE, fmt.Errorf("command %s was not written by coco", value)
+			}
+
+			ctbE.Cmd = cmd
+			sawExec = true
+		}
+
+		if err != nil {
+			return ctbE, fmt.Errorf("unit key %s: %w", key, err)
+		}
+	}
+
+	if ctbE.ID == uuid.Nil || !sawCron || !sawExec {
+		return ctbE, errors.New("unit is missing its ID, cron or command")
+	}
+
+	if !ctbE.Imported {
+		ctbE.Cmd = strings.TrimPrefix(ctbE.Cmd, cmdPathPrefix)
+	}
+
+	for i := range ctbE.Env {
+		ctbE.Env[i].Secret = slices.Contains(secrets, ctbE.Env[i].Name)
+	}
+
+	return ctbE, scanner.Err()
+}
+
+// Values that are not plain strings are kept as base64 encoded JSON, so they
+// fit on one line without quoting
+func encodeUnitValue(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeUnitValue(encoded string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func parseUnitTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func unquoteUnitValue(value string, unquoter *strings.Replacer) (string, bool) {
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return "", false
+	}
+
+	return unquoter.Replace(value[1 : len(value)-1]), true
+}
+
+// Parses every managed service in the unit directory. A task whose timer is
+// missing is read back as paused.
+func (sM *SystemdManager) ReadUnits() ([]CrontabEntry, error) {
+	sM.mu.Lock()
+	defer sM.mu.Unlock()
+
+	services, err := filepath.Glob(filepath.Join(sM.dir, unitPrefix+"*.service"))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []CrontabEntry
+	for _, path := range services {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		ctbE, err := newCrontabEntryFromServiceUnit(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+
+		_, err = os.Stat(filepath.Join(sM.dir, timerUnitName(ctbE.ID)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			ctbE.Paused = true
+		case err != nil:
+			return nil, err
+		}
+
+		out = append(out, ctbE)
+	}
+
+	return out, nil
+}
+
+// Writes a unit pair for every stored entry and removes the units of tasks
+// that are gone. Each file is swapped in atomically. Timers of removed tasks
+// are disabled while their files still exist, then systemd is reloaded and
+// the remaining timers are enabled.
+func (sM *SystemdManager) renderUnits() error {
+	// Rendered under the store's lock, so an older render never lands last
+	return sM.store.View(func(entries []CrontabEntry) error {
+		sM.mu.Lock()
+		defer sM.mu.Unlock()
+
+		err := os.MkdirAll(sM.dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		// systemd will not create the directory the task logs go in
+		if sM.logDir != "" {
+			if err = os.MkdirAll(sM.logDir, 0755); err != nil {
+				return err
+			}
+		}
+
+		wanted := make(map[string]bool, 2*len(entries))
+		var timers []string
+
+		for _, ctbE := range entries {
+			service := serviceUnitName(ctbE.ID)
+			if err = store.WriteFileAtomic(filepath.Join(sM.dir, service), []byte(ctbE.serviceUnit(sM.logDir)), store.PRIVATE_FILE_MODE); err != nil {
+				return err
+			}
+			wanted[service] = true
+
+			if ctbE.Paused {
+				continue
+			}
+
+			onCalendar, err := OnCalendar(ctbE.Cron)
+			if err != nil {
+				return err
+			}
+
+			timer := timerUnitName(ctbE.ID)
+			if err = store.WriteFileAtomic(filepath.Join(sM.dir, timer), []byte(ctbE.timerUnit(onCalendar)), 0644); err != nil {
+				return err
+			}
+			wanted[timer] = true
+			timers = append(timers, timer)
+		}
+
+		existing, err := filepath.Glob(filepath.Join(sM.dir, unitPrefix+"*"))
+		if err != nil {
+			return err
+		}
+
+		var stale, staleTimers []string
+		for _, path := range existing {
+			name := filepath.Base(path)
+			if wanted[name] || !(strings.HasSuffix(name, ".service") || strings.HasSuffix(name, ".timer")) {
+				continue
+			}
+
+			stale = append(stale, path)
+			if strings.HasSuffix(name, ".timer") {
+				staleTimers = append(staleTimers, name)
+			}
+		}
+
+		if sM.systemctl != nil && len(staleTimers) > 0 {
+			if err = sM.systemctl(append([]string{"disable", "--now"}, staleTimers...)...); err != nil {
+				return err
+			}
+		}
+
+		for _, path := range stale {
+			if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		if sM.systemctl == nil {
+			return nil
+		}
+
+		if err = sM.systemctl("daemon-reload"); err != nil {
+			return err
+		}
+
+		if len(timers) == 0 {
+			return nil
+		}
+
+		return sM.systemctl(append([]string{"enable", "--now"}, timers...)...)
+	})
+}
@@ -0,0 +1,169 @@
+package crontab
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+)
+
+func mustParseCron(t *testing.T, expr string) parser.Cron {
+	t.Helper()
+
+	var cron parser.Cron
+	require.NoError(t, cron.UnmarshalText([]byte(expr)))
+
+	return cron
+}
+
+func Test_ItTranslatesCronToOnCalendar(t *testing.T) {
+	cases := map[string]string{
+		"* * * * *":      "*-*-* *:*:00",
+		"*/15 * * * *":   "*-*-* *:00/15:00",
+		"30 9 * * *":     "*-*-* 09:30:00",
+		"0 9-17 * * *":   "*-*-* 09..17:00:00",
+		"0,30 8 1 1,7 *": "*-01,07-01 08:00,30:00",
+		"0 8 * * 1-5":    "Mon,Tue,Wed,Thu,Fri *-*-* 08:00:00",
+		"5 4 * * 7":      "Sun *-*-* 04:05:00",
+	}
+
+	for expr, want := range cases {
+		got, err := OnCalendar(mustParseCron(t, expr))
+		assert.NoError(t, err, expr)
+		assert.Equal(t, want, got, expr)
+	}
+}
+
+func Test_ItRejectsCronThatSystemdCannotRepresent(t *testing.T) {
+	_, err := OnCalendar(mustParseCron(t, "0 8 1 * 1"))
+	assert.ErrorIs(t, err, ErrCronNotRepresentable)
+
+	sM := NewSystemdManager(t.TempDir(), WithSystemdTaskStore(NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))))
+	id, _ := uuid.NewV7()
+
+	err = sM.WriteCrontabEntries([]CrontabEntry{{ID: id, Cron: mustParseCron(t, "0 8 1 * 1"), Cmd: "start-game 1"}})
+	assert.ErrorIs(t, err, ErrCronNotRepresentable)
+
+	entries, err := sM.GetAllCrontabEntries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "nothing is stored when the schedule is rejected")
+}
+
+func Test_ItWritesUnitsThatReadBackAsTheSameEntries(t *testing.T) {
+	dir := t.TempDir()
+	var calls [][]string
+	sM := NewSystemdManager(dir,
+		WithSystemdTaskStore(NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))),
+		WithSystemctl(func(args ...string) error {
+			calls = append(calls, args)
+			return nil
+		}),
+	)
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	runAt := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	endAt := time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC)
+	idOne, _ := uuid.NewV7()
+	idTwo, _ := uuid.NewV7()
+
+	written := []CrontabEntry{
+		{
+			ID:      idOne,
+			Cron:    mustParseCron(t, "*/5 * * * *"),
+			Cmd:     `start-game "123" $HOME 100%`,
+			Meta:    Metadata{Name: "game", Tags: []string{"a"}, CreatedAt: created},
+			Env:     []EnvVar{{Name: "PLAIN", Value: `say "hi"`}, {Name: "TOKEN", Value: "s3cret", Secret: true}},
+			WorkDir: "/srv/coco",
+			Args:    map[string]string{"game_id": "123"},
+			StartAt: &created,
+			EndAt:   &endAt,
+			MaxRuns: 10,
+			Runs:    3,
+			Retry:   &RetryPolicy{MaxAttempts: 3, InitialDelay: 30 * time.Second, Jitter: 0.2},
+
+			ConcurrencyPolicy: CONCURRENCY_FORBID,
+			MisfirePolicy:     MISFIRE_RUN_ONCE,
+		},
+		{
+			ID:    idTwo,
+			Cron:  mustParseCron(t, "0 12 1 6 *"),
+			Cmd:   "/usr/bin/cleanup",
+			RunAt: &runAt,
+		},
+	}
+
+	require.NoError(t, sM.WriteCrontabEntries(written))
+	require.NoError(t, sM.PauseCrontabEntryByID(idTwo))
+
+	assert.FileExists(t, filepath.Join(dir, serviceUnitName(idOne)))
+	assert.FileExists(t, filepath.Join(dir, timerUnitName(idOne)))
+	assert.FileExists(t, filepath.Join(dir, serviceUnitName(idTwo)))
+	assert.NoFileExists(t, filepath.Join(dir, timerUnitName(idTwo)), "paused tasks have no timer")
+
+	timer, err := os.ReadFile(filepath.Join(dir, timerUnitName(idOne)))
+	require.NoError(t, err)
+	assert.Contains(t, string(timer), "OnCalendar=*-*-* *:00/5:00\n")
+
+	service, err := os.ReadFile(filepath.Join(dir, serviceUnitName(idTwo)))
+	require.NoError(t, err)
+	assert.Contains(t, string(service), "Environment=\""+TASK_ID_ENV+"="+idTwo.String()+"\"\n")
+
+	read, err := sM.ReadUnits()
+	require.NoError(t, err)
+	require.Len(t, read, 2)
+
+	stored, err := sM.GetAllCrontabEntries()
+	require.NoError(t, err)
+
+	for i := range read {
+		assert.Equal(t, stored[i].Cron.String(), read[i].Cron.String())
+		read[i].Cron, stored[i].Cron = parser.Cron{}, parser.Cron{}
+	}
+
+	assert.Equal(t, stored, read)
+
+	assert.Equal(t, []string{"daemon-reload"}, calls[len(calls)-2])
+	assert.Equal(t, []string{"enable", "--now", timerUnitName(idOne)}, calls[len(calls)-1])
+	assert.Contains(t, calls, []string{"disable", "--now", timerUnitName(idTwo)})
+}
+
+func Test_ItRemovesTheUnitsOfRemovedTasks(t *testing.T) {
+	dir := t.TempDir()
+	sM := NewSystemdManager(dir, WithSystemdTaskStore(NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))))
+	id, _ := uuid.NewV7()
+
+	// Unmanaged units in the same directory are left alone
+	other := filepath.Join(dir, "other.service")
+	require.NoError(t, os.WriteFile(other, []byte("[Service]\n"), 0644))
+
+	require.NoError(t, sM.WriteCrontabEntries([]CrontabEntry{{ID: id, Cron: mustParseCron(t, "0 * * * *"), Cmd: "start-game 1"}}))
+	require.NoError(t, sM.RemoveCrontabEntryByID(id))
+
+	matches, err := filepath.Glob(filepath.Join(dir, unitPrefix+"*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+	assert.FileExists(t, other)
+
+	read, err := sM.ReadUnits()
+	assert.NoError(t, err)
+	assert.Empty(t, read)
+}
+
+func Test_ItRejectsUnitsNotWrittenByCoco(t *testing.T) {
+	_, err := newCrontabEntryFromServiceUnit(strings.Join([]string{
+		"[Unit]",
+		"X-Coco-ID=" + uuid.NewString(),
+		"X-Coco-Cron=* * * * *",
+		"[Service]",
+		"ExecStart=/usr/bin/true",
+	}, "\n"))
+
+	assert.Error(t, err)
+}
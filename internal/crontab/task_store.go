@@ -0,0 +1,214 @@
+package crontab
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+// The source of truth for scheduled tasks. The crontab is rendered from it.
+type TaskStore interface {
+	All() ([]CrontabEntry, error)
+	Get(uuid.UUID) (CrontabEntry, error)
+	// Inserts new entries and overwrites existing ones with the same ID
+	Put(...CrontabEntry) error
+	Update(uuid.UUID, func(ctbE *CrontabEntry) error) error
+	// Hands every entry to fn to change, add to or remove from while holding
+	// the store's lock. Nothing is written if fn returns an error.
+	UpdateAll(fn func(entries *[]CrontabEntry) error) error
+	Delete(uuid.UUID) error
+	Replace([]CrontabEntry) error
+	// Hands every entry to fn while holding the store's lock, so nothing can
+	// change them until fn returns. fn must not use the store.
+	View(fn func([]CrontabEntry) error) error
+}
+
+type taskDocument struct {
+	Tasks []CrontabEntry `json:"tasks"`
+}
+
+// A TaskStore kept in a single JSON file. Reads are served from memory and
+// the file is only reparsed when its contents have changed. The cache is
+// keyed on a hash of the contents rather than the file's mtime and size, since
+// two writes can land within the same mtime tick and leave the same size.
+type FileTaskStore struct {
+	mu     sync.Mutex
+	file   *store.JSONFile[taskDocument]
+	cache  []CrontabEntry
+	index  map[uuid.UUID]int
+	sum    [sha256.Size]byte
+	loaded bool
+}
+
+func NewFileTaskStore(path string) *FileTaskStore {
+	return &FileTaskStore{
+		file: store.NewJSONFile[taskDocument](path),
+	}
+}
+
+func (fts *FileTaskStore) All() ([]CrontabEntry, error) {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	if err := fts.refresh(); err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(fts.cache), nil
+}
+
+func (fts *FileTaskStore) Get(id uuid.UUID) (CrontabEntry, error) {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	if err := fts.refresh(); err != nil {
+		return CrontabEntry{}, err
+	}
+
+	idx, ok := fts.index[id]
+	if !ok {
+		return CrontabEntry{}, crontabEntryNotFound(id)
+	}
+
+	return fts.cache[idx], nil
+}
+
+func (fts *FileTaskStore) Put(entries ...CrontabEntry) error {
+	return fts.update(func(doc *taskDocument) error {
+		for _, ctbE := range entries {
+			ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+
+			idx := slices.IndexFunc(doc.Tasks, func(item CrontabEntry) bool {
+				return item.ID == ctbE.ID
+			})
+
+			if idx == -1 {
+				doc.Tasks = append(doc.Tasks, ctbE)
+				continue
+			}
+
+			doc.Tasks[idx] = ctbE
+		}
+
+		return nil
+	})
+}
+
+func (fts *FileTaskStore) Update(id uuid.UUID, fn func(ctbE *CrontabEntry) error) error {
+	return fts.update(func(doc *taskDocument) error {
+		idx := slices.IndexFunc(doc.Tasks, func(item CrontabEntry) bool {
+			return item.ID == id
+		})
+
+		if idx == -1 {
+			return crontabEntryNotFound(id)
+		}
+
+		return fn(&doc.Tasks[idx])
+	})
+}
+
+func (fts *FileTaskStore) UpdateAll(fn func(entries *[]CrontabEntry) error) error {
+	return fts.update(func(doc *taskDocument) error {
+		if err := fn(&doc.Tasks); err != nil {
+			return err
+		}
+
+		for idx := range doc.Tasks {
+			doc.Tasks[idx].Cron.PrintingMode = parser.RAW_EXPRESSION
+		}
+
+		return nil
+	})
+}
+
+func (fts *FileTaskStore) Delete(id uuid.UUID) error {
+	return fts.update(func(doc *taskDocument) error {
+		idx := slices.IndexFunc(doc.Tasks, func(item CrontabEntry) bool {
+			return item.ID == id
+		})
+
+		if idx == -1 {
+			return crontabEntryNotFound(id)
+		}
+
+		doc.Tasks = slices.Delete(doc.Tasks, idx, idx+1)
+
+		return nil
+	})
+}
+
+func (fts *FileTaskStore) Replace(entries []CrontabEntry) error {
+	return fts.update(func(doc *taskDocument) error {
+		doc.Tasks = make([]CrontabEntry, 0, len(entries))
+		for _, ctbE := range entries {
+			ctbE.Cron.PrintingMode = parser.RAW_EXPRESSION
+			doc.Tasks = append(doc.Tasks, ctbE)
+		}
+
+		return nil
+	})
+}
+
+func (fts *FileTaskStore) View(fn func([]CrontabEntry) error) error {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	return fts.file.View(func(doc taskDocument) error {
+		return fn(doc.Tasks)
+	})
+}
+
+func (fts *FileTaskStore) update(fn func(doc *taskDocument) error) error {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	err := fts.file.Update(fn)
+	if err != nil {
+		return err
+	}
+
+	fts.loaded = false
+
+	return fts.refresh()
+}
+
+// Reloads the cache if the file changed since we last read it
+func (fts *FileTaskStore) refresh() error {
+	data, err := os.ReadFile(fts.file.Path())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	if fts.loaded && sum == fts.sum {
+		return nil
+	}
+
+	var doc taskDocument
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+	}
+
+	fts.cache = doc.Tasks
+	fts.index = make(map[uuid.UUID]int, len(doc.Tasks))
+	for idx, item := range doc.Tasks {
+		fts.index[item.ID] = idx
+	}
+
+	fts.sum = sum
+	fts.loaded = true
+
+	return nil
+}
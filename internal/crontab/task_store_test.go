@@ -0,0 +1,120 @@
+package crontab
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+)
+
+func Test_ItPersistsTasksInTheStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	id, _ := uuid.NewV7()
+
+	ts := NewFileTaskStore(path)
+	require.NoError(t, ts.Put(fixtureCrontabs(id)...))
+
+	other := NewFileTaskStore(path)
+	ctbE, err := other.Get(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, id, ctbE.ID)
+	assert.Equal(t, "./test-command", ctbE.Cmd)
+	assert.Equal(t, exampleTestCron().String(), ctbE.Cron.String())
+}
+
+func Test_ItUpsertsTasksByID(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	ts := NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+
+	entries := fixtureCrontabs(id)
+	require.NoError(t, ts.Put(entries...))
+
+	entries[0].Cmd = "./other-command"
+	entries[0].Cron.PrintingMode = parser.POSSIBLE_VALUES
+	require.NoError(t, ts.Put(entries...))
+
+	all, err := ts.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, "./other-command", all[0].Cmd)
+}
+
+func Test_ItSeesChangesMadeByOtherProcesses(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	idOne, _ := uuid.NewV7()
+	idTwo, _ := uuid.NewV7()
+
+	ts := NewFileTaskStore(path)
+	require.NoError(t, ts.Put(fixtureCrontabs(idOne)...))
+
+	all, _ := ts.All()
+	assert.Len(t, all, 1)
+
+	other := NewFileTaskStore(path)
+	require.NoError(t, other.Put(fixtureCrontabs(idTwo)...))
+
+	all, err := ts.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func Test_ItSeesSameSizeChangesMadeWithinTheSameMtime(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	id, _ := uuid.NewV7()
+
+	ts := NewFileTaskStore(path)
+	require.NoError(t, ts.Put(fixtureCrontabs(id)...))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	ctbE, err := ts.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "./test-command", ctbE.Cmd)
+
+	other := NewFileTaskStore(path)
+	require.NoError(t, other.Update(id, func(ctbE *CrontabEntry) error {
+		ctbE.Cmd = "./best-command"
+		return nil
+	}))
+
+	// Pin the mtime back, as a write within the same tick would leave it
+	require.NoError(t, os.Chtimes(path, before.ModTime(), before.ModTime()))
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, before.Size(), after.Size())
+
+	ctbE, err = ts.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "./best-command", ctbE.Cmd)
+}
+
+func Test_ItErrorsForMissingTasks(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	ts := NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+
+	_, err := ts.Get(id)
+	assert.ErrorIs(t, err, ErrCrontabEntryNotFound)
+
+	err = ts.Delete(id)
+	assert.ErrorIs(t, err, ErrCrontabEntryNotFound)
+
+	err = ts.Update(id, func(ctbE *CrontabEntry) error { return nil })
+	assert.ErrorIs(t, err, ErrCrontabEntryNotFound)
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+type EventType string
+
+const (
+	TASK_CREATED  EventType = "task.created"
+	TASK_REMOVED  EventType = "task.removed"
+	TASK_MODIFIED EventType = "task.modified"
+	// A crontab line failed its signature check and was quarantined
+	TASK_TAMPERED EventType = "task.tampered"
+	// A task was due while nothing was firing it. At is when it was due.
+	TASK_MISSED EventType = "task.missed"
+)
+
+// How many events a slow subscriber can fall behind before new ones are
+// dropped for it
+const subscriberBuffer = 64
+
+type Event struct {
+	Type   EventType `json:"type"`
+	TaskID uuid.UUID `json:"task_id"`
+	At     time.Time `json:"at"`
+	// Where the change was noticed, e.g. the crontab watcher
+	Source string `json:"source"`
+	// The entry after the change. Empty for removals.
+	Entry crontab.CrontabEntry `json:"entry,omitzero"`
+	// The entry before the change. Empty for creations.
+	Previous crontab.CrontabEntry `json:"previous,omitzero"`
+}
+
+type subscription struct {
+	types []EventType
+	ch    chan Event
+}
+
+// Fans events out to subscribers. Publishing never blocks, a subscriber
+// that is not keeping up misses events rather than stalling everyone else.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[int]subscription),
+	}
+}
+
+// Returns a channel receiving the given event types, or every type when none
+// are given, and a function that unsubscribes and closes the channel
+func (b *Bus) Subscribe(types ...EventType) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+
+	sub := subscription{
+		types: types,
+		ch:    make(chan Event, subscriberBuffer),
+	}
+	b.subs[id] = sub
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subs, id)
+			close(sub.ch)
+		})
+	}
+
+	return sub.ch, unsubscribe
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if len(sub.types) > 0 && !slices.Contains(sub.types, e.Type) {
+			continue
+		}
+
+		select {
+		case sub.ch <- e:
+		default:
+			slog.Warn("dropped event for slow subscriber",
+				slog.String("type", string(e.Type)),
+				slog.String("task_id", e.TaskID.String()),
+			)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ItDeliversEventsToMatchingSubscribers(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	all, unsubscribeAll := bus.Subscribe()
+	defer unsubscribeAll()
+
+	removed, unsubscribeRemoved := bus.Subscribe(TASK_REMOVED)
+	defer unsubscribeRemoved()
+
+	id, _ := uuid.NewV7()
+	bus.Publish(Event{Type: TASK_CREATED, TaskID: id})
+	bus.Publish(Event{Type: TASK_REMOVED, TaskID: id})
+
+	require.Len(t, all, 2)
+	assert.Equal(t, TASK_CREATED, (<-all).Type)
+	assert.Equal(t, TASK_REMOVED, (<-all).Type)
+
+	require.Len(t, removed, 1)
+	e := <-removed
+	assert.Equal(t, id, e.TaskID)
+	assert.False(t, e.At.IsZero())
+}
+
+func Test_ItStopsDeliveringAfterUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	evts, unsubscribe := bus.Subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	bus.Publish(Event{Type: TASK_CREATED})
+
+	_, open := <-evts
+	assert.False(t, open)
+}
@@ -1,6 +1,7 @@
 package coco_http
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
@@ -30,19 +31,29 @@ func CreateApp() *http.Server {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	res := resources.CreateResources()
+
 	a := &app{
-		logger:           logger,
-		resources:        resources.CreateResources(),
-		commandsRegistry: coco_cli.CommandRegistry,
+		logger:    logger,
+		resources: res,
+		// Built on the server's resources, so tasks run in-process share its
+		// task store and locks
+		commandsRegistry: coco_cli.NewCommandRegistry(res, "scheduler"),
 	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(customLoggingMiddleware(logger))
 
-	return &http.Server{
+	srv := &http.Server{
 		Addr:        ":3000",
 		Handler:     a.apiV1Router(r),
 		ReadTimeout: 5 * time.Second,
 	}
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	srv.RegisterOnShutdown(stopWorkers)
+	a.startWorkers(workersCtx)
+
+	return srv
 }
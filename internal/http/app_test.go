@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -22,6 +26,7 @@ import (
 	"github.com/captainmango/coco-cron-parser/internal/parser"
 	"github.com/captainmango/coco-cron-parser/internal/resources"
 	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
 )
 
 type mockAppWithResources struct {
@@ -67,6 +72,12 @@ func Test_handleGetScheduledTasks(t *testing.T) {
 				ID:   uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
 				Cron: parsedCron,
 				Cmd:  "cli start-game room1",
+				Meta: crontab.Metadata{
+					Name:      "Room one",
+					Tags:      []string{"league"},
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
 			},
 			{
 				ID:   uuid.MustParse("660e8400-e29b-41d4-a716-446655440001"),
@@ -94,6 +105,10 @@ func Test_handleGetScheduledTasks(t *testing.T) {
 		assert.Len(t, out.Data, 2)
 		assert.Equal(t, "cli start-game room1", out.Data[0].Command)
 		assert.Equal(t, "cli start-game room2", out.Data[1].Command)
+		assert.Equal(t, "Room one", out.Data[0].Name)
+		assert.Equal(t, []string{"league"}, out.Data[0].Tags)
+		assert.NotNil(t, out.Data[0].CreatedAt)
+		assert.Nil(t, out.Data[1].CreatedAt)
 		assert.Empty(t, out.Error)
 		mockApp.mockCrontab.AssertExpectations(t)
 	})
@@ -244,12 +259,219 @@ func Test_handleScheduleTask(t *testing.T) {
 		assert.NotEqual(t, uuid.Nil, out.Data.ID)
 		assert.Equal(t, "*/5 * * * *", out.Data.Cron)
 		assert.Equal(t, "cli start-game room123", out.Data.Command)
+		assert.Equal(t, map[string]string{resources.ARG_ROOM_ID: "room123"}, out.Data.Args)
 
 		mockApp.mockCommandRegistry.AssertExpectations(t)
 		mockApp.mockCrontab.AssertExpectations(t)
 		mockApp.mockQueue.AssertExpectations(t)
 	})
 
+	t.Run("schedules task with metadata", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		var written []crontab.CrontabEntry
+		mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				written = args.Get(0).([]crontab.CrontabEntry)
+			})
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"scheduled_time": "30 19 2 11 *",
+			"args": {"room_id": "123"},
+			"name": "Final table",
+			"description": "Starts the tournament final",
+			"owner": "tournaments",
+			"tags": ["finals", "season-3"]
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Final table", out.Data.Name)
+		assert.Equal(t, "tournaments", out.Data.Owner)
+		assert.Equal(t, []string{"finals", "season-3"}, out.Data.Tags)
+		assert.NotNil(t, out.Data.CreatedAt)
+		assert.NotNil(t, out.Data.UpdatedAt)
+
+		assert.Len(t, written, 1)
+		assert.Equal(t, "Starts the tournament final", written[0].Meta.Description)
+		assert.False(t, written[0].Meta.CreatedAt.IsZero())
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+
+	t.Run("schedules task with environment and redacts secrets", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		var written []crontab.CrontabEntry
+		mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				written = args.Get(0).([]crontab.CrontabEntry)
+			})
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"scheduled_time": "*/5 * * * *",
+			"args": {"room_id": "123"},
+			"env": [
+				{"name": "RABBITMQ_HOST", "value": "other-rabbit:5672/"},
+				{"name": "API_TOKEN", "value": "s3cret", "secret": true}
+			],
+			"work_dir": "/srv/coco"
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "/srv/coco", out.Data.WorkDir)
+		assert.Equal(t, []crontab.EnvVar{
+			{Name: "RABBITMQ_HOST", Value: "other-rabbit:5672/"},
+			{Name: "API_TOKEN", Value: crontab.REDACTED, Secret: true},
+		}, out.Data.Env)
+
+		assert.Len(t, written, 1)
+		assert.Equal(t, "s3cret", written[0].Env[1].Value)
+	})
+
+	t.Run("schedules task with a retry policy", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		var written []crontab.CrontabEntry
+		mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				written = args.Get(0).([]crontab.CrontabEntry)
+			})
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"run_at": "in 15m",
+			"args": {"room_id": "123"},
+			"retry": {"max_attempts": 4, "initial_delay": "10s", "max_delay": "1m", "jitter": 0.2}
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		expected := &crontab.RetryPolicy{MaxAttempts: 4, InitialDelay: 10 * time.Second, MaxDelay: time.Minute, Jitter: 0.2}
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out.Data.Retry)
+
+		assert.Len(t, written, 1)
+		assert.Equal(t, expected, written[0].Retry)
+	})
+
+	t.Run("rejects an invalid retry policy", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"scheduled_time": "*/5 * * * *",
+			"retry": {"max_attempts": 3}
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+
+	t.Run("rejects an unknown concurrency policy", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"scheduled_time": "*/5 * * * *",
+			"concurrency_policy": "Queue"
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+
+	t.Run("rejects an invalid environment variable", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		jsonBody := `{
+			"task_id": "start-game",
+			"scheduled_time": "*/5 * * * *",
+			"env": [{"name": "NOT-VALID", "value": "x"}]
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+
 	t.Run("returns error for invalid JSON", func(t *testing.T) {
 		mockApp := getMockApp(t)
 
@@ -451,6 +673,27 @@ func Test_handleRemoveTask(t *testing.T) {
 		mockApp.mockCrontab.AssertExpectations(t)
 	})
 
+	t.Run("returns not found when the task does not exist", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+		parsedUUID := uuid.MustParse(taskUUID)
+
+		mockApp.mockCrontab.On("RemoveCrontabEntryByID", parsedUUID).Return(crontab.ErrCrontabEntryNotFound)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/tasks/"+taskUUID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handleRemoveTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
 	t.Run("returns error for invalid UUID format", func(t *testing.T) {
 		mockApp := getMockApp(t)
 
@@ -476,7 +719,819 @@ func Test_handleRemoveTask(t *testing.T) {
 	})
 }
 
-func getMockApp(t *testing.T) *mockAppWithResources {
+func Test_handlePauseTask(t *testing.T) {
+	t.Run("pauses task successfully", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+		parsedUUID := uuid.MustParse(taskUUID)
+
+		cronExpr, _ := parser.NewParser(parser.WithInput("* * * * *", true))
+		parsedCron, _ := cronExpr.Parse()
+
+		mockApp.mockCrontab.On("PauseCrontabEntryByID", parsedUUID).Return(nil)
+		mockApp.mockCrontab.On("GetCrontabEntryByID", parsedUUID).Return(crontab.CrontabEntry{
+			ID:     parsedUUID,
+			Cron:   parsedCron,
+			Cmd:    "cli start-game room1",
+			Paused: true,
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+taskUUID+"/pause", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handlePauseTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, SCHEDULED_TASK, out.Type)
+		assert.Equal(t, parsedUUID, out.Data.ID)
+		assert.True(t, out.Data.Paused)
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+
+	t.Run("returns not found for unknown task", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+		parsedUUID := uuid.MustParse(taskUUID)
+
+		mockApp.mockCrontab.On("PauseCrontabEntryByID", parsedUUID).
+			Return(fmt.Errorf("%w with ID of %s", crontab.ErrCrontabEntryNotFound, taskUUID))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+taskUUID+"/pause", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handlePauseTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "error", out.Type)
+		assert.Contains(t, out.Error, "did not find crontab entry")
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+}
+
+func Test_handleResumeTask(t *testing.T) {
+	t.Run("resumes task successfully", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+		parsedUUID := uuid.MustParse(taskUUID)
+
+		cronExpr, _ := parser.NewParser(parser.WithInput("* * * * *", true))
+		parsedCron, _ := cronExpr.Parse()
+
+		mockApp.mockCrontab.On("ResumeCrontabEntryByID", parsedUUID).Return(nil)
+		mockApp.mockCrontab.On("GetCrontabEntryByID", parsedUUID).Return(crontab.CrontabEntry{
+			ID:   parsedUUID,
+			Cron: parsedCron,
+			Cmd:  "cli start-game room1",
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+taskUUID+"/resume", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handleResumeTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.False(t, out.Data.Paused)
+		assert.Equal(t, "* * * * *", out.Data.Cron)
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+}
+
+func Test_handleMaintenance(t *testing.T) {
+	t.Run("enables maintenance and reports it", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithMaintenance(filepath.Join(t.TempDir(), "maintenance.json"), resources.MAINTENANCE_REJECT))
+
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return([]crontab.CrontabEntry{
+			{
+				ID:  uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				Cmd: "cli start-game room1",
+			},
+		}, nil)
+
+		body := strings.NewReader(`{"enabled_by": "ops", "expires_at": "2099-01-01T00:00:00Z"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/maintenance", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleEnableMaintenance(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[MaintenanceResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, MAINTENANCE, out.Type)
+		assert.True(t, out.Data.Enabled)
+		assert.Equal(t, "ops", out.Data.EnabledBy)
+		assert.Equal(t, 1, out.Data.SuspendedTasks)
+		assert.NotNil(t, out.Data.EnabledAt)
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+
+	t.Run("returns conflict when disabling while not in maintenance", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithMaintenance(filepath.Join(t.TempDir(), "maintenance.json"), resources.MAINTENANCE_REJECT))
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/maintenance", nil)
+		w := httptest.NewRecorder()
+
+		mockApp.handleDisableMaintenance(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("rejects schedules while in maintenance", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithMaintenance(filepath.Join(t.TempDir(), "maintenance.json"), resources.MAINTENANCE_REJECT))
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return([]crontab.CrontabEntry{}, nil)
+
+		_, err := mockApp.resources.TaskResource.EnableMaintenance("ops", nil)
+		assert.NoError(t, err)
+
+		body := strings.NewReader(`{"task_id": "start-game", "scheduled_time": "* * * * *", "args": {"room_id": "1"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+}
+
+func Test_handleRevisions(t *testing.T) {
+	t.Run("rolls back and attributes the change to the actor", func(t *testing.T) {
+		rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+		mockApp := getMockApp(t, resources.WithRevisionHistory(rh))
+
+		taskID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+		cronExpr, _ := parser.NewParser(parser.WithInput("* * * * *", true))
+		parsedCron, _ := cronExpr.Parse()
+		entries := []crontab.CrontabEntry{
+			{ID: taskID, Cron: parsedCron, Cmd: "cli start-game room1"},
+		}
+
+		baseline, err := rh.Record("ops", "baseline", entries)
+		assert.NoError(t, err)
+
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return([]crontab.CrontabEntry{}, nil)
+		mockApp.mockCrontab.On("GetAllCrontabEntries").Return(entries, nil)
+
+		body := strings.NewReader(`{"reason": "bad bulk change"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/crontab/revisions/1/rollback", body)
+		req.Header.Set("X-Actor", "alice")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", fmt.Sprint(baseline.ID))
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handleRollbackRevision(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[RevisionResponse]
+		err = json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.Equal(t, REVISION, out.Type)
+		assert.Equal(t, baseline.ID, out.Data.ID)
+
+		revs, err := rh.List()
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", revs[0].Author)
+		assert.Equal(t, "bad bulk change", revs[0].Reason)
+		mockApp.mockCrontab.AssertExpectations(t)
+	})
+
+	t.Run("diffs two revisions", func(t *testing.T) {
+		rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+		mockApp := getMockApp(t, resources.WithRevisionHistory(rh))
+
+		taskID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+		cronExpr, _ := parser.NewParser(parser.WithInput("* * * * *", true))
+		parsedCron, _ := cronExpr.Parse()
+		entry := crontab.CrontabEntry{ID: taskID, Cron: parsedCron, Cmd: "cli start-game room1"}
+
+		_, err := rh.Record("ops", "empty", nil)
+		assert.NoError(t, err)
+		_, err = rh.Record("ops", "added", []crontab.CrontabEntry{entry})
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/crontab/revisions/diff?from=1&to=2", nil)
+		w := httptest.NewRecorder()
+
+		mockApp.handleDiffRevisions(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[RevisionDiffResponse]
+		err = json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.Len(t, out.Data.Lines, 1)
+		assert.True(t, strings.HasPrefix(out.Data.Lines[0], "+ "))
+		assert.Contains(t, out.Data.Lines[0], taskID.String())
+	})
+
+	t.Run("returns not found for an unknown revision", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithRevisionHistory(crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/crontab/revisions/9", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "9")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		mockApp.handleGetRevision(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
+func Test_handleImportCrontab(t *testing.T) {
+	t.Run("shows what a dry run would write", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		body := strings.NewReader(`{"format": "user", "dry_run": true, "crontab": "0 3 * * * /usr/bin/backup\nMAILTO=ops\n"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/import", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleImportCrontab(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[ImportResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, IMPORT_PLAN, out.Type)
+		assert.True(t, out.Data.DryRun)
+		assert.Len(t, out.Data.Accepted, 1)
+		assert.Contains(t, out.Data.Accepted[0].Line, "0 3 * * * root COCO_TASK_ID=")
+		assert.Contains(t, out.Data.Accepted[0].Line, " /usr/bin/backup 2>&1")
+		assert.Equal(t, "/usr/bin/backup", out.Data.Accepted[0].Task.Command)
+		assert.Len(t, out.Data.Rejected, 1)
+		assert.Equal(t, 2, out.Data.Rejected[0].LineNo)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+
+	t.Run("rejects an unknown format", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		body := strings.NewReader(`{"format": "bsd", "crontab": ""}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/import", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleImportCrontab(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	})
+}
+
+func Test_handleScheduleOneShotTask(t *testing.T) {
+	t.Run("schedules a task that runs once", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+		mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+		body := strings.NewReader(`{"task_id": "start-game", "run_at": "in 15m", "args": {"room_id": "1"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		if assert.NotNil(t, out.Data.RunAt) {
+			assert.WithinDuration(t, time.Now().Add(15*time.Minute), *out.Data.RunAt, time.Minute)
+		}
+	})
+
+	t.Run("rejects both a cron and a run time", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		body := strings.NewReader(`{"task_id": "start-game", "scheduled_time": "* * * * *", "run_at": "in 15m", "args": {"room_id": "1"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		mockApp.mockCrontab.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+	})
+}
+
+func Test_handleScheduleTaskIdempotently(t *testing.T) {
+	mockApp := getMockApp(t, resources.WithIdempotency(filepath.Join(t.TempDir(), "idempotency.json"), time.Hour))
+
+	mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+		Name: "start-game",
+	}, nil)
+	mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).Return(nil)
+	mockApp.mockCrontab.On("GetCrontabEntryByID", mock.Anything).Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound)
+
+	var ids []uuid.UUID
+	for _, want := range []int{http.StatusAccepted, http.StatusOK} {
+		body := strings.NewReader(`{"task_id": "start-game", "scheduled_time": "0 19 * * *", "args": {"room_id": "1"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", body)
+		req.Header.Set("Idempotency-Key", "game-1")
+		w := httptest.NewRecorder()
+
+		mockApp.handleScheduleTask(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, want, res.StatusCode)
+
+		var out Response[ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		ids = append(ids, out.Data.ID)
+	}
+
+	assert.Equal(t, ids[0], ids[1])
+	mockApp.mockCrontab.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+func Test_handleBatchTasks(t *testing.T) {
+	t.Run("schedules and removes in one write", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		removedID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+		written := []crontab.CrontabEntry{
+			{ID: removedID, Cmd: "cli start-game room1"},
+		}
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return(&written, nil)
+
+		body := strings.NewReader(`{
+			"schedule": [
+				{"task_id": "start-game", "scheduled_time": "0 19 * * *", "args": {"room_id": "1"}},
+				{"task_id": "start-game", "scheduled_time": "30 19 * * *", "args": {"room_id": "2"}}
+			],
+			"remove": ["550e8400-e29b-41d4-a716-446655440000"]
+		}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks:batch", body)
+		w := httptest.NewRecorder()
+
+		mockApp.apiV1Router(chi.NewRouter()).ServeHTTP(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		var out Response[BatchResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.Equal(t, BATCH, out.Type)
+		assert.True(t, out.Data.Committed)
+		assert.Len(t, out.Data.Items, 3)
+		assert.Len(t, written, 2)
+		assert.Equal(t, "cli start-game 1", written[0].Cmd)
+		mockApp.mockCrontab.AssertNumberOfCalls(t, "UpdateCrontabEntries", 1)
+	})
+
+	t.Run("rejects the batch when a cron is invalid", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		written := []crontab.CrontabEntry{}
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return(&written, nil)
+
+		body := strings.NewReader(`{"schedule": [
+			{"task_id": "start-game", "scheduled_time": "0 19 * * *", "args": {"room_id": "1"}},
+			{"task_id": "start-game", "scheduled_time": "not a cron", "args": {"room_id": "2"}}
+		]}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks:batch", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleBatchTasks(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		var out Response[BatchResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+
+		assert.False(t, out.Data.Committed)
+		assert.Empty(t, out.Data.Items[0].Error)
+		assert.NotEmpty(t, out.Data.Items[1].Error)
+		assert.Empty(t, written)
+	})
+
+	t.Run("keeps the policies of each item", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCommandRegistry.On("Find").Return(&cli.Command{
+			Name: "start-game",
+		}, nil)
+
+		written := []crontab.CrontabEntry{}
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return(&written, nil)
+
+		body := strings.NewReader(`{"schedule": [
+			{"task_id": "start-game", "scheduled_time": "0 19 * * *", "args": {"room_id": "1"},
+				"retry": {"max_attempts": 3, "initial_delay": "30s"}, "concurrency_policy": "Forbid",
+				"misfire_policy": "run_once"},
+			{"task_id": "start-game", "run_at": "in 15m", "args": {"room_id": "2"}}
+		]}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks:batch", body)
+		w := httptest.NewRecorder()
+
+		mockApp.handleBatchTasks(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		assert.Len(t, written, 2)
+		assert.Equal(t, &crontab.RetryPolicy{MaxAttempts: 3, InitialDelay: 30 * time.Second}, written[0].Retry)
+		assert.Equal(t, crontab.CONCURRENCY_FORBID, written[0].ConcurrencyPolicy)
+		assert.Equal(t, crontab.MISFIRE_RUN_ONCE, written[0].MisfirePolicy)
+		assert.Nil(t, written[0].RunAt)
+		assert.NotNil(t, written[1].RunAt, "run_at schedules a one-shot task")
+	})
+}
+
+type mockDriftCrontab struct {
+	*mocks.MockCrontabHandler
+	report crontab.DriftReport
+}
+
+func (m mockDriftCrontab) DetectDrift() (crontab.DriftReport, error) {
+	return m.report, nil
+}
+
+func (m mockDriftCrontab) RepairDrift() (crontab.DriftReport, error) {
+	report := m.report
+	report.Repaired = true
+
+	return report, nil
+}
+
+func Test_handleGetDrift(t *testing.T) {
+	t.Run("reports drift between the task store and the crontab", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		taskID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+		mockApp.resources.TaskResource = resources.CreateTaskResource(
+			mockDriftCrontab{
+				MockCrontabHandler: mockApp.mockCrontab,
+				report: crontab.DriftReport{
+					Items: []crontab.DriftItem{{Kind: crontab.DRIFT_REMOVED, ID: taskID}},
+				},
+			},
+			mockApp.mockQueue,
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/drift", nil)
+		w := httptest.NewRecorder()
+
+		mockApp.handleGetDrift(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[crontab.DriftReport]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.Equal(t, DRIFT_REPORT, out.Type)
+		assert.Len(t, out.Data.Items, 1)
+		assert.Equal(t, crontab.DRIFT_REMOVED, out.Data.Items[0].Kind)
+		assert.Equal(t, taskID, out.Data.Items[0].ID)
+		assert.False(t, out.Data.Repaired)
+	})
+
+	t.Run("errors when the handler cannot detect drift", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/drift", nil)
+		w := httptest.NewRecorder()
+
+		mockApp.handleGetDrift(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	})
+}
+
+func Test_handleRoomTasks(t *testing.T) {
+	roomTask := crontab.CrontabEntry{
+		ID:   uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		Cmd:  "cli start-game 123",
+		Args: map[string]string{resources.ARG_ROOM_ID: "123"},
+	}
+	otherTask := crontab.CrontabEntry{
+		ID:   uuid.MustParse("660e8400-e29b-41d4-a716-446655440001"),
+		Cmd:  "cli start-game 456",
+		Args: map[string]string{resources.ARG_ROOM_ID: "456"},
+	}
+
+	roomRequest := func(method string) *http.Request {
+		req := httptest.NewRequest(method, "/api/v1/rooms/123/tasks", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("room_id", "123")
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("lists the tasks for a room", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCrontab.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{roomTask, otherTask}, nil)
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetRoomTasks(w, roomRequest(http.MethodGet))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[[]ScheduledTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.Len(t, out.Data, 1)
+		assert.Equal(t, roomTask.ID, out.Data[0].ID)
+	})
+
+	t.Run("cancels the tasks for a room", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		mockApp.mockCrontab.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{roomTask, otherTask}, nil)
+
+		written := []crontab.CrontabEntry{roomTask, otherTask}
+		mockApp.mockCrontab.On("UpdateCrontabEntries").Return(&written, nil)
+
+		w := httptest.NewRecorder()
+		mockApp.handleCancelRoomTasks(w, roomRequest(http.MethodDelete))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out Response[BatchResponse]
+		err := json.NewDecoder(res.Body).Decode(&out)
+		assert.NoError(t, err)
+		assert.True(t, out.Data.Committed)
+		assert.Len(t, out.Data.Items, 1)
+		assert.Equal(t, roomTask.ID, out.Data.Items[0].ID)
+		assert.Equal(t, []crontab.CrontabEntry{otherTask}, written)
+	})
+}
+
+func Test_handleArchivedTasks(t *testing.T) {
+	cronExpr, _ := parser.NewParser(parser.WithInput("*/5 * * * *", true))
+	parsedCron, _ := cronExpr.Parse()
+
+	taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+	task := crontab.CrontabEntry{
+		ID:   uuid.MustParse(taskUUID),
+		Cron: parsedCron,
+		Cmd:  "cli start-game 123",
+	}
+
+	uuidRequest := func(method, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("lists and restores a removed task", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithArchive(filepath.Join(t.TempDir(), "archive.json"), time.Hour))
+
+		mockApp.mockCrontab.On("GetCrontabEntryByID", task.ID).Return(task, nil).Once()
+		mockApp.mockCrontab.On("RemoveCrontabEntryByID", task.ID).Return(nil)
+
+		w := httptest.NewRecorder()
+		mockApp.handleRemoveTask(w, uuidRequest(http.MethodDelete, "/api/v1/tasks/"+taskUUID))
+		assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+
+		w = httptest.NewRecorder()
+		mockApp.handleGetArchivedTasks(w, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/archive", nil))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var archived Response[[]ArchivedTaskResponse]
+		err := json.NewDecoder(res.Body).Decode(&archived)
+		assert.NoError(t, err)
+		assert.Equal(t, ARCHIVED_TASK, archived.Type)
+		assert.Len(t, archived.Data, 1)
+		assert.Equal(t, task.ID, archived.Data[0].Task.ID)
+
+		mockApp.mockCrontab.On("GetCrontabEntryByID", task.ID).
+			Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound).Once()
+		mockApp.mockCrontab.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+		w = httptest.NewRecorder()
+		mockApp.handleRestoreTask(w, uuidRequest(http.MethodPost, "/api/v1/tasks/archive/"+taskUUID+"/restore"))
+		res = w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var restored Response[ScheduledTaskResponse]
+		err = json.NewDecoder(res.Body).Decode(&restored)
+		assert.NoError(t, err)
+		assert.Equal(t, task.ID, restored.Data.ID)
+		assert.Equal(t, "*/5 * * * *", restored.Data.Cron)
+	})
+
+	t.Run("returns not found for a task that is not archived", func(t *testing.T) {
+		mockApp := getMockApp(t, resources.WithArchive(filepath.Join(t.TempDir(), "archive.json"), time.Hour))
+
+		w := httptest.NewRecorder()
+		mockApp.handleRestoreTask(w, uuidRequest(http.MethodPost, "/api/v1/tasks/archive/"+taskUUID+"/restore"))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
+func Test_handleGetTaskLogs(t *testing.T) {
+	taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+
+	logsRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", taskUUID)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("returns the last lines of a task's log", func(t *testing.T) {
+		mockApp := getMockApp(t)
+		mockApp.resources.Logs = tasklog.NewLogs(t.TempDir())
+
+		path := tasklog.Path(mockApp.resources.Logs.Dir(), uuid.MustParse(taskUUID))
+		err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644)
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetTaskLogs(w, logsRequest("/api/v1/tasks/"+taskUUID+"/logs?tail=2"))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var body Response[TaskLogResponse]
+		err = json.NewDecoder(res.Body).Decode(&body)
+		assert.NoError(t, err)
+		assert.Equal(t, TASK_LOG, body.Type)
+		assert.Equal(t, []string{"two", "three"}, body.Data.Lines)
+	})
+
+	t.Run("returns not found when the task has not logged anything", func(t *testing.T) {
+		mockApp := getMockApp(t)
+		mockApp.resources.Logs = tasklog.NewLogs(t.TempDir())
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetTaskLogs(w, logsRequest("/api/v1/tasks/"+taskUUID+"/logs"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("rejects an invalid tail", func(t *testing.T) {
+		mockApp := getMockApp(t)
+		mockApp.resources.Logs = tasklog.NewLogs(t.TempDir())
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetTaskLogs(w, logsRequest("/api/v1/tasks/"+taskUUID+"/logs?tail=-1"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func Test_handleGetTaskRuns(t *testing.T) {
+	taskUUID := "550e8400-e29b-41d4-a716-446655440000"
+
+	runsRequest := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+id+"/runs", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", id)
+
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("returns the task's runs newest first", func(t *testing.T) {
+		rh := crontab.NewRunHistory(filepath.Join(t.TempDir(), "runs.json"), 10, 0)
+		mockApp := getMockApp(t, resources.WithRunHistory(rh))
+
+		scheduledAt := time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)
+		for _, err := range []error{nil, errors.New("rabbitmq unavailable")} {
+			run := crontab.NewRun(uuid.MustParse(taskUUID), crontab.RUN_SOURCE_CLI, scheduledAt)
+			run.Finish(err, "pushed start game message\n")
+			assert.NoError(t, mockApp.resources.TaskResource.RecordTaskRun(run))
+		}
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetTaskRuns(w, runsRequest(taskUUID))
+		res := w.Result()
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var body Response[[]TaskRunResponse]
+		err := json.NewDecoder(res.Body).Decode(&body)
+		assert.NoError(t, err)
+		assert.Equal(t, TASK_RUN, body.Type)
+		assert.Len(t, body.Data, 2)
+		assert.Equal(t, crontab.RUN_FAILED, body.Data[0].Outcome)
+		assert.Equal(t, 1, body.Data[0].ExitCode)
+		assert.Equal(t, "rabbitmq unavailable", body.Data[0].Error)
+		assert.Equal(t, crontab.RUN_SUCCEEDED, body.Data[1].Outcome)
+		assert.Equal(t, scheduledAt, body.Data[1].ScheduledAt)
+		assert.Equal(t, "pushed start game message\n", body.Data[1].Output)
+	})
+
+	t.Run("rejects an invalid uuid", func(t *testing.T) {
+		mockApp := getMockApp(t)
+
+		w := httptest.NewRecorder()
+		mockApp.handleGetTaskRuns(w, runsRequest("not-a-uuid"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func getMockApp(t *testing.T, opts ...resources.TaskResourceOptFn) *mockAppWithResources {
 	mockCrontab := &mocks.MockCrontabHandler{}
 	mockQueue := &mocks.MockQueueHandler{}
 	mockCommandRegistry := &coco_cli_mock.MockCommandRegistry{}
@@ -487,6 +1542,7 @@ func getMockApp(t *testing.T) *mockAppWithResources {
 			TaskResource: resources.CreateTaskResource(
 				mockCrontab,
 				mockQueue,
+				opts...,
 			),
 		},
 		commandsRegistry: mockCommandRegistry,
@@ -1,16 +1,148 @@
 package coco_http
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources"
+)
 
 const (
 	SCHEDULED_TASK = "scheduled_task" // refers to the type the client will receive
 	TASK           = "task"
+	MAINTENANCE    = "maintenance"
+	REVISION       = "revision"
+	REVISION_DIFF  = "revision_diff"
+	DRIFT_REPORT   = "drift_report"
+	IMPORT_PLAN    = "import_plan"
+	BATCH          = "batch"
+	ARCHIVED_TASK  = "archived_task"
+	TASK_LOG       = "task_log"
+	TASK_RUN       = "task_run"
 )
 
 type ScheduledTaskResponse struct {
-	ID      uuid.UUID `json:"id"`
-	Command string    `json:"command"`
-	Cron    string    `json:"cron"`
+	ID          uuid.UUID  `json:"id"`
+	Command     string     `json:"command"`
+	Cron        string     `json:"cron"`
+	Paused      bool       `json:"paused"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Owner       string     `json:"owner"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	RunAt       *time.Time `json:"run_at"`
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at"`
+	MaxRuns     int        `json:"max_runs"`
+	Runs        int        `json:"runs"`
+	// Null when the task has no run limit
+	RemainingRuns *int              `json:"remaining_runs"`
+	Args          map[string]string `json:"args"`
+	// Secret values are redacted
+	Env     []crontab.EnvVar     `json:"env"`
+	WorkDir string               `json:"work_dir"`
+	Retry   *crontab.RetryPolicy `json:"retry"`
+	// Empty when runs may overlap
+	ConcurrencyPolicy string `json:"concurrency_policy"`
+	// Empty when missed runs are skipped
+	MisfirePolicy string `json:"misfire_policy"`
+}
+
+func NewScheduledTaskResponse(ctbE crontab.CrontabEntry) ScheduledTaskResponse {
+	ctbE = ctbE.Redacted()
+
+	res := ScheduledTaskResponse{
+		ID:                ctbE.ID,
+		Command:           ctbE.Cmd,
+		Cron:              ctbE.Cron.String(),
+		Paused:            ctbE.Paused,
+		Name:              ctbE.Meta.Name,
+		Description:       ctbE.Meta.Description,
+		Owner:             ctbE.Meta.Owner,
+		Tags:              ctbE.Meta.Tags,
+		RunAt:             ctbE.RunAt,
+		StartAt:           ctbE.StartAt,
+		EndAt:             ctbE.EndAt,
+		MaxRuns:           ctbE.MaxRuns,
+		Runs:              ctbE.Runs,
+		Args:              ctbE.Args,
+		Env:               ctbE.Env,
+		WorkDir:           ctbE.WorkDir,
+		Retry:             ctbE.Retry,
+		ConcurrencyPolicy: ctbE.ConcurrencyPolicy,
+		MisfirePolicy:     ctbE.MisfirePolicy,
+	}
+
+	if remaining, ok := ctbE.RemainingRuns(); ok {
+		res.RemainingRuns = &remaining
+	}
+
+	// Entries written before metadata existed have no timestamps
+	if !ctbE.Meta.CreatedAt.IsZero() {
+		res.CreatedAt = &ctbE.Meta.CreatedAt
+	}
+
+	if !ctbE.Meta.UpdatedAt.IsZero() {
+		res.UpdatedAt = &ctbE.Meta.UpdatedAt
+	}
+
+	return res
+}
+
+type ArchivedTaskResponse struct {
+	Task      ScheduledTaskResponse `json:"task"`
+	DeletedAt time.Time             `json:"deleted_at"`
+	DeletedBy string                `json:"deleted_by"`
+	Reason    string                `json:"reason,omitempty"`
+}
+
+func NewArchivedTaskResponse(task resources.ArchivedTask) ArchivedTaskResponse {
+	return ArchivedTaskResponse{
+		Task:      NewScheduledTaskResponse(task.Entry),
+		DeletedAt: task.DeletedAt,
+		DeletedBy: task.DeletedBy,
+		Reason:    task.Reason,
+	}
+}
+
+type TaskLogResponse struct {
+	TaskID uuid.UUID `json:"task_id"`
+	Lines  []string  `json:"lines"`
+}
+
+type TaskRunResponse struct {
+	ID          uuid.UUID          `json:"id"`
+	TaskID      uuid.UUID          `json:"task_id"`
+	Source      string             `json:"source"`
+	ScheduledAt time.Time          `json:"scheduled_at"`
+	StartedAt   time.Time          `json:"started_at"`
+	EndedAt     time.Time          `json:"ended_at"`
+	DurationMs  int64              `json:"duration_ms"`
+	Outcome     crontab.RunOutcome `json:"outcome"`
+	ExitCode    int                `json:"exit_code"`
+	Error       string             `json:"error"`
+	Output      string             `json:"output"`
+}
+
+func NewTaskRunResponse(run crontab.Run) TaskRunResponse {
+	return TaskRunResponse{
+		ID:          run.ID,
+		TaskID:      run.TaskID,
+		Source:      run.Source,
+		ScheduledAt: run.ScheduledAt,
+		StartedAt:   run.StartedAt,
+		EndedAt:     run.EndedAt,
+		DurationMs:  run.Duration().Milliseconds(),
+		Outcome:     run.Outcome,
+		ExitCode:    run.ExitCode,
+		Error:       run.Error,
+		Output:      run.Output,
+	}
 }
 
 type TaskResponse struct {
@@ -24,4 +156,213 @@ type ScheduleTaskRequest struct {
 	Args          struct {
 		RoomId string `json:"room_id"`
 	} `json:"args"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Schedules a one-shot task instead, an RFC 3339 time or "in 15m"
+	RunAt string `json:"run_at,omitempty"`
+
+	// Optional window a recurring task fires in, and a cap on its runs
+	StartAt *time.Time `json:"start_at,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+	MaxRuns int        `json:"max_runs,omitempty"`
+
+	// Set in the command's environment and the directory it runs from
+	Env     []crontab.EnvVar `json:"env,omitempty"`
+	WorkDir string           `json:"work_dir,omitempty"`
+
+	// How failed runs are retried, e.g. {"max_attempts": 3, "initial_delay": "30s"}
+	Retry *crontab.RetryPolicy `json:"retry,omitempty"`
+
+	// Allow, Forbid or Replace a run while the last one is still going
+	ConcurrencyPolicy string `json:"concurrency_policy,omitempty"`
+
+	// What happens to runs missed while the service was down: skip, run_once
+	// or run_all
+	MisfirePolicy string `json:"misfire_policy,omitempty"`
+}
+
+// The command line written to the crontab for the named task
+func (str ScheduleTaskRequest) Command(name string) string {
+	return fmt.Sprintf("cli %s %s", name, str.Args.RoomId)
+}
+
+// The structured arguments stored with the task, so it can be looked up by
+// room later
+func (str ScheduleTaskRequest) TaskArgs() map[string]string {
+	if str.Args.RoomId == "" {
+		return nil
+	}
+
+	return map[string]string{resources.ARG_ROOM_ID: str.Args.RoomId}
+}
+
+func (str ScheduleTaskRequest) Metadata() crontab.Metadata {
+	return crontab.Metadata{
+		Name:        str.Name,
+		Description: str.Description,
+		Owner:       str.Owner,
+		Tags:        str.Tags,
+	}
+}
+
+type MaintenanceRequest struct {
+	EnabledBy string     `json:"enabled_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type MaintenanceResponse struct {
+	Enabled        bool       `json:"enabled"`
+	EnabledBy      string     `json:"enabled_by"`
+	EnabledAt      *time.Time `json:"enabled_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	SuspendedTasks int        `json:"suspended_tasks"`
+}
+
+func NewMaintenanceResponse(state resources.MaintenanceState) MaintenanceResponse {
+	res := MaintenanceResponse{
+		Enabled:        state.Enabled,
+		EnabledBy:      state.EnabledBy,
+		ExpiresAt:      state.ExpiresAt,
+		SuspendedTasks: len(state.SuspendedIDs),
+	}
+
+	if !state.EnabledAt.IsZero() {
+		res.EnabledAt = &state.EnabledAt
+	}
+
+	return res
+}
+
+type RevisionResponse struct {
+	ID        int                     `json:"id"`
+	Author    string                  `json:"author"`
+	Reason    string                  `json:"reason"`
+	CreatedAt time.Time               `json:"created_at"`
+	Tasks     []ScheduledTaskResponse `json:"tasks"`
+}
+
+func NewRevisionResponse(rev crontab.Revision) RevisionResponse {
+	res := RevisionResponse{
+		ID:        rev.ID,
+		Author:    rev.Author,
+		Reason:    rev.Reason,
+		CreatedAt: rev.CreatedAt,
+		Tasks:     []ScheduledTaskResponse{},
+	}
+
+	for _, ctbE := range rev.Entries {
+		res.Tasks = append(res.Tasks, NewScheduledTaskResponse(ctbE))
+	}
+
+	return res
+}
+
+type RevisionDiffResponse struct {
+	From  int      `json:"from"`
+	To    int      `json:"to"`
+	Lines []string `json:"lines"`
+}
+
+func NewRevisionDiffResponse(from, to int, diff []crontab.DiffLine) RevisionDiffResponse {
+	res := RevisionDiffResponse{
+		From:  from,
+		To:    to,
+		Lines: []string{},
+	}
+
+	for _, line := range diff {
+		res.Lines = append(res.Lines, line.String())
+	}
+
+	return res
+}
+
+type RollbackRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImportCrontabRequest struct {
+	Format  string `json:"format"`
+	Crontab string `json:"crontab"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+type ImportedLineResponse struct {
+	LineNo int                   `json:"line_no"`
+	Source string                `json:"source"`
+	Line   string                `json:"line"`
+	Task   ScheduledTaskResponse `json:"task"`
+}
+
+type ImportResponse struct {
+	Format   string                 `json:"format"`
+	DryRun   bool                   `json:"dry_run"`
+	Accepted []ImportedLineResponse `json:"accepted"`
+	Rejected []crontab.RejectedLine `json:"rejected"`
+}
+
+func NewImportResponse(plan crontab.ImportPlan, dryRun bool) ImportResponse {
+	res := ImportResponse{
+		Format:   plan.Format,
+		DryRun:   dryRun,
+		Accepted: []ImportedLineResponse{},
+		Rejected: plan.Rejected,
+	}
+
+	for _, item := range plan.Accepted {
+		res.Accepted = append(res.Accepted, ImportedLineResponse{
+			LineNo: item.LineNo,
+			Source: item.Source,
+			Line:   item.Line,
+			Task:   NewScheduledTaskResponse(item.Entry),
+		})
+	}
+
+	return res
+}
+
+type BatchTaskRequest struct {
+	Schedule []ScheduleTaskRequest `json:"schedule"`
+	Remove   []uuid.UUID           `json:"remove"`
+}
+
+type BatchItemResponse struct {
+	Op    string                 `json:"op"`
+	Index int                    `json:"index"`
+	ID    uuid.UUID              `json:"id"`
+	Task  *ScheduledTaskResponse `json:"task"`
+	Error string                 `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool                `json:"committed"`
+	Items     []BatchItemResponse `json:"items"`
+}
+
+func NewBatchResponse(result resources.BatchResult) BatchResponse {
+	res := BatchResponse{
+		Committed: result.Committed,
+		Items:     []BatchItemResponse{},
+	}
+
+	for _, item := range result.Items {
+		out := BatchItemResponse{
+			Op:    item.Op,
+			Index: item.Index,
+			ID:    item.ID,
+			Error: item.Error,
+		}
+
+		if item.Entry.ID != uuid.Nil {
+			task := NewScheduledTaskResponse(item.Entry)
+			out.Task = &task
+		}
+
+		res.Items = append(res.Items, out)
+	}
+
+	return res
 }
@@ -1,12 +1,26 @@
 package coco_http
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources"
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
+)
+
+var (
+	errInvalidRevisionID = errors.New("revision id must be a number")
+	errScheduleAmbiguous = errors.New("set either scheduled_time or run_at, not both")
+	errInvalidTail       = errors.New("tail must be a number of lines")
+	errInvalidFollow     = errors.New("follow must be true or false")
 )
 
 func (a *app) handleLivez(w http.ResponseWriter, r *http.Request) {
@@ -35,13 +49,7 @@ func (a *app) handleGetScheduledTasks(w http.ResponseWriter, r *http.Request) {
 	var out []ScheduledTaskResponse
 
 	for _, item := range entries {
-		i := ScheduledTaskResponse{
-			ID:      item.ID,
-			Command: item.Cmd,
-			Cron:    item.Cron.String(),
-		}
-
-		out = append(out, i)
+		out = append(out, NewScheduledTaskResponse(item))
 	}
 
 	res := NewResponse(
@@ -93,28 +101,185 @@ func (a *app) handleScheduleTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cmdStringBuilder strings.Builder
-	fmt.Fprintf(&cmdStringBuilder, "cli %s ", cmd.Name)
-	fmt.Fprintf(&cmdStringBuilder, "%s", input.Args.RoomId)
+	if input.RunAt != "" && input.ScheduledTime != "" {
+		res := NewResponse(WithError(errScheduleAmbiguous, ScheduledTaskResponse{}))
+		a.writeJSON(w, http.StatusUnprocessableEntity, res, nil)
+		return
+	}
+
+	schedule := a.taskResource(r).ScheduleTaskOnce
+	when := input.ScheduledTime
+	if input.RunAt != "" {
+		schedule = a.taskResource(r).ScheduleOneShotTaskOnce
+		when = input.RunAt
+	}
 
-	id, err := a.resources.TaskResource.ScheduleTask(input.ScheduledTime, cmdStringBuilder.String())
+	ctbE, created, err := schedule(
+		strings.TrimSpace(r.Header.Get("Idempotency-Key")),
+		when,
+		input.Command(cmd.Name),
+		resources.WithMetadata(input.Metadata()),
+		resources.WithValidity(input.StartAt, input.EndAt),
+		resources.WithMaxRuns(input.MaxRuns),
+		resources.WithArgs(input.TaskArgs()),
+		resources.WithEnv(input.Env),
+		resources.WithWorkDir(input.WorkDir),
+		resources.WithRetryPolicy(input.Retry),
+		resources.WithConcurrencyPolicy(input.ConcurrencyPolicy),
+		resources.WithMisfirePolicy(input.MisfirePolicy),
+	)
 	if err != nil {
+		status := http.StatusUnprocessableEntity
+		switch {
+		case errors.Is(err, resources.ErrMaintenanceActive):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, resources.ErrDuplicateTask):
+			status = http.StatusConflict
+		}
+
 		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
-		a.writeJSON(w, http.StatusUnprocessableEntity, res, nil)
+		a.writeJSON(w, status, res, nil)
 		return
 	}
 
-	scheduledTaskDto := ScheduledTaskResponse{
-		ID:      id,
-		Command: cmdStringBuilder.String(),
-		Cron:    input.ScheduledTime,
+	res := NewResponse(WithData(SCHEDULED_TASK, NewScheduledTaskResponse(ctbE)))
+
+	// A retried or duplicate request gets the task that already exists
+	status := http.StatusAccepted
+	if !created {
+		status = http.StatusOK
 	}
 
-	res := NewResponse(WithData(SCHEDULED_TASK, scheduledTaskDto))
+	a.writeJSON(w, status, res, nil)
+}
+
+func (a *app) handleBatchTasks(w http.ResponseWriter, r *http.Request) {
+	var input BatchTaskRequest
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		res := NewResponse(WithError(err, BatchResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	batch := resources.Batch{Remove: input.Remove}
+	lookupFailed := resources.BatchResult{Items: []resources.BatchItemResult{}}
+
+	for i, item := range input.Schedule {
+		cmd, err := a.commandsRegistry.Find(item.TaskId)
+		if err != nil {
+			lookupFailed.Items = append(lookupFailed.Items, resources.BatchItemResult{
+				Op:    resources.BATCH_SCHEDULE,
+				Index: i,
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		batch.Schedule = append(batch.Schedule, resources.BatchScheduleItem{
+			Cron:              item.ScheduledTime,
+			RunAt:             item.RunAt,
+			Cmd:               item.Command(cmd.Name),
+			Name:              item.Name,
+			Description:       item.Description,
+			Owner:             item.Owner,
+			Tags:              item.Tags,
+			StartAt:           item.StartAt,
+			EndAt:             item.EndAt,
+			MaxRuns:           item.MaxRuns,
+			Args:              item.TaskArgs(),
+			Env:               item.Env,
+			WorkDir:           item.WorkDir,
+			Retry:             item.Retry,
+			ConcurrencyPolicy: item.ConcurrencyPolicy,
+			MisfirePolicy:     item.MisfirePolicy,
+		})
+	}
+
+	// Unknown tasks fail the whole batch before anything is validated further
+	if len(lookupFailed.Items) > 0 {
+		res := NewResponse(WithError(resources.ErrBatchRejected, NewBatchResponse(lookupFailed)))
+		a.writeJSON(w, http.StatusUnprocessableEntity, res, nil)
+		return
+	}
+
+	result, err := a.taskResource(r).ApplyBatch(batch)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, resources.ErrMaintenanceActive) {
+			status = http.StatusServiceUnavailable
+		}
+
+		res := NewResponse(WithError(err, NewBatchResponse(result)))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
 
+	res := NewResponse(WithData(BATCH, NewBatchResponse(result)))
 	a.writeJSON(w, http.StatusAccepted, res, nil)
 }
 
+func (a *app) handleImportCrontab(w http.ResponseWriter, r *http.Request) {
+	var input ImportCrontabRequest
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		res := NewResponse(WithError(err, ImportResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	plan, err := a.taskResource(r).ImportCrontab(strings.NewReader(input.Crontab), input.Format, input.DryRun)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, resources.ErrMaintenanceActive) {
+			status = http.StatusServiceUnavailable
+		}
+
+		res := NewResponse(WithError(err, ImportResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	status := http.StatusAccepted
+	if input.DryRun {
+		status = http.StatusOK
+	}
+
+	res := NewResponse(WithData(IMPORT_PLAN, NewImportResponse(plan, input.DryRun)))
+	a.writeJSON(w, status, res, nil)
+}
+
+func (a *app) handleGetRoomTasks(w http.ResponseWriter, r *http.Request) {
+	entries, err := a.resources.TaskResource.GetRoomTasks(chi.URLParam(r, "room_id"))
+	if err != nil {
+		res := NewResponse(WithError(err, []ScheduledTaskResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	out := []ScheduledTaskResponse{}
+	for _, item := range entries {
+		out = append(out, NewScheduledTaskResponse(item))
+	}
+
+	res := NewResponse(WithData(SCHEDULED_TASK, out))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleCancelRoomTasks(w http.ResponseWriter, r *http.Request) {
+	result, err := a.taskResource(r).CancelRoomTasks(chi.URLParam(r, "room_id"))
+	if err != nil {
+		res := NewResponse(WithError(err, NewBatchResponse(result)))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(BATCH, NewBatchResponse(result)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
 func (a *app) handleRemoveTask(w http.ResponseWriter, r *http.Request) {
 	taskUUID := chi.URLParam(r, "uuid")
 
@@ -125,6 +290,391 @@ func (a *app) handleRemoveTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.resources.TaskResource.RemoveTaskByID(taskId)
+	if err = a.taskResource(r).RemoveTaskByID(taskId); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, crontab.ErrCrontabEntryNotFound) {
+			status = http.StatusNotFound
+		}
+
+		res := NewResponse(WithError(err, ""))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
 	a.writeJSON(w, http.StatusNoContent, "", nil)
 }
+
+const defaultLogTail = 100
+
+// Returns the end of the task's log, or streams it as plain text with
+// follow=true until the client goes away
+func (a *app) handleGetTaskLogs(w http.ResponseWriter, r *http.Request) {
+	taskId, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		res := NewResponse(WithError(err, TaskLogResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	query := r.URL.Query()
+
+	tail := defaultLogTail
+	if raw := query.Get("tail"); raw != "" {
+		tail, err = strconv.Atoi(raw)
+		if err != nil || tail < 0 {
+			res := NewResponse(WithError(errInvalidTail, TaskLogResponse{}))
+			a.writeJSON(w, http.StatusBadRequest, res, nil)
+			return
+		}
+	}
+
+	follow := false
+	if raw := query.Get("follow"); raw != "" {
+		follow, err = strconv.ParseBool(raw)
+		if err != nil {
+			res := NewResponse(WithError(errInvalidFollow, TaskLogResponse{}))
+			a.writeJSON(w, http.StatusBadRequest, res, nil)
+			return
+		}
+	}
+
+	if a.resources.Logs == nil {
+		res := NewResponse(WithError(tasklog.ErrNoLogs, TaskLogResponse{}))
+		a.writeJSON(w, http.StatusNotFound, res, nil)
+		return
+	}
+
+	lines, err := a.resources.Logs.Tail(taskId, tail)
+	if err != nil && !(follow && errors.Is(err, tasklog.ErrNoLogs)) {
+		status := http.StatusInternalServerError
+		if errors.Is(err, tasklog.ErrNoLogs) {
+			status = http.StatusNotFound
+		}
+
+		res := NewResponse(WithError(err, TaskLogResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	if !follow {
+		if lines == nil {
+			lines = []string{}
+		}
+
+		res := NewResponse(WithData(TASK_LOG, TaskLogResponse{TaskID: taskId, Lines: lines}))
+		a.writeJSON(w, http.StatusOK, res, nil)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	writeLine := func(line string) error {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	for _, line := range lines {
+		if err = writeLine(line); err != nil {
+			return
+		}
+	}
+
+	if err = rc.Flush(); err != nil {
+		return
+	}
+
+	if err = a.resources.Logs.Follow(r.Context(), taskId, writeLine); err != nil {
+		a.logger.Error("stopped following task log",
+			slog.String("task_id", taskId.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Every recorded run of the task, newest first. Runs outlive the task, so an
+// unknown ID is an empty list rather than not found.
+func (a *app) handleGetTaskRuns(w http.ResponseWriter, r *http.Request) {
+	taskId, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		res := NewResponse(WithError(err, []TaskRunResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	runs, err := a.resources.TaskResource.GetTaskRuns(taskId)
+	if err != nil {
+		res := NewResponse(WithError(err, []TaskRunResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	out := []TaskRunResponse{}
+	for _, run := range runs {
+		out = append(out, NewTaskRunResponse(run))
+	}
+
+	res := NewResponse(WithData(TASK_RUN, out))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleGetArchivedTasks(w http.ResponseWriter, r *http.Request) {
+	archived, err := a.resources.TaskResource.GetArchivedTasks()
+	if err != nil {
+		res := NewResponse(WithError(err, []ArchivedTaskResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	out := []ArchivedTaskResponse{}
+	for _, task := range archived {
+		out = append(out, NewArchivedTaskResponse(task))
+	}
+
+	res := NewResponse(WithData(ARCHIVED_TASK, out))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleRestoreTask(w http.ResponseWriter, r *http.Request) {
+	taskId, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	ctbE, err := a.taskResource(r).RestoreTask(taskId)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		switch {
+		case errors.Is(err, resources.ErrArchivedTaskNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, resources.ErrTaskAlreadyScheduled):
+			status = http.StatusConflict
+		case errors.Is(err, resources.ErrMaintenanceActive):
+			status = http.StatusServiceUnavailable
+		}
+
+		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(SCHEDULED_TASK, NewScheduledTaskResponse(ctbE)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handlePauseTask(w http.ResponseWriter, r *http.Request) {
+	a.handleSetTaskPaused(w, r, a.taskResource(r).PauseTaskByID)
+}
+
+func (a *app) handleResumeTask(w http.ResponseWriter, r *http.Request) {
+	a.handleSetTaskPaused(w, r, a.taskResource(r).ResumeTaskByID)
+}
+
+func (a *app) handleSetTaskPaused(w http.ResponseWriter, r *http.Request, setFn func(uuid.UUID) error) {
+	taskUUID := chi.URLParam(r, "uuid")
+
+	taskId, err := uuid.Parse(taskUUID)
+	if err != nil {
+		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	if err = setFn(taskId); err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, crontab.ErrCrontabEntryNotFound) {
+			status = http.StatusNotFound
+		}
+
+		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	ctbE, err := a.resources.TaskResource.GetTaskByID(taskId)
+	if err != nil {
+		res := NewResponse(WithError(err, ScheduledTaskResponse{}))
+		a.writeJSON(w, http.StatusUnprocessableEntity, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(SCHEDULED_TASK, NewScheduledTaskResponse(ctbE)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleGetDrift(w http.ResponseWriter, r *http.Request) {
+	a.handleDrift(w, false)
+}
+
+func (a *app) handleRepairDrift(w http.ResponseWriter, r *http.Request) {
+	a.handleDrift(w, true)
+}
+
+func (a *app) handleDrift(w http.ResponseWriter, repair bool) {
+	report, err := a.resources.TaskResource.CheckDrift(repair)
+	if err != nil {
+		res := NewResponse(WithError(err, crontab.DriftReport{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(DRIFT_REPORT, report))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	state, err := a.resources.TaskResource.GetMaintenance()
+	if err != nil {
+		res := NewResponse(WithError(err, MaintenanceResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(MAINTENANCE, NewMaintenanceResponse(state)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleEnableMaintenance(w http.ResponseWriter, r *http.Request) {
+	var input MaintenanceRequest
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		res := NewResponse(WithError(err, MaintenanceResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	state, err := a.taskResource(r).EnableMaintenance(input.EnabledBy, input.ExpiresAt)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, resources.ErrMaintenanceAlreadyActive) {
+			status = http.StatusConflict
+		}
+
+		res := NewResponse(WithError(err, MaintenanceResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(MAINTENANCE, NewMaintenanceResponse(state)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleDisableMaintenance(w http.ResponseWriter, r *http.Request) {
+	state, err := a.taskResource(r).DisableMaintenance()
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, resources.ErrMaintenanceNotActive) {
+			status = http.StatusConflict
+		}
+
+		res := NewResponse(WithError(err, MaintenanceResponse{}))
+		a.writeJSON(w, status, res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(MAINTENANCE, NewMaintenanceResponse(state)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleGetRevisions(w http.ResponseWriter, r *http.Request) {
+	revs, err := a.resources.TaskResource.GetRevisions()
+	if err != nil {
+		res := NewResponse(WithError(err, []RevisionResponse{}))
+		a.writeJSON(w, http.StatusInternalServerError, res, nil)
+		return
+	}
+
+	out := []RevisionResponse{}
+	for _, rev := range revs {
+		out = append(out, NewRevisionResponse(rev))
+	}
+
+	res := NewResponse(WithData(REVISION, out))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleGetRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		res := NewResponse(WithError(errInvalidRevisionID, RevisionResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	rev, err := a.resources.TaskResource.GetRevision(id)
+	if err != nil {
+		res := NewResponse(WithError(err, RevisionResponse{}))
+		a.writeJSON(w, revisionErrorStatus(err), res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(REVISION, NewRevisionResponse(rev)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	from, fromErr := strconv.Atoi(r.URL.Query().Get("from"))
+	to, toErr := strconv.Atoi(r.URL.Query().Get("to"))
+	if fromErr != nil || toErr != nil {
+		res := NewResponse(WithError(errInvalidRevisionID, RevisionDiffResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	diff, err := a.resources.TaskResource.DiffRevisions(from, to)
+	if err != nil {
+		res := NewResponse(WithError(err, RevisionDiffResponse{}))
+		a.writeJSON(w, revisionErrorStatus(err), res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(REVISION_DIFF, NewRevisionDiffResponse(from, to, diff)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func (a *app) handleRollbackRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		res := NewResponse(WithError(errInvalidRevisionID, RevisionResponse{}))
+		a.writeJSON(w, http.StatusBadRequest, res, nil)
+		return
+	}
+
+	// The body is optional, a rollback without a reason gets a generated one
+	var input RollbackRequest
+	if r.ContentLength != 0 {
+		if err = a.readJSON(w, r, &input); err != nil {
+			res := NewResponse(WithError(err, RevisionResponse{}))
+			a.writeJSON(w, http.StatusBadRequest, res, nil)
+			return
+		}
+	}
+
+	rev, err := a.taskResource(r).RollbackToRevision(id, input.Reason)
+	if err != nil {
+		res := NewResponse(WithError(err, RevisionResponse{}))
+		a.writeJSON(w, revisionErrorStatus(err), res, nil)
+		return
+	}
+
+	res := NewResponse(WithData(REVISION, NewRevisionResponse(rev)))
+	a.writeJSON(w, http.StatusOK, res, nil)
+}
+
+func revisionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, crontab.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, resources.ErrMaintenanceActive):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusUnprocessableEntity
+}
@@ -9,6 +9,8 @@ import (
 	"maps"
 	"net/http"
 	"strings"
+
+	"github.com/captainmango/coco-cron-parser/internal/resources"
 )
 
 type responseWriter struct {
@@ -22,6 +24,12 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Lets http.ResponseController reach the underlying writer, e.g. to flush
+// streamed logs
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (a *app) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -95,6 +103,17 @@ func (a *app) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	return nil
 }
 
+// Changes made through the API are attributed to the X-Actor header when
+// the caller sets one
+func (a *app) taskResource(r *http.Request) resources.TaskResource {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		actor = "api"
+	}
+
+	return a.resources.TaskResource.As(actor)
+}
+
 type tMeta map[string]any
 type Response[T any] struct {
 	Type  string `json:"type"`
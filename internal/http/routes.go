@@ -8,11 +8,40 @@ func (a *app) apiV1Router(r *chi.Mux) *chi.Mux {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/livez", a.handleLivez)
 
+		r.Route("/maintenance", func(r chi.Router) {
+			r.Get("/", a.handleGetMaintenance)
+			r.Post("/", a.handleEnableMaintenance)
+			r.Delete("/", a.handleDisableMaintenance)
+		})
+
+		r.Route("/crontab/revisions", func(r chi.Router) {
+			r.Get("/", a.handleGetRevisions)
+			r.Get("/diff", a.handleDiffRevisions)
+			r.Get("/{id}", a.handleGetRevision)
+			r.Post("/{id}/rollback", a.handleRollbackRevision)
+		})
+
+		r.Post("/tasks:batch", a.handleBatchTasks)
+
+		r.Route("/rooms/{room_id}/tasks", func(r chi.Router) {
+			r.Get("/", a.handleGetRoomTasks)
+			r.Delete("/", a.handleCancelRoomTasks)
+		})
+
 		r.Route("/tasks", func(r chi.Router) {
 			r.Get("/", a.handleGetTasks)
 			r.Get("/scheduled", a.handleGetScheduledTasks)
+			r.Get("/drift", a.handleGetDrift)
+			r.Post("/drift/repair", a.handleRepairDrift)
 			r.Post("/", a.handleScheduleTask)
+			r.Post("/import", a.handleImportCrontab)
+			r.Get("/archive", a.handleGetArchivedTasks)
+			r.Post("/archive/{uuid}/restore", a.handleRestoreTask)
 			r.Delete("/{uuid}", a.handleRemoveTask)
+			r.Post("/{uuid}/pause", a.handlePauseTask)
+			r.Post("/{uuid}/resume", a.handleResumeTask)
+			r.Get("/{uuid}/logs", a.handleGetTaskLogs)
+			r.Get("/{uuid}/runs", a.handleGetTaskRuns)
 		})
 	})
 
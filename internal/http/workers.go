@@ -0,0 +1,181 @@
+package coco_http
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	coco_cli "github.com/captainmango/coco-cron-parser/internal/cli"
+	"github.com/captainmango/coco-cron-parser/internal/config"
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/events"
+	"github.com/captainmango/coco-cron-parser/internal/resources"
+	"github.com/captainmango/coco-cron-parser/internal/scheduler"
+)
+
+// Background jobs that keep running for as long as the server does
+func (a *app) startWorkers(ctx context.Context) {
+	go a.every(ctx, time.Minute, "expire maintenance", a.resources.TaskResource.ExpireMaintenance)
+	go a.every(ctx, time.Minute, "sweep expired tasks", a.resources.TaskResource.SweepExpiredTasks)
+	go a.every(ctx, time.Hour, "purge task archive", a.purgeArchive)
+
+	if a.resources.Logs != nil {
+		go a.every(ctx, time.Minute, "rotate task logs", a.resources.Logs.Rotate)
+	}
+
+	// Tasks scheduled before arguments were stored only have them in their
+	// command, so they can't be found by room until they are filled in
+	if registry, ok := a.commandsRegistry.(*coco_cli.RegistryContainer); ok {
+		a.backfillTaskArgs(registry)
+	}
+
+	// The crontab may have been reset while we were down, so check it straight away
+	if err := a.reconcileDrift(); err != nil {
+		a.logger.Error("unable to check crontab drift", slog.String("error", err.Error()))
+	}
+
+	if interval := config.Config.DriftCheckInterval; interval > 0 {
+		go a.every(ctx, interval, "reconcile drift", a.reconcileDrift)
+	}
+
+	if a.resources.Events != nil {
+		// Subscribed before anything below publishes
+		evts, unsubscribe := a.resources.Events.Subscribe()
+		go a.logEvents(ctx, evts, unsubscribe)
+	}
+
+	if a.resources.Watcher != nil && a.resources.Events != nil {
+		go a.resources.Watcher.Run(ctx)
+	}
+
+	inProcess := config.Config.SchedulerMode == scheduler.MODE_IN_PROCESS
+	sched := scheduler.NewScheduler(a.resources.TaskResource, a.commandsRegistry,
+		scheduler.WithRunTracker(a.resources.TaskResource),
+	)
+
+	// Only crond and the in-process scheduler record their fires here, the
+	// other backends run tasks elsewhere
+	if config.Config.MisfireLookback > 0 && (inProcess || config.Config.ScheduleBackend == resources.SCHEDULE_BACKEND_CRONTAB) {
+		a.catchUpMissedRuns(ctx, sched)
+	}
+
+	if inProcess {
+		if config.Config.ScheduleBackend != resources.SCHEDULE_BACKEND_CRONTAB {
+			a.logger.Warn("tasks are run in-process and by the schedule backend",
+				slog.String("backend", config.Config.ScheduleBackend),
+			)
+		}
+
+		go sched.Run(ctx)
+	}
+}
+
+func (a *app) backfillTaskArgs(registry *coco_cli.RegistryContainer) {
+	filled, err := a.resources.TaskResource.BackfillArgs(registry.ArgsFor)
+	if err != nil {
+		a.logger.Error("unable to backfill task arguments", slog.String("error", err.Error()))
+		return
+	}
+
+	if filled > 0 {
+		a.logger.Info("backfilled task arguments", slog.Int("tasks", filled))
+	}
+}
+
+// Reports the runs tasks missed while the service was down and catches up
+// the ones their misfire policy asks for
+func (a *app) catchUpMissedRuns(ctx context.Context, sched *scheduler.Scheduler) {
+	missed, err := a.resources.TaskResource.MissedRuns(time.Now(), config.Config.MisfireLookback)
+	if err != nil {
+		a.logger.Error("unable to check for missed runs", slog.String("error", err.Error()))
+		return
+	}
+
+	if a.resources.Events != nil {
+		for _, mr := range missed {
+			a.resources.Events.Publish(events.Event{
+				Type:   events.TASK_MISSED,
+				TaskID: mr.Task.ID,
+				At:     mr.ScheduledAt,
+				Source: "misfire check",
+				Entry:  mr.Task,
+			})
+		}
+	}
+
+	sched.CatchUp(ctx, missed)
+}
+
+func (a *app) logEvents(ctx context.Context, evts <-chan events.Event, unsubscribe func()) {
+	defer unsubscribe()
+
+	for {
+		select {
+		case e := <-evts:
+			switch e.Type {
+			case events.TASK_TAMPERED:
+				a.logger.Warn("crontab line failed its signature check and was quarantined",
+					slog.String("task_id", e.TaskID.String()),
+					slog.String("source", e.Source),
+				)
+				continue
+			case events.TASK_MISSED:
+				a.logger.Warn("task missed a run",
+					slog.String("task_id", e.TaskID.String()),
+					slog.Time("scheduled_at", e.At),
+					slog.String("misfire_policy", cmp.Or(e.Entry.MisfirePolicy, crontab.MISFIRE_SKIP)),
+				)
+				continue
+			}
+
+			a.logger.Info("crontab changed",
+				slog.String("event", string(e.Type)),
+				slog.String("task_id", e.TaskID.String()),
+				slog.String("source", e.Source),
+			)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Tampered lines are quarantined first, so they are not reported as drift.
+// Backends without drift detection are skipped.
+func (a *app) reconcileDrift() error {
+	if _, err := a.resources.TaskResource.CheckSignatures(); err != nil {
+		return err
+	}
+
+	_, err := a.resources.TaskResource.CheckDrift(config.Config.DriftRepair)
+	if errors.Is(err, resources.ErrDriftUnsupported) {
+		return nil
+	}
+
+	return err
+}
+
+func (a *app) purgeArchive() error {
+	_, err := a.resources.TaskResource.PurgeArchive()
+	return err
+}
+
+func (a *app) every(ctx context.Context, interval time.Duration, name string, fn func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := fn(); err != nil {
+				a.logger.Error("background job failed",
+					slog.String("job", name),
+					slog.String("error", err.Error()),
+				)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"slices"
 	"strings"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -86,6 +87,48 @@ func (c Cron) Eq(other Cron) bool {
 	return true
 }
 
+// Compares what the expressions match rather than how they are written, so
+// "*/15" and "0,15,30,45" are the same minute
+func (c Cron) Equivalent(other Cron) bool {
+	if len(c.Data) != len(other.Data) {
+		return false
+	}
+
+	for idx, cf := range c.Data {
+		if cf.FragmentType != other.Data[idx].FragmentType {
+			return false
+		}
+
+		ours, err := cf.GetPossibleValues()
+		if err != nil {
+			return false
+		}
+
+		theirs, err := other.Data[idx].GetPossibleValues()
+		if err != nil {
+			return false
+		}
+
+		if !slices.Equal(ours, theirs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks every fragment is within its bounds. Parsing alone only checks the
+// syntax, so 61 is a perfectly good minute until its values are expanded.
+func (c Cron) Validate() error {
+	for _, fragment := range c.Data {
+		if _, err := fragment.GetPossibleValues(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c Cron) MarshalText() ([]byte, error) {
 	var builder strings.Builder
 	caser := cases.Title(language.English)
@@ -120,6 +163,24 @@ func (c Cron) MarshalText() ([]byte, error) {
 	return []byte(builder.String()), nil
 }
 
+// Parses a raw five field expression, so a Cron can be read back from
+// anything MarshalText wrote in RAW_EXPRESSION mode
+func (c *Cron) UnmarshalText(text []byte) error {
+	p, err := NewParser(WithInput(string(text), true))
+	if err != nil {
+		return err
+	}
+
+	parsed, err := p.Parse()
+	if err != nil {
+		return err
+	}
+
+	*c = parsed
+
+	return nil
+}
+
 func (c Cron) String() string {
 	out, err := c.MarshalText()
 
@@ -186,3 +247,115 @@ func NewSingleFragment(expr string, factors []uint8) (CronFragment, error) {
 		Factors: factors,
 	}, nil
 }
+
+// How far ahead Next looks before giving up. Five years covers the 29th of
+// February however the leap years fall.
+const nextSearchYears = 5
+
+// Whether the expression fires in the minute t falls in. Like cron, when both
+// the day of month and the weekday are restricted either one matching is enough.
+func (c Cron) Matches(t time.Time) bool {
+	m, err := c.matcher()
+	if err != nil {
+		return false
+	}
+
+	return m.month[t.Month()] && m.matchesDay(t) && m.hour[t.Hour()] && m.minute[t.Minute()]
+}
+
+// The first minute after t the expression fires in, in t's location. ok is
+// false when it never fires, e.g. "0 0 31 2 *".
+func (c Cron) Next(after time.Time) (next time.Time, ok bool) {
+	m, err := c.matcher()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(nextSearchYears, 0, 0)
+	loc := t.Location()
+
+	// Skips a whole month, day or hour at a time when that field does not match
+	for t.Before(limit) {
+		switch {
+		case !m.month[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !m.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !m.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !m.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+type cronMatcher struct {
+	minute  [60]bool
+	hour    [24]bool
+	day     [32]bool
+	month   [13]bool
+	weekday [8]bool
+	// Whether the day of month and weekday were restricted rather than "*"
+	dayRestricted     bool
+	weekdayRestricted bool
+}
+
+func (c Cron) matcher() (cronMatcher, error) {
+	var m cronMatcher
+	if len(c.Data) != len(cronOutputOrder) {
+		return m, ErrUnableToPullNextFragment()
+	}
+
+	for _, cf := range c.Data {
+		values, err := cf.GetPossibleValues()
+		if err != nil {
+			return m, err
+		}
+
+		var set []bool
+		switch cf.FragmentType {
+		case MINUTE:
+			set = m.minute[:]
+		case HOUR:
+			set = m.hour[:]
+		case DAY:
+			set = m.day[:]
+			m.dayRestricted = cf.Kind != WILDCARD
+		case MONTH:
+			set = m.month[:]
+		case WEEKDAY:
+			set = m.weekday[:]
+			m.weekdayRestricted = cf.Kind != WILDCARD
+		default:
+			return m, ErrUnknownFragmentType(cf.FragmentType)
+		}
+
+		for _, v := range values {
+			if int(v) < len(set) {
+				set[v] = true
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m cronMatcher) matchesDay(t time.Time) bool {
+	// Weekdays run from 1 for Monday to 7 for Sunday
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	day, wday := m.day[t.Day()], m.weekday[weekday]
+	if m.dayRestricted && m.weekdayRestricted {
+		return day || wday
+	}
+
+	return day && wday
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron_TextRoundTrip(t *testing.T) {
+	tests := []string{
+		"* * * * *",
+		"*/15 0 1,15 * 1-5",
+		"30 19 2 11 *",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			t.Parallel()
+
+			var c Cron
+			err := c.UnmarshalText([]byte(expr))
+			require.NoError(t, err)
+
+			out, err := c.MarshalText()
+			require.NoError(t, err)
+			assert.Equal(t, expr, string(out))
+		})
+	}
+}
+
+func TestCron_UnmarshalTextErrors(t *testing.T) {
+	var c Cron
+
+	assert.Error(t, c.UnmarshalText([]byte("wrong-cron")))
+	assert.Error(t, c.UnmarshalText([]byte("* * * *")))
+}
+
+func TestCron_JSON(t *testing.T) {
+	var in struct {
+		Cron Cron `json:"cron"`
+	}
+
+	err := json.Unmarshal([]byte(`{"cron": "5 4 * * 7"}`), &in)
+	require.NoError(t, err)
+
+	p, _ := NewParser(WithInput("5 4 * * 7", true))
+	expected, _ := p.Parse()
+	assert.True(t, expected.Eq(in.Cron))
+
+	out, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cron": "5 4 * * 7"}`, string(out))
+}
+
+func TestCron_Validate(t *testing.T) {
+	tests := map[string]bool{
+		"*/15 0 1,15 * 1-5": true,
+		"61 * * * *":        false,
+		"* 24 * * *":        false,
+		"* * 0 * *":         false,
+	}
+
+	for expr, valid := range tests {
+		t.Run(expr, func(t *testing.T) {
+			t.Parallel()
+
+			var c Cron
+			require.NoError(t, c.UnmarshalText([]byte(expr)))
+
+			if valid {
+				assert.NoError(t, c.Validate())
+			} else {
+				assert.Error(t, c.Validate())
+			}
+		})
+	}
+}
+
+func TestCron_Equivalent(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		equivalent bool
+	}{
+		{"*/15 * * * *", "0,15,30,45 * * * *", true},
+		{"0 9-11 * * *", "0 9,10,11 * * *", true},
+		{"* * * * 1-7", "* * * * *", true},
+		{"*/15 * * * *", "*/20 * * * *", false},
+		{"0 9 * * *", "0 9 1 * *", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			t.Parallel()
+
+			var a, b Cron
+			require.NoError(t, a.UnmarshalText([]byte(tt.a)))
+			require.NoError(t, b.UnmarshalText([]byte(tt.b)))
+
+			assert.Equal(t, tt.equivalent, a.Equivalent(b))
+		})
+	}
+}
+
+func TestCron_Matches(t *testing.T) {
+	// A Monday
+	at := time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := map[string]bool{
+		"* * * * *":       true,
+		"*/15 9-17 * * *": true,
+		"*/20 9-17 * * *": false,
+		"30 9 * * 1":      true,
+		"30 9 * * 7":      false,
+		"30 9 15 * 1":     true,
+		"30 9 15 * 2":     false,
+		"30 9 1 * 2":      true,
+		"30 9 1 7 *":      false,
+	}
+
+	for expr, matches := range tests {
+		t.Run(expr, func(t *testing.T) {
+			t.Parallel()
+
+			var c Cron
+			require.NoError(t, c.UnmarshalText([]byte(expr)))
+
+			assert.Equal(t, matches, c.Matches(at))
+		})
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	after := time.Date(2026, 6, 1, 9, 30, 15, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"* * * * *":    time.Date(2026, 6, 1, 9, 31, 0, 0, time.UTC),
+		"30 9 * * *":   time.Date(2026, 6, 2, 9, 30, 0, 0, time.UTC),
+		"0 8 * * 7":    time.Date(2026, 6, 7, 8, 0, 0, 0, time.UTC),
+		"0 0 1 1 *":    time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 29 2 *":  time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		"*/15 * * * *": time.Date(2026, 6, 1, 9, 45, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range tests {
+		t.Run(expr, func(t *testing.T) {
+			t.Parallel()
+
+			var c Cron
+			require.NoError(t, c.UnmarshalText([]byte(expr)))
+
+			next, ok := c.Next(after)
+			require.True(t, ok)
+			assert.Equal(t, expected, next)
+		})
+	}
+
+	var never Cron
+	require.NoError(t, never.UnmarshalText([]byte("0 0 31 2 *")))
+
+	_, ok := never.Next(after)
+	assert.False(t, ok)
+}
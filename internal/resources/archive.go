@@ -0,0 +1,273 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+var (
+	ErrArchivedTaskNotFound = errors.New("did not find archived task")
+	ErrTaskAlreadyScheduled = errors.New("a task with this ID is already scheduled")
+	ErrTaskExpired          = errors.New("task will never fire again")
+)
+
+// A removed task, kept so it can be restored with the same ID
+type ArchivedTask struct {
+	Entry     crontab.CrontabEntry `json:"entry"`
+	DeletedAt time.Time            `json:"deleted_at"`
+	DeletedBy string               `json:"deleted_by"`
+	// Why it was removed when it wasn't removed by hand, e.g. its last run failed
+	Reason string `json:"reason,omitempty"`
+}
+
+type taskArchive struct {
+	file      *store.JSONFile[map[uuid.UUID]ArchivedTask]
+	retention time.Duration
+}
+
+// Moves removed tasks into an archive at the given path instead of dropping
+// them. Archived tasks older than retention are purged, 0 keeps them forever.
+func WithArchive(path string, retention time.Duration) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.archive = &taskArchive{
+			file:      store.NewJSONFile[map[uuid.UUID]ArchivedTask](path),
+			retention: retention,
+		}
+	}
+}
+
+// Archives the entries and returns what the archive held before for each of
+// their IDs, so undo can put it back
+func (ta *taskArchive) add(entries []crontab.CrontabEntry, deletedBy, reason string) (map[uuid.UUID]ArchivedTask, error) {
+	var previous map[uuid.UUID]ArchivedTask
+
+	err := ta.file.Update(func(archived *map[uuid.UUID]ArchivedTask) error {
+		previous = putArchived(archived, entries, deletedBy, reason)
+
+		return nil
+	})
+
+	return previous, err
+}
+
+func putArchived(archived *map[uuid.UUID]ArchivedTask, entries []crontab.CrontabEntry, deletedBy, reason string) map[uuid.UUID]ArchivedTask {
+	if *archived == nil {
+		*archived = make(map[uuid.UUID]ArchivedTask)
+	}
+
+	now := time.Now().UTC()
+	previous := make(map[uuid.UUID]ArchivedTask)
+
+	for _, ctbE := range entries {
+		if prev, ok := (*archived)[ctbE.ID]; ok {
+			previous[ctbE.ID] = prev
+		}
+
+		(*archived)[ctbE.ID] = ArchivedTask{
+			Entry:     ctbE,
+			DeletedAt: now,
+			DeletedBy: deletedBy,
+			Reason:    reason,
+		}
+	}
+
+	return previous
+}
+
+// Takes the entries out of the archive again, putting back whatever was
+// archived under their IDs before add
+func (ta *taskArchive) undo(entries []crontab.CrontabEntry, previous map[uuid.UUID]ArchivedTask) error {
+	return ta.file.Update(func(archived *map[uuid.UUID]ArchivedTask) error {
+		for _, ctbE := range entries {
+			if prev, ok := previous[ctbE.ID]; ok {
+				(*archived)[ctbE.ID] = prev
+				continue
+			}
+
+			delete(*archived, ctbE.ID)
+		}
+
+		return nil
+	})
+}
+
+// Archives the entries before fn removes them. If fn fails the archive is put
+// back as it was, so a task is never in both places. reason may be empty when
+// the tasks are removed by hand.
+func (t TaskResource) archiving(entries []crontab.CrontabEntry, reason string, fn func() error) error {
+	if t.archive == nil || len(entries) == 0 {
+		return fn()
+	}
+
+	previous, err := t.archive.add(entries, t.author, reason)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err == nil {
+		return nil
+	}
+
+	if undoErr := t.archive.undo(entries, previous); undoErr != nil {
+		slog.Error("unable to undo archiving tasks",
+			slog.String("error", undoErr.Error()),
+		)
+	}
+
+	return err
+}
+
+// Like archiving, for when the entries being removed are only known once fn
+// holds the store's lock. fn hands them to archive and the archive stays
+// locked until fn returns, so they are only kept if fn succeeds.
+func (t TaskResource) archivingWithin(reason string, fn func(archive func([]crontab.CrontabEntry)) error) error {
+	if t.archive == nil {
+		return fn(func([]crontab.CrontabEntry) {})
+	}
+
+	return t.archive.file.Update(func(archived *map[uuid.UUID]ArchivedTask) error {
+		return fn(func(entries []crontab.CrontabEntry) {
+			if len(entries) > 0 {
+				putArchived(archived, entries, t.author, reason)
+			}
+		})
+	})
+}
+
+// Lists archived tasks, most recently removed first
+func (t TaskResource) GetArchivedTasks() ([]ArchivedTask, error) {
+	if t.archive == nil {
+		return []ArchivedTask{}, nil
+	}
+
+	archived, err := t.archive.file.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	out := slices.SortedFunc(maps.Values(archived), func(a, b ArchivedTask) int {
+		return b.DeletedAt.Compare(a.DeletedAt)
+	})
+
+	if out == nil {
+		out = []ArchivedTask{}
+	}
+
+	return out, nil
+}
+
+// The definition of every archived task by ID
+func (t TaskResource) archivedEntries() (map[uuid.UUID]crontab.CrontabEntry, error) {
+	if t.archive == nil {
+		return nil, nil
+	}
+
+	archived, err := t.archive.file.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[uuid.UUID]crontab.CrontabEntry, len(archived))
+	for id, at := range archived {
+		out[id] = at.Entry
+	}
+
+	return out, nil
+}
+
+// Schedules an archived task again with its original ID and definition. The
+// archive stays locked until the task is written and taken out of it, so a
+// task can only be restored once. Tasks that could never fire again are
+// refused, since the sweeper would only archive them again.
+func (t TaskResource) RestoreTask(id uuid.UUID) (crontab.CrontabEntry, error) {
+	if t.archive == nil {
+		return crontab.CrontabEntry{}, fmt.Errorf("%w with ID of %s", ErrArchivedTaskNotFound, id)
+	}
+
+	var ctbE crontab.CrontabEntry
+
+	err := t.underMaintenancePolicy(func(apply func(*crontab.CrontabEntry) error) error {
+		return t.archive.file.Update(func(archived *map[uuid.UUID]ArchivedTask) error {
+			task, ok := (*archived)[id]
+			if !ok {
+				return fmt.Errorf("%w with ID of %s", ErrArchivedTaskNotFound, id)
+			}
+
+			_, err := t.crontabManager.GetCrontabEntryByID(id)
+			if err == nil {
+				return fmt.Errorf("%w: %s", ErrTaskAlreadyScheduled, id)
+			}
+
+			if !errors.Is(err, crontab.ErrCrontabEntryNotFound) {
+				return err
+			}
+
+			if reason := expiredReason(task.Entry, time.Now()); reason != "" {
+				return fmt.Errorf("%w: %s", ErrTaskExpired, reason)
+			}
+
+			ctbE = task.Entry
+			ctbE.Meta.UpdatedAt = time.Now().UTC()
+
+			if err = apply(&ctbE); err != nil {
+				return err
+			}
+
+			if err = t.crontabManager.WriteCrontabEntries([]crontab.CrontabEntry{ctbE}); err != nil {
+				return err
+			}
+
+			delete(*archived, id)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return crontab.CrontabEntry{}, err
+	}
+
+	t.recordRevision(fmt.Sprintf("restore task %s", id))
+
+	return ctbE, nil
+}
+
+// Drops archived tasks that were removed longer ago than the retention period
+func (t TaskResource) PurgeArchive() (int, error) {
+	if t.archive == nil || t.archive.retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-t.archive.retention)
+	purged := 0
+
+	err := t.archive.file.Update(func(archived *map[uuid.UUID]ArchivedTask) error {
+		before := len(*archived)
+		maps.DeleteFunc(*archived, func(_ uuid.UUID, task ArchivedTask) bool {
+			return task.DeletedAt.Before(cutoff)
+		})
+		purged = before - len(*archived)
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		slog.Info("purged archived tasks", slog.Int("count", purged))
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,274 @@
+package resources
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func archivingTaskResource(t *testing.T, retention time.Duration) (TaskResource, *mocks.MockCrontabHandler) {
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithArchive(filepath.Join(t.TempDir(), "archive.json"), retention),
+	).As("alice")
+
+	return tR, mockCrontabHandler
+}
+
+// Entries round trip through JSON in the archive, so they need a real cron
+func archivableEntry(t *testing.T) crontab.CrontabEntry {
+	ctbE, err := newTaskEntry("*/5 * * * *", "cli start-game 123")
+	require.NoError(t, err)
+
+	return ctbE
+}
+
+func Test_ItArchivesRemovedTasks(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(nil)
+
+	require.NoError(t, tR.RemoveTaskByID(id))
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, id, archived[0].Entry.ID)
+	assert.Equal(t, ctbE.Cmd, archived[0].Entry.Cmd)
+	assert.Equal(t, ctbE.Cron.String(), archived[0].Entry.Cron.String())
+	assert.Equal(t, "alice", archived[0].DeletedBy)
+	assert.WithinDuration(t, time.Now(), archived[0].DeletedAt, time.Second)
+}
+
+func Test_ItDoesNotArchiveTasksThatFailedToBeRemoved(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(assert.AnError)
+
+	require.ErrorIs(t, tR.RemoveTaskByID(id), assert.AnError)
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	assert.Empty(t, archived)
+}
+
+func Test_ItKeepsEarlierArchivedCopiesWhenRemovalFails(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	_, err := tR.archive.add([]crontab.CrontabEntry{ctbE}, "bob", "completed")
+	require.NoError(t, err)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(assert.AnError)
+
+	require.ErrorIs(t, tR.RemoveTaskByID(id), assert.AnError)
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, "bob", archived[0].DeletedBy)
+	assert.Equal(t, "completed", archived[0].Reason)
+}
+
+func Test_ItArchivesTasksRemovedInABatch(t *testing.T) {
+	t.Parallel()
+
+	removed := archivableEntry(t)
+	kept := archivableEntry(t)
+	removedID := removed.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	mockCrontabHandler.On("UpdateCrontabEntries").
+		Return([]crontab.CrontabEntry{removed, kept}, nil)
+
+	_, err := tR.RemoveTasks([]uuid.UUID{removedID})
+	require.NoError(t, err)
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, removedID, archived[0].Entry.ID)
+}
+
+func Test_ItRestoresAnArchivedTaskWithTheSameID(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil).Once()
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(nil)
+	require.NoError(t, tR.RemoveTaskByID(id))
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).
+		Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound).Once()
+
+	var written []crontab.CrontabEntry
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			written = args.Get(0).([]crontab.CrontabEntry)
+		})
+
+	restored, err := tR.RestoreTask(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, id, restored.ID)
+	require.Len(t, written, 1)
+	assert.Equal(t, id, written[0].ID)
+	assert.Equal(t, "cli start-game 123", written[0].Cmd)
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	assert.Empty(t, archived)
+}
+
+func Test_ItRefusesToRestoreTasksThatCanNeverFireAgain(t *testing.T) {
+	t.Parallel()
+
+	missed := time.Now().Add(-time.Hour)
+
+	tests := map[string]func(ctbE *crontab.CrontabEntry){
+		"one-shot past its run time": func(ctbE *crontab.CrontabEntry) { ctbE.RunAt = &missed },
+		"past its end time":          func(ctbE *crontab.CrontabEntry) { ctbE.EndAt = &missed },
+		"out of runs":                func(ctbE *crontab.CrontabEntry) { ctbE.MaxRuns, ctbE.Runs = 2, 2 },
+	}
+
+	for name, expire := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctbE := archivableEntry(t)
+			expire(&ctbE)
+
+			tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+			_, err := tR.archive.add([]crontab.CrontabEntry{ctbE}, "alice", "completed")
+			require.NoError(t, err)
+
+			mockCrontabHandler.On("GetCrontabEntryByID", ctbE.ID).
+				Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound)
+
+			_, err = tR.RestoreTask(ctbE.ID)
+
+			assert.ErrorIs(t, err, ErrTaskExpired)
+			mockCrontabHandler.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+
+			archived, err := tR.GetArchivedTasks()
+			require.NoError(t, err)
+			assert.Len(t, archived, 1, "the task stays in the archive")
+		})
+	}
+}
+
+func Test_ItRefusesToRestoreOverAScheduledTask(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(nil)
+	require.NoError(t, tR.RemoveTaskByID(id))
+
+	_, err := tR.RestoreTask(id)
+	assert.ErrorIs(t, err, ErrTaskAlreadyScheduled)
+
+	_, err = tR.RestoreTask(uuid.New())
+	assert.ErrorIs(t, err, ErrArchivedTaskNotFound)
+
+	mockCrontabHandler.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+}
+
+func Test_ItRestoresATaskOnlyOnce(t *testing.T) {
+	t.Parallel()
+
+	ctbE := archivableEntry(t)
+	id := ctbE.ID
+
+	tR, mockCrontabHandler := archivingTaskResource(t, time.Hour)
+
+	_, err := tR.archive.add([]crontab.CrontabEntry{ctbE}, "bob", "")
+	require.NoError(t, err)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound)
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := tR.RestoreTask(id)
+			errs <- err
+		}()
+	}
+
+	var failed []error
+	for range 2 {
+		if err := <-errs; err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	require.Len(t, failed, 1)
+	assert.ErrorIs(t, failed[0], ErrArchivedTaskNotFound)
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+func Test_ItPurgesTasksPastTheRetentionPeriod(t *testing.T) {
+	t.Parallel()
+
+	old := archivableEntry(t)
+	recent := archivableEntry(t)
+	recentID := recent.ID
+
+	tR, _ := archivingTaskResource(t, time.Hour)
+
+	err := tR.archive.file.Save(map[uuid.UUID]ArchivedTask{
+		old.ID:   {Entry: old, DeletedAt: time.Now().Add(-2 * time.Hour)},
+		recentID: {Entry: recent, DeletedAt: time.Now()},
+	})
+	require.NoError(t, err)
+
+	purged, err := tR.PurgeArchive()
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, recentID, archived[0].Entry.ID)
+}
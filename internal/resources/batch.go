@@ -0,0 +1,307 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+const (
+	BATCH_SCHEDULE = "schedule"
+	BATCH_REMOVE   = "remove"
+)
+
+var (
+	ErrBatchRejected = errors.New("batch rejected, nothing was written")
+
+	errBatchItemAmbiguous = errors.New("set either a cron expression or run_at, not both")
+	// Returned inside a crontab update to skip writing entries that didn't change
+	errNothingRemoved = errors.New("no tasks to remove")
+)
+
+type BatchScheduleItem struct {
+	Cron        string   `json:"cron" yaml:"cron"`
+	Cmd         string   `json:"command" yaml:"command"`
+	Name        string   `json:"name,omitempty" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Owner       string   `json:"owner,omitempty" yaml:"owner"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags"`
+
+	// Schedules a one-shot task instead of Cron, an RFC 3339 time or "in 15m"
+	RunAt string `json:"run_at,omitempty" yaml:"run_at"`
+
+	StartAt *time.Time `json:"start_at,omitempty" yaml:"start_at"`
+	EndAt   *time.Time `json:"end_at,omitempty" yaml:"end_at"`
+	MaxRuns int        `json:"max_runs,omitempty" yaml:"max_runs"`
+
+	Args    map[string]string `json:"args,omitempty" yaml:"args"`
+	Env     []crontab.EnvVar  `json:"env,omitempty" yaml:"env"`
+	WorkDir string            `json:"work_dir,omitempty" yaml:"work_dir"`
+
+	Retry             *crontab.RetryPolicy `json:"retry,omitempty" yaml:"retry"`
+	ConcurrencyPolicy string               `json:"concurrency_policy,omitempty" yaml:"concurrency_policy"`
+	MisfirePolicy     string               `json:"misfire_policy,omitempty" yaml:"misfire_policy"`
+}
+
+func (bsi BatchScheduleItem) Metadata() crontab.Metadata {
+	return crontab.Metadata{
+		Name:        bsi.Name,
+		Description: bsi.Description,
+		Owner:       bsi.Owner,
+		Tags:        bsi.Tags,
+	}
+}
+
+// Builds the entry the item schedules, a one-shot task when it has a run time
+func (bsi BatchScheduleItem) entry() (crontab.CrontabEntry, error) {
+	cron := bsi.Cron
+	opts := []ScheduleOptFn{
+		WithMetadata(bsi.Metadata()),
+		WithValidity(bsi.StartAt, bsi.EndAt),
+		WithMaxRuns(bsi.MaxRuns),
+		WithArgs(bsi.Args),
+		WithEnv(bsi.Env),
+		WithWorkDir(bsi.WorkDir),
+		WithRetryPolicy(bsi.Retry),
+		WithConcurrencyPolicy(bsi.ConcurrencyPolicy),
+		WithMisfirePolicy(bsi.MisfirePolicy),
+	}
+
+	if bsi.RunAt != "" {
+		if bsi.Cron != "" {
+			return crontab.CrontabEntry{}, errBatchItemAmbiguous
+		}
+
+		runAt, err := crontab.ParseRunAt(bsi.RunAt, time.Now())
+		if err != nil {
+			return crontab.CrontabEntry{}, err
+		}
+
+		cron = crontab.OneShotCron(runAt)
+		opts = append(opts, WithRunAt(runAt))
+	}
+
+	return newTaskEntry(cron, bsi.Cmd, opts...)
+}
+
+type Batch struct {
+	Schedule []BatchScheduleItem `json:"schedule" yaml:"schedule"`
+	Remove   []uuid.UUID         `json:"remove" yaml:"remove"`
+}
+
+type BatchItemResult struct {
+	Op    string               `json:"op"`
+	Index int                  `json:"index"`
+	ID    uuid.UUID            `json:"id"`
+	Entry crontab.CrontabEntry `json:"entry,omitzero"`
+	Error string               `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Committed bool              `json:"committed"`
+	Items     []BatchItemResult `json:"items"`
+}
+
+func (br BatchResult) failed() bool {
+	return slices.ContainsFunc(br.Items, func(item BatchItemResult) bool {
+		return item.Error != ""
+	})
+}
+
+func (t TaskResource) ScheduleTasks(items []BatchScheduleItem) (BatchResult, error) {
+	return t.ApplyBatch(Batch{Schedule: items})
+}
+
+func (t TaskResource) RemoveTasks(ids []uuid.UUID) (BatchResult, error) {
+	return t.ApplyBatch(Batch{Remove: ids})
+}
+
+// Removes every task match picks in one store update and archives them. The
+// tasks are picked under the store's lock, so one removed meanwhile is just
+// not picked instead of failing the removal of the rest.
+func (t TaskResource) removeMatching(match func(crontab.CrontabEntry) bool) ([]crontab.CrontabEntry, error) {
+	var removed []crontab.CrontabEntry
+
+	err := t.archivingWithin("", func(archive func([]crontab.CrontabEntry)) error {
+		return t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+			*entries = slices.DeleteFunc(*entries, func(ctbE crontab.CrontabEntry) bool {
+				if !match(ctbE) {
+					return false
+				}
+
+				removed = append(removed, ctbE)
+
+				return true
+			})
+
+			if len(removed) == 0 {
+				return errNothingRemoved
+			}
+
+			archive(removed)
+
+			return nil
+		})
+	})
+
+	if errors.Is(err, errNothingRemoved) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// Compares each entry the batch creates with the entries that stay and with
+// the ones accepted before it. Under DUPLICATE_EXISTING a duplicate is not
+// created and its result is the task it duplicates. Under DUPLICATE_REJECT
+// its result carries the error, which rejects the batch. Returns the entries
+// still to be created and the indexes of their results.
+func (t TaskResource) applyDuplicatePolicy(
+	entries []crontab.CrontabEntry,
+	removing map[uuid.UUID]bool,
+	created []crontab.CrontabEntry,
+	createdItems []int,
+	results []BatchItemResult,
+) ([]crontab.CrontabEntry, []int) {
+	if t.duplicatePolicy == DUPLICATE_ALLOW {
+		return created, createdItems
+	}
+
+	staying := slices.DeleteFunc(slices.Clone(entries), func(ctbE crontab.CrontabEntry) bool {
+		return removing[ctbE.ID]
+	})
+
+	var accepted []crontab.CrontabEntry
+	var acceptedItems []int
+	for i, ctbE := range created {
+		res := &results[createdItems[i]]
+
+		existing, found := duplicateOf(ctbE, staying)
+		if !found {
+			existing, found = duplicateOf(ctbE, accepted)
+		}
+
+		switch {
+		case !found:
+			accepted = append(accepted, ctbE)
+			acceptedItems = append(acceptedItems, createdItems[i])
+		case t.duplicatePolicy == DUPLICATE_EXISTING:
+			res.ID = existing.ID
+			res.Entry = existing
+		default:
+			res.Error = fmt.Errorf("%w with ID of %s", ErrDuplicateTask, existing.ID).Error()
+		}
+	}
+
+	return accepted, acceptedItems
+}
+
+// Validates every item and writes all of them in a single rewrite under the
+// store's lock, so nothing written meanwhile is lost. If any item is invalid
+// nothing is written and the per item results say why. The duplicate policy
+// applies to each item as it would to a single schedule.
+func (t TaskResource) ApplyBatch(b Batch) (BatchResult, error) {
+	result := BatchResult{Items: []BatchItemResult{}}
+
+	if len(b.Schedule) == 0 && len(b.Remove) == 0 {
+		return result, nil
+	}
+
+	var created []crontab.CrontabEntry
+	var createdItems []int
+	for i, item := range b.Schedule {
+		res := BatchItemResult{Op: BATCH_SCHEDULE, Index: i}
+
+		ctbE, err := item.entry()
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.ID = ctbE.ID
+			res.Entry = ctbE
+			created = append(created, ctbE)
+			createdItems = append(createdItems, len(result.Items))
+		}
+
+		result.Items = append(result.Items, res)
+	}
+
+	removing := make(map[uuid.UUID]bool, len(b.Remove))
+
+	err := t.underMaintenancePolicy(func(apply func(*crontab.CrontabEntry) error) error {
+		return t.archivingWithin("", func(archive func([]crontab.CrontabEntry)) error {
+			return t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+				for i, id := range b.Remove {
+					res := BatchItemResult{Op: BATCH_REMOVE, Index: i, ID: id}
+
+					idx := slices.IndexFunc(*entries, func(ctbE crontab.CrontabEntry) bool {
+						return ctbE.ID == id
+					})
+
+					switch {
+					case idx == -1:
+						res.Error = fmt.Errorf("%w with ID of %s", crontab.ErrCrontabEntryNotFound, id).Error()
+					case removing[id]:
+						res.Error = "removed more than once in the same batch"
+					default:
+						res.Entry = (*entries)[idx]
+						removing[id] = true
+					}
+
+					result.Items = append(result.Items, res)
+				}
+
+				created, createdItems = t.applyDuplicatePolicy(*entries, removing, created, createdItems, result.Items)
+
+				if result.failed() {
+					return ErrBatchRejected
+				}
+
+				for i := range created {
+					if err := apply(&created[i]); err != nil {
+						return err
+					}
+
+					result.Items[createdItems[i]].Entry = created[i]
+				}
+
+				var removed []crontab.CrontabEntry
+				*entries = slices.DeleteFunc(*entries, func(ctbE crontab.CrontabEntry) bool {
+					if removing[ctbE.ID] {
+						removed = append(removed, ctbE)
+					}
+
+					return removing[ctbE.ID]
+				})
+				*entries = append(*entries, created...)
+
+				archive(removed)
+
+				return nil
+			})
+		})
+	})
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Committed = true
+	t.recordRevision(fmt.Sprintf("batch: schedule %d, remove %d", len(created), len(removing)))
+
+	slog.Info("applied task batch",
+		slog.Int("scheduled", len(created)),
+		slog.Int("removed", len(removing)),
+	)
+
+	return result, nil
+}
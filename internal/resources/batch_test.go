@@ -0,0 +1,235 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItAppliesABatchInOneWrite(t *testing.T) {
+	t.Parallel()
+
+	keptID, _ := uuid.NewV7()
+	removedID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{
+		{ID: keptID, Cron: exampleTestCron(), Cmd: "test-command"},
+		{ID: removedID, Cron: exampleTestCron(), Cmd: "test-command"},
+	}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{
+			{Cron: "0 19 * * *", Cmd: "cli start-game 1", Name: "Game 1"},
+			{Cron: "30 19 * * *", Cmd: "cli start-game 2"},
+		},
+		Remove: []uuid.UUID{removedID},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	require.Len(t, result.Items, 3)
+	assert.Equal(t, "Game 1", result.Items[0].Entry.Meta.Name)
+
+	require.Len(t, written, 3)
+	assert.Equal(t, keptID, written[0].ID)
+	assert.Equal(t, result.Items[0].ID, written[1].ID)
+	assert.Equal(t, result.Items[1].ID, written[2].ID)
+	mockCrontabHandler.AssertNumberOfCalls(t, "UpdateCrontabEntries", 1)
+}
+
+func Test_ItRejectsTheWholeBatchWhenAnItemIsInvalid(t *testing.T) {
+	t.Parallel()
+
+	missingID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{
+			{Cron: "0 19 * * *", Cmd: "cli start-game 1"},
+			{Cron: "61 19 * * *", Cmd: "cli start-game 2"},
+		},
+		Remove: []uuid.UUID{missingID},
+	})
+
+	assert.ErrorIs(t, err, ErrBatchRejected)
+	assert.False(t, result.Committed)
+	require.Len(t, result.Items, 3)
+	assert.Empty(t, result.Items[0].Error)
+	assert.NotEmpty(t, result.Items[1].Error)
+	assert.Contains(t, result.Items[2].Error, missingID.String())
+	assert.Empty(t, written)
+}
+
+func Test_ItSchedulesOneShotTasksInABatch(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{
+			{RunAt: "in 15m", Cmd: "cli start-game 1"},
+		},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	require.Len(t, written, 1)
+	require.NotNil(t, written[0].RunAt)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *written[0].RunAt, time.Minute)
+}
+
+func Test_ItRejectsABatchItemWithACronAndARunTime(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{
+			{Cron: "0 19 * * *", RunAt: "in 15m", Cmd: "cli start-game 1"},
+		},
+	})
+
+	assert.ErrorIs(t, err, ErrBatchRejected)
+	require.Len(t, result.Items, 1)
+	assert.NotEmpty(t, result.Items[0].Error)
+	assert.Empty(t, written)
+}
+
+func Test_ItRejectsABatchThatDuplicatesTasks(t *testing.T) {
+	t.Parallel()
+
+	scheduled, err := newTaskEntry("0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+
+	for name, items := range map[string][]BatchScheduleItem{
+		"a scheduled task": {
+			{Cron: "0 19 * * *", Cmd: "cli start-game 1"},
+		},
+		"another item": {
+			{Cron: "30 19 * * *", Cmd: "cli start-game 2"},
+			{Cron: "30 19 * * *", Cmd: "cli start-game 2"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCrontabHandler := new(mocks.MockCrontabHandler)
+			mockQueueHandler := new(mocks.MockQueueHandler)
+
+			written := []crontab.CrontabEntry{scheduled}
+			mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+			tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithDuplicatePolicy(DUPLICATE_REJECT))
+
+			result, err := tR.ApplyBatch(Batch{Schedule: items})
+
+			assert.ErrorIs(t, err, ErrBatchRejected)
+			assert.False(t, result.Committed)
+			assert.Contains(t, result.Items[len(items)-1].Error, ErrDuplicateTask.Error())
+			assert.Len(t, written, 1, "nothing is written")
+		})
+	}
+}
+
+func Test_ItHandsBackExistingTasksForBatchDuplicates(t *testing.T) {
+	t.Parallel()
+
+	scheduled, err := newTaskEntry("0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{scheduled}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithDuplicatePolicy(DUPLICATE_EXISTING))
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{
+			{Cron: "0 19 * * *", Cmd: "cli start-game 1"},
+			{Cron: "30 19 * * *", Cmd: "cli start-game 2"},
+			{Cron: "30 19 * * *", Cmd: "cli start-game 2"},
+		},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	require.Len(t, result.Items, 3)
+	assert.Equal(t, scheduled.ID, result.Items[0].ID, "a scheduled task is handed back")
+	assert.Equal(t, result.Items[1].ID, result.Items[2].ID, "a later item gets the earlier one")
+
+	require.Len(t, written, 2)
+	assert.Equal(t, result.Items[1].ID, written[1].ID)
+}
+
+func Test_ItAllowsABatchToReplaceATaskItRemoves(t *testing.T) {
+	t.Parallel()
+
+	scheduled, err := newTaskEntry("0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{scheduled}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithDuplicatePolicy(DUPLICATE_REJECT))
+
+	result, err := tR.ApplyBatch(Batch{
+		Schedule: []BatchScheduleItem{{Cron: "0 19 * * *", Cmd: "cli start-game 1"}},
+		Remove:   []uuid.UUID{scheduled.ID},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	assert.Equal(t, result.Items[0].ID, written[0].ID)
+}
+
+func Test_ItReadsABatchFromYAML(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	raw := "schedule:\n  - cron: \"0 19 * * *\"\n    command: cli start-game 1\n    tags: [finals]\nremove:\n  - " + id.String() + "\n"
+
+	var batch Batch
+	require.NoError(t, yaml.Unmarshal([]byte(raw), &batch))
+
+	require.Len(t, batch.Schedule, 1)
+	assert.Equal(t, "cli start-game 1", batch.Schedule[0].Cmd)
+	assert.Equal(t, []string{"finals"}, batch.Schedule[0].Tags)
+	assert.Equal(t, []uuid.UUID{id}, batch.Remove)
+}
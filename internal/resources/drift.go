@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+// The crontab handler has nothing on disk to compare against the task store
+var ErrDriftUnsupported = errors.New("crontab handler does not support drift detection")
+
+// Compares the tasks we expect to be scheduled with the crontab on disk and,
+// when repair is set, rewrites the crontab to match
+func (t TaskResource) CheckDrift(repair bool) (crontab.DriftReport, error) {
+	reconciler, ok := t.crontabManager.(crontab.DriftReconciler)
+	if !ok {
+		return crontab.DriftReport{}, ErrDriftUnsupported
+	}
+
+	check := reconciler.DetectDrift
+	if repair {
+		check = reconciler.RepairDrift
+	}
+
+	report, err := check()
+	if err != nil {
+		return report, err
+	}
+
+	for _, item := range report.Items {
+		slog.Warn("crontab drift detected",
+			slog.String("kind", string(item.Kind)),
+			slog.String("id", item.ID.String()),
+			slog.String("expected", item.Expected),
+			slog.String("actual", item.Actual),
+		)
+	}
+
+	if report.Repaired {
+		slog.Info("repaired crontab drift", slog.Int("items", len(report.Items)))
+	}
+
+	return report, nil
+}
+
+// Quarantines crontab lines that fail their signature check. Does nothing
+// when the handler does not sign what it writes.
+func (t TaskResource) CheckSignatures() ([]crontab.TamperedLine, error) {
+	checker, ok := t.crontabManager.(crontab.SignatureChecker)
+	if !ok {
+		return nil, nil
+	}
+
+	return checker.CheckSignatures()
+}
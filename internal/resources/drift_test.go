@@ -0,0 +1,18 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItSaysWhenTheHandlerCannotDetectDrift(t *testing.T) {
+	t.Parallel()
+
+	tR := CreateTaskResource(new(mocks.MockCrontabHandler), new(mocks.MockQueueHandler))
+
+	_, err := tR.CheckDrift(false)
+	assert.ErrorIs(t, err, ErrDriftUnsupported)
+}
@@ -0,0 +1,125 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+)
+
+const (
+	DUPLICATE_ALLOW    = "allow"    // schedule it anyway
+	DUPLICATE_REJECT   = "reject"   // fail with ErrDuplicateTask
+	DUPLICATE_EXISTING = "existing" // hand back the task that is already scheduled
+)
+
+var (
+	ErrDuplicateTask          = errors.New("an identical task is already scheduled")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key was already used for a different task")
+)
+
+type idempotencyRecord struct {
+	Cron string `json:"cron,omitempty"`
+	// The run_at a one-shot task was asked for, as given. A relative time
+	// resolves to a different cron on every retry, so the input is compared.
+	RunAt     string               `json:"run_at,omitempty"`
+	Cmd       string               `json:"cmd"`
+	Entry     crontab.CrontabEntry `json:"entry"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// Remembers which task each idempotency key produced, for as long as the
+// window lasts
+type idempotencyStore struct {
+	mu     sync.Mutex
+	file   *store.JSONFile[map[string]idempotencyRecord]
+	window time.Duration
+}
+
+func WithIdempotency(path string, window time.Duration) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.idempotency = &idempotencyStore{
+			file:   store.NewJSONFile[map[string]idempotencyRecord](path),
+			window: window,
+		}
+	}
+}
+
+func WithDuplicatePolicy(policy string) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		if policy != "" {
+			t.duplicatePolicy = policy
+		}
+	}
+}
+
+// Returns the task created for the key, if the key is still remembered.
+// request holds the schedule and command the key is being used for now.
+func (is *idempotencyStore) lookup(key string, request idempotencyRecord) (crontab.CrontabEntry, bool, error) {
+	records, err := is.file.Load()
+	if err != nil {
+		return crontab.CrontabEntry{}, false, err
+	}
+
+	record, ok := records[key]
+	if !ok || time.Since(record.CreatedAt) > is.window {
+		return crontab.CrontabEntry{}, false, nil
+	}
+
+	if record.Cron != request.Cron || record.RunAt != request.RunAt || record.Cmd != request.Cmd {
+		return crontab.CrontabEntry{}, false, fmt.Errorf("%w: %s", ErrIdempotencyKeyMismatch, key)
+	}
+
+	return record.Entry, true, nil
+}
+
+// Stores the key and drops any that have fallen out of the window
+func (is *idempotencyStore) remember(key string, request idempotencyRecord, ctbE crontab.CrontabEntry) error {
+	return is.file.Update(func(records *map[string]idempotencyRecord) error {
+		if *records == nil {
+			*records = make(map[string]idempotencyRecord)
+		}
+
+		maps.DeleteFunc(*records, func(_ string, record idempotencyRecord) bool {
+			return time.Since(record.CreatedAt) > is.window
+		})
+
+		// A replay reads the task as it is now, the copy is only a fallback
+		request.Entry = ctbE.Redacted()
+		request.CreatedAt = time.Now().UTC()
+		(*records)[key] = request
+
+		return nil
+	})
+}
+
+// Looks for a scheduled task running the same command on an equivalent
+// schedule
+func (t TaskResource) findDuplicate(ctbE crontab.CrontabEntry) (crontab.CrontabEntry, bool, error) {
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err != nil {
+		return crontab.CrontabEntry{}, false, err
+	}
+
+	existing, found := duplicateOf(ctbE, entries)
+
+	return existing, found, nil
+}
+
+func duplicateOf(ctbE crontab.CrontabEntry, entries []crontab.CrontabEntry) (crontab.CrontabEntry, bool) {
+	for _, existing := range entries {
+		if existing.IsOneShot() != ctbE.IsOneShot() {
+			continue
+		}
+
+		if existing.Cmd == ctbE.Cmd && existing.Cron.Equivalent(ctbE.Cron) {
+			return existing, true
+		}
+	}
+
+	return crontab.CrontabEntry{}, false
+}
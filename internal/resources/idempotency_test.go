@@ -0,0 +1,201 @@
+package resources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItReplaysAnIdempotentSchedule(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithIdempotency(filepath.Join(t.TempDir(), "idempotency.json"), time.Hour),
+	)
+
+	first, created, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// Paused since it was scheduled, the replay shows it as it is now
+	edited := first
+	edited.Paused = true
+	mockCrontabHandler.On("GetCrontabEntryByID", first.ID).Return(edited, nil)
+
+	second, created, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, second.ID)
+	assert.True(t, second.Paused)
+
+	_, _, err = tR.ScheduleTaskOnce("retry-1", "0 20 * * *", "cli start-game 1")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+func Test_ItReplaysARelativeOneShotAcrossAMinuteBoundary(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithIdempotency(filepath.Join(t.TempDir(), "idempotency.json"), time.Hour),
+	)
+
+	now := time.Date(2026, 6, 1, 19, 59, 50, 0, time.Local)
+	tR.now = func() time.Time { return now }
+
+	first, created, err := tR.ScheduleOneShotTaskOnce("retry-1", "in 15m", "cli start-game 1")
+	require.NoError(t, err)
+	require.True(t, created)
+	mockCrontabHandler.On("GetCrontabEntryByID", first.ID).Return(first, nil)
+
+	// The retry resolves "in 15m" to the next minute
+	now = now.Add(20 * time.Second)
+
+	second, created, err := tR.ScheduleOneShotTaskOnce("retry-1", "in 15m", "cli start-game 1")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, second.ID)
+
+	_, _, err = tR.ScheduleOneShotTaskOnce("retry-1", "in 30m", "cli start-game 1")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+func Test_ItKeepsSecretValuesOutOfIdempotencyRecords(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithIdempotency(path, time.Hour))
+
+	_, _, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1",
+		WithEnv([]crontab.EnvVar{{Name: "API_TOKEN", Value: "s3cret", Secret: true}}),
+	)
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "s3cret")
+}
+
+func Test_ItReplaysTheOriginalTaskOnceRemoved(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+	mockCrontabHandler.On("GetCrontabEntryByID", mock.Anything).Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithIdempotency(filepath.Join(t.TempDir(), "idempotency.json"), time.Hour),
+	)
+
+	first, _, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+
+	second, created, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.Cmd, second.Cmd)
+
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+func Test_ItForgetsIdempotencyKeysAfterTheWindow(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithIdempotency(filepath.Join(t.TempDir(), "idempotency.json"), time.Nanosecond),
+	)
+
+	first, _, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	second, created, err := tR.ScheduleTaskOnce("retry-1", "0 19 * * *", "cli start-game 1")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func Test_ItAppliesTheDuplicatePolicy(t *testing.T) {
+	t.Parallel()
+
+	p, _ := parser.NewParser(parser.WithInput("0,15,30,45 19 * * *", true))
+	existingCron, _ := p.Parse()
+	existing := crontab.CrontabEntry{Cron: existingCron, Cmd: "cli start-game 1"}
+
+	tests := map[string]func(t *testing.T, ctbE crontab.CrontabEntry, created bool, err error){
+		DUPLICATE_REJECT: func(t *testing.T, ctbE crontab.CrontabEntry, created bool, err error) {
+			assert.ErrorIs(t, err, ErrDuplicateTask)
+		},
+		DUPLICATE_EXISTING: func(t *testing.T, ctbE crontab.CrontabEntry, created bool, err error) {
+			require.NoError(t, err)
+			assert.False(t, created)
+			assert.Equal(t, existing.Cmd, ctbE.Cmd)
+		},
+		DUPLICATE_ALLOW: func(t *testing.T, ctbE crontab.CrontabEntry, created bool, err error) {
+			require.NoError(t, err)
+			assert.True(t, created)
+		},
+	}
+
+	for policy, check := range tests {
+		t.Run(policy, func(t *testing.T) {
+			t.Parallel()
+
+			mockCrontabHandler := new(mocks.MockCrontabHandler)
+			mockQueueHandler := new(mocks.MockQueueHandler)
+
+			mockCrontabHandler.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{existing}, nil)
+			mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+			tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithDuplicatePolicy(policy))
+
+			// Written differently, but fires at the same times
+			ctbE, created, err := tR.ScheduleTaskOnce("", "*/15 19 * * *", "cli start-game 1")
+			check(t, ctbE, created, err)
+		})
+	}
+}
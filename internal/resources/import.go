@@ -0,0 +1,60 @@
+package resources
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+// Converts a crontab written outside the service into managed entries. With
+// dryRun set the plan is returned without writing anything.
+func (t TaskResource) ImportCrontab(r io.Reader, format string, dryRun bool) (crontab.ImportPlan, error) {
+	plan, err := crontab.ParseForeignCrontab(r, format)
+	if err != nil {
+		return crontab.ImportPlan{}, err
+	}
+
+	if dryRun || len(plan.Accepted) == 0 {
+		return t.withCrontabLines(plan), nil
+	}
+
+	err = t.underMaintenancePolicy(func(apply func(*crontab.CrontabEntry) error) error {
+		for i := range plan.Accepted {
+			if err := apply(&plan.Accepted[i].Entry); err != nil {
+				return err
+			}
+		}
+
+		return t.crontabManager.WriteCrontabEntries(plan.Entries())
+	})
+
+	if err != nil {
+		return crontab.ImportPlan{}, err
+	}
+
+	t.recordRevision("import crontab")
+
+	slog.Info("imported crontab",
+		slog.Int("accepted", len(plan.Accepted)),
+		slog.Int("rejected", len(plan.Rejected)),
+	)
+
+	return t.withCrontabLines(plan), nil
+}
+
+// Fills in the line each accepted entry is written as. It depends on how the
+// handler is set up, e.g. where task logs go.
+func (t TaskResource) withCrontabLines(plan crontab.ImportPlan) crontab.ImportPlan {
+	render := crontab.CrontabEntry.String
+	if renderer, ok := t.crontabManager.(crontab.LineRenderer); ok {
+		render = renderer.RenderLine
+	}
+
+	for i := range plan.Accepted {
+		plan.Accepted[i].Line = strings.TrimSuffix(render(plan.Accepted[i].Entry), "\n")
+	}
+
+	return plan
+}
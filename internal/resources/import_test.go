@@ -0,0 +1,78 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+const legacyCrontab = "*/5 * * * * /usr/bin/poll\n@hourly /usr/bin/tick\n"
+
+func Test_ItDoesNotWriteOnADryRunImport(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	plan, err := tR.ImportCrontab(strings.NewReader(legacyCrontab), crontab.IMPORT_FORMAT_USER, true)
+
+	require.NoError(t, err)
+	assert.Len(t, plan.Accepted, 1)
+	assert.Len(t, plan.Rejected, 1)
+	mockCrontabHandler.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+}
+
+func Test_ItWritesImportedEntriesInOneGo(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	var written []crontab.CrontabEntry
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			written = args.Get(0).([]crontab.CrontabEntry)
+		})
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	plan, err := tR.ImportCrontab(strings.NewReader(legacyCrontab), crontab.IMPORT_FORMAT_USER, false)
+
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	assert.Equal(t, plan.Accepted[0].Entry.ID, written[0].ID)
+	assert.Equal(t, "/usr/bin/poll", written[0].Cmd)
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 1)
+}
+
+type mockLineRenderer struct {
+	*mocks.MockCrontabHandler
+}
+
+func (m mockLineRenderer) RenderLine(ctbE crontab.CrontabEntry) string {
+	return "rendered " + ctbE.Cmd + "\n"
+}
+
+func Test_ItShowsImportedLinesAsTheHandlerWritesThem(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(mockLineRenderer{mockCrontabHandler}, mockQueueHandler)
+
+	plan, err := tR.ImportCrontab(strings.NewReader(legacyCrontab), crontab.IMPORT_FORMAT_USER, true)
+
+	require.NoError(t, err)
+	require.Len(t, plan.Accepted, 1)
+	assert.Equal(t, "rendered /usr/bin/poll", plan.Accepted[0].Line)
+}
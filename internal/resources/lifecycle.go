@@ -0,0 +1,201 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+// How long after its run time a one-shot task is assumed to have been missed
+// and is swept away
+const oneShotGrace = 2 * time.Minute
+
+var (
+	ErrInvalidValidity = errors.New("end_at must be after start_at and in the future")
+	ErrInvalidMaxRuns  = errors.New("max_runs must not be negative")
+
+	// Leaves the entry unchanged when a run has nothing to count
+	errRunNotCounted = errors.New("run not counted")
+)
+
+// Limits the task to firing between start and end. Either may be nil to
+// leave that side open.
+func WithValidity(start, end *time.Time) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if end != nil && (!end.After(time.Now()) || (start != nil && !end.After(*start))) {
+			return ErrInvalidValidity
+		}
+
+		if start != nil {
+			startAt := start.UTC()
+			ctbE.StartAt = &startAt
+		}
+
+		if end != nil {
+			endAt := end.UTC()
+			ctbE.EndAt = &endAt
+		}
+
+		return nil
+	}
+}
+
+// Removes the task after it has fired n times. Zero means no limit.
+func WithMaxRuns(n int) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if n < 0 {
+			return ErrInvalidMaxRuns
+		}
+
+		ctbE.MaxRuns = n
+
+		return nil
+	}
+}
+
+// Called by the CLI before it runs a command cron started for a tracked task.
+// Reports false when the task is outside its window or out of runs, in which
+// case the command must not run. Otherwise the run is counted. The check and
+// the count happen in one store update, so two runs can't both take the last
+// one. Fails with ErrCrontabEntryNotFound once the task is gone.
+func (t TaskResource) BeginTaskRun(id uuid.UUID) (bool, error) {
+	run := false
+
+	err := t.crontabManager.UpdateCrontabEntryByID(id, func(ctbE *crontab.CrontabEntry) error {
+		if reason := ctbE.SuppressedReason(time.Now()); reason != "" {
+			slog.Info("suppressed task run",
+				slog.String("id", id.String()),
+				slog.String("reason", reason),
+			)
+
+			return errRunNotCounted
+		}
+
+		run = true
+
+		if ctbE.MaxRuns == 0 {
+			return errRunNotCounted
+		}
+
+		ctbE.Runs++
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errRunNotCounted) {
+		return false, err
+	}
+
+	return run, nil
+}
+
+// Called by the CLI once a task it was started for has finished, with the
+// error of its last attempt. One-shot tasks are removed so they do not fire
+// again next year, as are tasks that have used up their runs. They go to the
+// archive with the outcome as the reason, so a failed one can be looked at
+// and restored.
+func (t TaskResource) FinishTaskRun(id uuid.UUID, runErr error) error {
+	ctbE, err := t.crontabManager.GetCrontabEntryByID(id)
+	if err != nil {
+		return err
+	}
+
+	remaining, limited := ctbE.RemainingRuns()
+	if !ctbE.IsOneShot() && !(limited && remaining == 0) {
+		return nil
+	}
+
+	reason := "completed"
+	if runErr != nil {
+		reason = fmt.Sprintf("last run failed: %s", runErr)
+
+		slog.Warn("archiving task after its last run failed",
+			slog.String("id", id.String()),
+			slog.String("error", runErr.Error()),
+		)
+	}
+
+	err = t.archiving([]crontab.CrontabEntry{ctbE}, reason, func() error {
+		return t.crontabManager.RemoveCrontabEntryByID(id)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	t.recordRevision(fmt.Sprintf("task %s %s", id, reason))
+
+	return nil
+}
+
+// Whether the task can never fire again. One-shot tasks get a grace period,
+// since they normally clean up after themselves.
+func isExpired(ctbE crontab.CrontabEntry, now time.Time) bool {
+	if ctbE.IsOneShot() && ctbE.RunAt.Before(now.Add(-oneShotGrace)) {
+		return true
+	}
+
+	if ctbE.EndAt != nil && !now.Before(*ctbE.EndAt) {
+		return true
+	}
+
+	remaining, limited := ctbE.RemainingRuns()
+
+	return limited && remaining == 0
+}
+
+// Why the task can never fire again, or empty if it still can
+func expiredReason(ctbE crontab.CrontabEntry, now time.Time) string {
+	if ctbE.IsOneShot() && !ctbE.RunAt.After(now) {
+		return "run_at has passed"
+	}
+
+	if ctbE.EndAt != nil && !now.Before(*ctbE.EndAt) {
+		return "end_at has passed"
+	}
+
+	if remaining, limited := ctbE.RemainingRuns(); limited && remaining == 0 {
+		return "max_runs reached"
+	}
+
+	return ""
+}
+
+// Removes tasks that will never fire again but were not cleaned up when they
+// last ran, e.g. missed one-shot tasks or tasks past their end_at. Paused
+// tasks are left alone, including those maintenance mode suspended, until
+// they are resumed. So are tasks with a run still going or retrying, which
+// clean up after themselves when it finishes.
+func (t TaskResource) SweepExpiredTasks() error {
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expired := func(ctbE crontab.CrontabEntry) bool {
+		return !ctbE.Paused && isExpired(ctbE, now) && !t.taskRunning(ctbE.ID)
+	}
+
+	// Saves taking the store's lock on every sweep
+	if !slices.ContainsFunc(entries, expired) {
+		return nil
+	}
+
+	removed, err := t.removeMatching(expired)
+	if err != nil || len(removed) == 0 {
+		return err
+	}
+
+	t.recordRevision(fmt.Sprintf("sweep %d expired tasks", len(removed)))
+
+	slog.Info("swept expired tasks", slog.Int("count", len(removed)))
+
+	return nil
+}
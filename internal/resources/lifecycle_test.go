@@ -0,0 +1,251 @@
+package resources
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItRejectsAnInvalidValidityWindow(t *testing.T) {
+	t.Parallel()
+
+	past := time.Now().Add(-time.Hour)
+	start := time.Now().Add(2 * time.Hour)
+	end := time.Now().Add(time.Hour)
+
+	_, err := newTaskEntry("* * * * *", "cli start-game 1", WithValidity(nil, &past))
+	assert.ErrorIs(t, err, ErrInvalidValidity)
+
+	_, err = newTaskEntry("* * * * *", "cli start-game 1", WithValidity(&start, &end))
+	assert.ErrorIs(t, err, ErrInvalidValidity)
+
+	_, err = newTaskEntry("* * * * *", "cli start-game 1", WithMaxRuns(-1))
+	assert.ErrorIs(t, err, ErrInvalidMaxRuns)
+
+	ctbE, err := newTaskEntry("* * * * *", "cli start-game 1", WithValidity(&end, &start), WithMaxRuns(3))
+	require.NoError(t, err)
+	assert.True(t, ctbE.IsTracked())
+	assert.Equal(t, 3, ctbE.MaxRuns)
+}
+
+func Test_ItCountsRunsWithinTheWindow(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	stored := &crontab.CrontabEntry{ID: id, MaxRuns: 2, Runs: 1}
+	mockCrontabHandler.On("UpdateCrontabEntryByID", id).Return(stored, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	run, err := tR.BeginTaskRun(id)
+
+	require.NoError(t, err)
+	assert.True(t, run)
+	assert.Equal(t, 2, stored.Runs)
+}
+
+func Test_ItSuppressesRunsOutsideTheWindow(t *testing.T) {
+	t.Parallel()
+
+	notStartedID, _ := uuid.NewV7()
+	endedID, _ := uuid.NewV7()
+	exhaustedID, _ := uuid.NewV7()
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	exhausted := &crontab.CrontabEntry{ID: exhaustedID, MaxRuns: 1, Runs: 1}
+
+	mockCrontabHandler.On("UpdateCrontabEntryByID", notStartedID).
+		Return(&crontab.CrontabEntry{ID: notStartedID, StartAt: &future}, nil)
+	mockCrontabHandler.On("UpdateCrontabEntryByID", endedID).
+		Return(&crontab.CrontabEntry{ID: endedID, EndAt: &past}, nil)
+	mockCrontabHandler.On("UpdateCrontabEntryByID", exhaustedID).
+		Return(exhausted, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	for _, id := range []uuid.UUID{notStartedID, endedID, exhaustedID} {
+		run, err := tR.BeginTaskRun(id)
+
+		require.NoError(t, err)
+		assert.False(t, run)
+	}
+
+	assert.Equal(t, 1, exhausted.Runs)
+}
+
+func Test_ItDoesNotRunTasksThatNoLongerExist(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("UpdateCrontabEntryByID", id).
+		Return((*crontab.CrontabEntry)(nil), crontab.ErrCrontabEntryNotFound)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	run, err := tR.BeginTaskRun(id)
+
+	assert.ErrorIs(t, err, crontab.ErrCrontabEntryNotFound)
+	assert.False(t, run)
+}
+
+func Test_ItRemovesTasksThatUsedUpTheirRuns(t *testing.T) {
+	t.Parallel()
+
+	exhaustedID, _ := uuid.NewV7()
+	remainingID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", exhaustedID).
+		Return(crontab.CrontabEntry{ID: exhaustedID, MaxRuns: 2, Runs: 2}, nil)
+	mockCrontabHandler.On("GetCrontabEntryByID", remainingID).
+		Return(crontab.CrontabEntry{ID: remainingID, MaxRuns: 2, Runs: 1}, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", exhaustedID).Return(nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	require.NoError(t, tR.FinishTaskRun(exhaustedID, nil))
+	require.NoError(t, tR.FinishTaskRun(remainingID, nil))
+
+	mockCrontabHandler.AssertCalled(t, "RemoveCrontabEntryByID", exhaustedID)
+	mockCrontabHandler.AssertNotCalled(t, "RemoveCrontabEntryByID", remainingID)
+}
+
+func Test_ItRemovesOneShotTasksOnceFired(t *testing.T) {
+	t.Parallel()
+
+	oneShotID, _ := uuid.NewV7()
+	recurringID, _ := uuid.NewV7()
+	runAt := time.Now()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", oneShotID).
+		Return(crontab.CrontabEntry{ID: oneShotID, RunAt: &runAt}, nil)
+	mockCrontabHandler.On("GetCrontabEntryByID", recurringID).
+		Return(crontab.CrontabEntry{ID: recurringID}, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", oneShotID).Return(nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	require.NoError(t, tR.FinishTaskRun(oneShotID, nil))
+	require.NoError(t, tR.FinishTaskRun(recurringID, nil))
+
+	mockCrontabHandler.AssertCalled(t, "RemoveCrontabEntryByID", oneShotID)
+	mockCrontabHandler.AssertNotCalled(t, "RemoveCrontabEntryByID", recurringID)
+}
+
+func Test_ItArchivesOneShotTasksWhoseRunFailed(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	runAt := time.Now()
+	ctbE.RunAt = &runAt
+	id := ctbE.ID
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetCrontabEntryByID", id).Return(ctbE, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", id).Return(nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithArchive(filepath.Join(t.TempDir(), "archive.json"), 0),
+	)
+
+	require.NoError(t, tR.FinishTaskRun(id, errors.New("rabbitmq unavailable")))
+
+	archived, err := tR.GetArchivedTasks()
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, id, archived[0].Entry.ID)
+	assert.Equal(t, "last run failed: rabbitmq unavailable", archived[0].Reason)
+}
+
+func Test_ItSweepsExpiredTasks(t *testing.T) {
+	t.Parallel()
+
+	missedID, _ := uuid.NewV7()
+	upcomingID, _ := uuid.NewV7()
+	recurringID, _ := uuid.NewV7()
+	endedID, _ := uuid.NewV7()
+	exhaustedID, _ := uuid.NewV7()
+	pausedID, _ := uuid.NewV7()
+	missed := time.Now().Add(-time.Hour)
+	upcoming := time.Now().Add(time.Hour)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{
+		{ID: missedID, RunAt: &missed},
+		{ID: upcomingID, RunAt: &upcoming},
+		{ID: recurringID},
+		{ID: endedID, EndAt: &missed},
+		{ID: exhaustedID, MaxRuns: 3, Runs: 3},
+		{ID: pausedID, RunAt: &missed, Paused: true},
+	}
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(slices.Clone(written), nil)
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	require.NoError(t, tR.SweepExpiredTasks())
+
+	require.Len(t, written, 3)
+	assert.Equal(t, upcomingID, written[0].ID)
+	assert.Equal(t, recurringID, written[1].ID)
+	assert.Equal(t, pausedID, written[2].ID, "paused tasks are kept until they are resumed")
+}
+
+func Test_ItSweepsTheRestWhenAnExpiredTaskIsRemovedMeanwhile(t *testing.T) {
+	t.Parallel()
+
+	goneID, _ := uuid.NewV7()
+	endedID, _ := uuid.NewV7()
+	recurringID, _ := uuid.NewV7()
+	missed := time.Now().Add(-time.Hour)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{
+		{ID: goneID, RunAt: &missed},
+		{ID: endedID, EndAt: &missed},
+		{ID: recurringID},
+	}, nil)
+
+	// The one-shot task is removed before the sweep takes the store's lock
+	written := []crontab.CrontabEntry{{ID: endedID, EndAt: &missed}, {ID: recurringID}}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	require.NoError(t, tR.SweepExpiredTasks())
+
+	require.Len(t, written, 1)
+	assert.Equal(t, recurringID, written[0].ID)
+}
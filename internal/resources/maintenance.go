@@ -0,0 +1,344 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+const (
+	MAINTENANCE_REJECT = "reject"
+	MAINTENANCE_QUEUE  = "queue"
+)
+
+var (
+	ErrMaintenanceActive        = errors.New("maintenance mode is on, scheduling is suspended")
+	ErrMaintenanceAlreadyActive = errors.New("maintenance mode is already on")
+	ErrMaintenanceNotActive     = errors.New("maintenance mode is not on")
+
+	errMaintenanceNotConfigured = errors.New("maintenance state store not configured")
+	// Returned inside a state update to skip saving a state that didn't change
+	errMaintenanceUnchanged = errors.New("maintenance state unchanged")
+)
+
+type MaintenanceState struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledBy string     `json:"enabled_by,omitempty"`
+	EnabledAt time.Time  `json:"enabled_at,omitzero"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Entries we paused (or queued) ourselves. Only these are resumed when
+	// maintenance ends, so tasks that were already paused stay paused.
+	SuspendedIDs []uuid.UUID `json:"suspended_ids,omitempty"`
+}
+
+func (ms MaintenanceState) hasExpired(now time.Time) bool {
+	return ms.Enabled && ms.ExpiresAt != nil && !now.Before(*ms.ExpiresAt)
+}
+
+// Reports maintenance mode that has expired as off. The suspended tasks are
+// resumed by whichever state update comes next, see endExpiredMaintenance.
+func (t TaskResource) GetMaintenance() (MaintenanceState, error) {
+	if t.maintenance == nil {
+		return MaintenanceState{}, errMaintenanceNotConfigured
+	}
+
+	state, err := t.maintenance.Load()
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+
+	if state.hasExpired(time.Now()) {
+		return MaintenanceState{}, nil
+	}
+
+	return state, nil
+}
+
+// Pauses every active managed entry in a single crontab rewrite and records
+// who did it, so DisableMaintenance can put things back exactly as they were
+func (t TaskResource) EnableMaintenance(enabledBy string, expiresAt *time.Time) (MaintenanceState, error) {
+	var out MaintenanceState
+
+	if t.maintenance == nil {
+		return out, errMaintenanceNotConfigured
+	}
+
+	if enabledBy == "" {
+		return out, errors.New("enabled_by is required")
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return out, fmt.Errorf("expiry %s is in the past", expiresAt.Format(time.RFC3339))
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		if _, err := t.endExpiredMaintenance(state); err != nil {
+			return err
+		}
+
+		if state.Enabled {
+			return ErrMaintenanceAlreadyActive
+		}
+
+		var suspended []uuid.UUID
+		err := t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+			for idx, item := range *entries {
+				if item.Paused {
+					continue
+				}
+
+				(*entries)[idx].Paused = true
+				suspended = append(suspended, item.ID)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		*state = MaintenanceState{
+			Enabled:      true,
+			EnabledBy:    enabledBy,
+			EnabledAt:    time.Now().UTC(),
+			ExpiresAt:    expiresAt,
+			SuspendedIDs: suspended,
+		}
+		out = *state
+
+		return nil
+	})
+
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+
+	t.recordRevision("enable maintenance")
+
+	slog.Info("maintenance mode enabled",
+		slog.String("enabled_by", enabledBy),
+		slog.Int("suspended", len(out.SuspendedIDs)),
+	)
+
+	return out, nil
+}
+
+// Resumes the entries maintenance mode suspended, in a single crontab rewrite.
+// Tasks paused or resumed by hand since are left as they are.
+func (t TaskResource) DisableMaintenance() (MaintenanceState, error) {
+	if t.maintenance == nil {
+		return MaintenanceState{}, errMaintenanceNotConfigured
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		if !state.Enabled {
+			return ErrMaintenanceNotActive
+		}
+
+		return t.endMaintenance(state)
+	})
+
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+
+	t.recordRevision("disable maintenance")
+
+	slog.Info("maintenance mode disabled")
+
+	return MaintenanceState{}, nil
+}
+
+// Called on an interval by long running processes so an expiry still takes
+// effect when nobody is calling the API. Maintenance mode that is already
+// off is left alone.
+func (t TaskResource) ExpireMaintenance() error {
+	if t.maintenance == nil {
+		return nil
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		expired, err := t.endExpiredMaintenance(state)
+		if err != nil {
+			return err
+		}
+
+		if !expired {
+			return errMaintenanceUnchanged
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errMaintenanceUnchanged) {
+		return nil
+	}
+
+	return err
+}
+
+// Ends maintenance mode in state if it has expired, reporting whether it
+// did. Must be called inside a maintenance state update.
+func (t TaskResource) endExpiredMaintenance(state *MaintenanceState) (bool, error) {
+	if !state.hasExpired(time.Now()) {
+		return false, nil
+	}
+
+	slog.Info("maintenance mode expired",
+		slog.String("enabled_by", state.EnabledBy),
+	)
+
+	if err := t.endMaintenance(state); err != nil {
+		return false, err
+	}
+
+	t.recordRevision("maintenance expired")
+
+	return true, nil
+}
+
+// Resumes the entries maintenance mode suspended and clears state. Must be
+// called inside a maintenance state update.
+func (t TaskResource) endMaintenance(state *MaintenanceState) error {
+	err := t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+		for idx, item := range *entries {
+			if slices.Contains(state.SuspendedIDs, item.ID) {
+				(*entries)[idx].Paused = false
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	*state = MaintenanceState{}
+
+	return nil
+}
+
+// Hands fn a function applying the maintenance policy to each entry about to
+// be scheduled, and keeps maintenance mode from being turned on or off until
+// fn has written them. Queued entries are written paused and resumed along
+// with everything else. Entries that are already paused are left to the user.
+func (t TaskResource) underMaintenancePolicy(fn func(apply func(ctbE *crontab.CrontabEntry) error) error) error {
+	if t.maintenance == nil {
+		return fn(func(*crontab.CrontabEntry) error { return nil })
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		// Ends maintenance first if it has expired
+		expired, err := t.endExpiredMaintenance(state)
+		if err != nil {
+			return err
+		}
+
+		queued := false
+		apply := func(ctbE *crontab.CrontabEntry) error {
+			switch {
+			case !state.Enabled, ctbE.Paused:
+				return nil
+			case t.maintenancePolicy != MAINTENANCE_QUEUE:
+				return ErrMaintenanceActive
+			}
+
+			ctbE.Paused = true
+			state.SuspendedIDs = append(state.SuspendedIDs, ctbE.ID)
+			queued = true
+
+			return nil
+		}
+
+		if err := fn(apply); err != nil {
+			return err
+		}
+
+		if !queued && !expired {
+			return errMaintenanceUnchanged
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errMaintenanceUnchanged) {
+		return nil
+	}
+
+	return err
+}
+
+// Runs fn unless maintenance mode is on, and keeps it from being turned on
+// until fn returns. For changes that would undo what maintenance mode
+// suspended.
+func (t TaskResource) outsideMaintenance(fn func() error) error {
+	if t.maintenance == nil {
+		return fn()
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		expired, err := t.endExpiredMaintenance(state)
+		if err != nil {
+			return err
+		}
+
+		if state.Enabled {
+			return ErrMaintenanceActive
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		if !expired {
+			return errMaintenanceUnchanged
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errMaintenanceUnchanged) {
+		return nil
+	}
+
+	return err
+}
+
+// Runs fn, which pauses or resumes a task by hand, and forgets that
+// maintenance mode suspended the task, so ending maintenance leaves it as
+// the user set it
+func (t TaskResource) overridingMaintenance(id uuid.UUID, fn func() error) error {
+	if t.maintenance == nil {
+		return fn()
+	}
+
+	err := t.maintenance.Update(func(state *MaintenanceState) error {
+		if err := fn(); err != nil {
+			return err
+		}
+
+		if !slices.Contains(state.SuspendedIDs, id) {
+			return errMaintenanceUnchanged
+		}
+
+		state.SuspendedIDs = slices.DeleteFunc(state.SuspendedIDs, func(suspended uuid.UUID) bool {
+			return suspended == id
+		})
+
+		return nil
+	})
+
+	if errors.Is(err, errMaintenanceUnchanged) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,268 @@
+package resources
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItSuspendsActiveTasksForMaintenance(t *testing.T) {
+	t.Parallel()
+
+	activeID, _ := uuid.NewV7()
+	pausedID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{
+		{ID: activeID, Cron: exampleTestCron(), Cmd: "test-command"},
+		{ID: pausedID, Cron: exampleTestCron(), Cmd: "test-command", Paused: true},
+	}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(written, nil)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithMaintenance(filepath.Join(t.TempDir(), "maintenance.json"), MAINTENANCE_REJECT),
+	)
+
+	state, err := tR.EnableMaintenance("ops", nil)
+
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.Equal(t, "ops", state.EnabledBy)
+	assert.Equal(t, []uuid.UUID{activeID}, state.SuspendedIDs)
+	assert.True(t, written[0].Paused)
+	assert.True(t, written[1].Paused)
+
+	_, err = tR.EnableMaintenance("ops", nil)
+	assert.ErrorIs(t, err, ErrMaintenanceAlreadyActive)
+}
+
+func Test_ItOnlyResumesSuspendedTasks(t *testing.T) {
+	t.Parallel()
+
+	activeID, _ := uuid.NewV7()
+	pausedID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{
+		{ID: activeID, Cron: exampleTestCron(), Cmd: "test-command", Paused: true},
+		{ID: pausedID, Cron: exampleTestCron(), Cmd: "test-command", Paused: true},
+	}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(written, nil)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, ""))
+
+	err := tR.maintenance.Save(MaintenanceState{
+		Enabled:      true,
+		EnabledBy:    "ops",
+		EnabledAt:    time.Now(),
+		SuspendedIDs: []uuid.UUID{activeID},
+	})
+	require.NoError(t, err)
+
+	state, err := tR.DisableMaintenance()
+
+	require.NoError(t, err)
+	assert.False(t, state.Enabled)
+	assert.False(t, written[0].Paused)
+	assert.True(t, written[1].Paused)
+}
+
+func Test_ItRejectsSchedulesDuringMaintenance(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, MAINTENANCE_REJECT))
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops"}))
+
+	_, err := tR.ScheduleTask("* * * * *", "test-command")
+
+	assert.ErrorIs(t, err, ErrMaintenanceActive)
+	mockCrontabHandler.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+}
+
+func Test_ItQueuesSchedulesDuringMaintenance(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	var written []crontab.CrontabEntry
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			written = args.Get(0).([]crontab.CrontabEntry)
+		})
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, MAINTENANCE_QUEUE))
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops"}))
+
+	id, err := tR.ScheduleTask("* * * * *", "test-command")
+
+	require.NoError(t, err)
+	assert.True(t, written[0].Paused)
+
+	state, _ := tR.GetMaintenance()
+	assert.Contains(t, state.SuspendedIDs, id)
+}
+
+func Test_ItDisablesExpiredMaintenance(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("UpdateCrontabEntries").Return([]crontab.CrontabEntry{}, nil)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, ""))
+
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops", ExpiresAt: &expired}))
+
+	err := tR.ExpireMaintenance()
+	require.NoError(t, err)
+
+	state, _ := tR.GetMaintenance()
+	assert.False(t, state.Enabled)
+	mockCrontabHandler.AssertExpectations(t)
+}
+
+func Test_ItReportsExpiredMaintenanceAsOffWithoutEndingIt(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, ""))
+
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops", ExpiresAt: &expired}))
+
+	state, err := tR.GetMaintenance()
+	require.NoError(t, err)
+	assert.False(t, state.Enabled)
+	mockCrontabHandler.AssertNotCalled(t, "UpdateCrontabEntries")
+
+	stored, err := tR.maintenance.Load()
+	require.NoError(t, err)
+	assert.True(t, stored.Enabled, "reading maintenance mode does not change it")
+}
+
+func Test_ItEndsExpiredMaintenanceOnceWhenSchedulingConcurrently(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("UpdateCrontabEntries").Return([]crontab.CrontabEntry{}, nil)
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, MAINTENANCE_REJECT))
+
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops", ExpiresAt: &expired}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for range 5 {
+		wg.Go(func() {
+			_, err := tR.ScheduleTask("* * * * *", "test-command")
+			errs <- err
+		})
+	}
+	wg.Go(func() {
+		errs <- tR.ExpireMaintenance()
+	})
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	mockCrontabHandler.AssertNumberOfCalls(t, "UpdateCrontabEntries", 1)
+	mockCrontabHandler.AssertNumberOfCalls(t, "WriteCrontabEntries", 5)
+}
+
+func Test_ItLeavesTasksPausedByHandDuringMaintenancePaused(t *testing.T) {
+	t.Parallel()
+
+	pausedID, _ := uuid.NewV7()
+	resumedID, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("PauseCrontabEntryByID", pausedID).Return(nil)
+	mockCrontabHandler.On("ResumeCrontabEntryByID", resumedID).Return(nil)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, ""))
+
+	err := tR.maintenance.Save(MaintenanceState{
+		Enabled:      true,
+		EnabledBy:    "ops",
+		SuspendedIDs: []uuid.UUID{pausedID, resumedID},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, tR.PauseTaskByID(pausedID))
+	require.NoError(t, tR.ResumeTaskByID(resumedID))
+
+	state, err := tR.GetMaintenance()
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.Empty(t, state.SuspendedIDs, "ending maintenance leaves them as they were set by hand")
+}
+
+func Test_ItDoesNotQueueTasksThatArePausedAlready(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).Return(nil)
+
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithMaintenance(path, MAINTENANCE_QUEUE))
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops"}))
+
+	id, _ := uuid.NewV7()
+	paused := crontab.CrontabEntry{ID: id, Paused: true}
+	active := crontab.CrontabEntry{ID: uuid.Must(uuid.NewV7())}
+
+	err := tR.underMaintenancePolicy(func(apply func(*crontab.CrontabEntry) error) error {
+		require.NoError(t, apply(&paused))
+		require.NoError(t, apply(&active))
+
+		return tR.crontabManager.WriteCrontabEntries([]crontab.CrontabEntry{paused, active})
+	})
+	require.NoError(t, err)
+
+	state, err := tR.GetMaintenance()
+	require.NoError(t, err)
+	assert.True(t, active.Paused)
+	assert.Equal(t, []uuid.UUID{active.ID}, state.SuspendedIDs)
+}
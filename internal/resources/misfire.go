@@ -0,0 +1,160 @@
+package resources
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+var errFireLogNotConfigured = errors.New("fire log not configured")
+
+// Remembers when each task last fired, so missed runs can be found
+func WithFireLog(fl *crontab.FireLog) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.fires = fl
+	}
+}
+
+// What happens to runs the task missed while nothing was firing it: skip,
+// run_once or run_all. Empty skips them.
+func WithMisfirePolicy(policy string) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if err := crontab.ValidateMisfirePolicy(policy); err != nil {
+			return err
+		}
+
+		ctbE.MisfirePolicy = policy
+
+		return nil
+	}
+}
+
+// Works out the runs each task missed since it last ran successfully, looking
+// back no further than lookback, and marks the ones its misfire policy catches
+// up. The rest are recorded as skipped and the fire log moves past them, so
+// they are only reported once. Runs that are caught up move the log on
+// themselves once they go through, so one that never does is reported again
+// by the next check. Runs missed before a task was created or last paused or
+// resumed do not count.
+func (t TaskResource) MissedRuns(now time.Time, lookback time.Duration) ([]crontab.MissedRun, error) {
+	if t.fires == nil {
+		return nil, errFireLogNotConfigured
+	}
+
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[uuid.UUID]bool, len(entries))
+	for _, ctbE := range entries {
+		current[ctbE.ID] = true
+	}
+
+	earliest := now.Add(-lookback)
+
+	var missed []crontab.MissedRun
+	var skipped []crontab.Run
+
+	// The log stays locked throughout, so a run the cli records meanwhile is
+	// neither missed nor overwritten
+	err = t.fires.Update(func(fires map[uuid.UUID]time.Time, ok bool) error {
+		maps.DeleteFunc(fires, func(id uuid.UUID, _ time.Time) bool {
+			return !current[id]
+		})
+
+		// Nothing was tracked before, so there is nothing to compare against
+		// without reporting every task as having missed its runs
+		if !ok {
+			for _, ctbE := range entries {
+				fires[ctbE.ID] = now
+			}
+
+			return nil
+		}
+
+		for _, ctbE := range entries {
+			since := latest(fires[ctbE.ID], ctbE.Meta.CreatedAt, ctbE.Meta.UpdatedAt, earliest)
+
+			if ctbE.Paused || !ctbE.RunsCLI() {
+				fires[ctbE.ID] = latest(fires[ctbE.ID], now)
+				continue
+			}
+
+			// The log only moves up to the first run that is caught up
+			upTo := now
+			caughtUp := false
+
+			// Cron fires in local time
+			due := ctbE.FiresBetween(since.In(time.Local), now)
+			for i, at := range due {
+				mr := crontab.MissedRun{Task: ctbE, ScheduledAt: at}
+
+				switch ctbE.MisfirePolicy {
+				case crontab.MISFIRE_RUN_ALL:
+					mr.CatchUp = true
+				case crontab.MISFIRE_RUN_ONCE:
+					mr.CatchUp = i == len(due)-1
+				}
+
+				switch {
+				case mr.CatchUp && !caughtUp:
+					upTo = since
+					if i > 0 {
+						upTo = due[i-1]
+					}
+					caughtUp = true
+				case !mr.CatchUp:
+					record := crontab.NewRun(ctbE.ID, crontab.RUN_SOURCE_CATCH_UP, at)
+					record.Skip("missed")
+					skipped = append(skipped, record)
+				}
+
+				missed = append(missed, mr)
+			}
+
+			fires[ctbE.ID] = latest(fires[ctbE.ID], upTo)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range skipped {
+		t.recordTaskRun(record)
+	}
+
+	return missed, nil
+}
+
+func (t TaskResource) recordFire(id uuid.UUID, at time.Time) {
+	if t.fires == nil {
+		return
+	}
+
+	if err := t.fires.Record(id, at); err != nil {
+		slog.Error("unable to record task fire",
+			slog.String("id", id.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func latest(times ...time.Time) time.Time {
+	var out time.Time
+	for _, t := range times {
+		if t.After(out) {
+			out = t
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,217 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func newMisfireTaskResource(t *testing.T, fires *crontab.FireLog, entries ...crontab.CrontabEntry) TaskResource {
+	t.Helper()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(entries, nil)
+
+	return CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithRunHistory(crontab.NewRunHistory(filepath.Join(t.TempDir(), "runs.json"), 10, 0)),
+		WithFireLog(fires),
+	)
+}
+
+func hourlyTask(t *testing.T, policy string, created time.Time) crontab.CrontabEntry {
+	t.Helper()
+
+	var cron parser.Cron
+	require.NoError(t, cron.UnmarshalText([]byte("0 * * * *")))
+
+	id, _ := uuid.NewV7()
+
+	return crontab.CrontabEntry{
+		ID:            id,
+		Cron:          cron,
+		Cmd:           "cli start-game 1",
+		MisfirePolicy: policy,
+		Meta:          crontab.Metadata{CreatedAt: created},
+	}
+}
+
+func Test_ItAppliesEachTasksMisfirePolicy(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 6, 1, 20, 30, 0, 0, time.Local)
+	created := now.AddDate(0, 0, -7)
+
+	skip := hourlyTask(t, "", created)
+	once := hourlyTask(t, crontab.MISFIRE_RUN_ONCE, created)
+	all := hourlyTask(t, crontab.MISFIRE_RUN_ALL, created)
+	paused := hourlyTask(t, crontab.MISFIRE_RUN_ALL, created)
+	paused.Paused = true
+	script := hourlyTask(t, crontab.MISFIRE_RUN_ALL, created)
+	script.Cmd = "/usr/local/bin/backup.sh"
+
+	// Down since just after the 17:00 runs
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	for _, ctbE := range []crontab.CrontabEntry{skip, once, all, paused, script} {
+		require.NoError(t, fires.Record(ctbE.ID, now.Add(-3*time.Hour-30*time.Minute)))
+	}
+
+	tR := newMisfireTaskResource(t, fires, skip, once, all, paused, script)
+
+	missed, err := tR.MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+
+	caughtUp := map[uuid.UUID][]int{}
+	count := map[uuid.UUID]int{}
+	for _, mr := range missed {
+		count[mr.Task.ID]++
+		if mr.CatchUp {
+			caughtUp[mr.Task.ID] = append(caughtUp[mr.Task.ID], mr.ScheduledAt.Hour())
+		}
+	}
+
+	assert.Equal(t, map[uuid.UUID]int{skip.ID: 3, once.ID: 3, all.ID: 3}, count)
+	assert.Equal(t, map[uuid.UUID][]int{once.ID: {20}, all.ID: {18, 19, 20}}, caughtUp)
+
+	runs, err := tR.GetTaskRuns(skip.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 3, "skipped missed runs are recorded")
+	assert.Equal(t, crontab.RUN_SKIPPED, runs[0].Outcome)
+	assert.Equal(t, "missed", runs[0].Reason)
+
+	again, err := tR.MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+
+	pending := map[uuid.UUID][]int{}
+	for _, mr := range again {
+		assert.True(t, mr.CatchUp, "skipped runs are only reported once")
+		pending[mr.Task.ID] = append(pending[mr.Task.ID], mr.ScheduledAt.Hour())
+	}
+	assert.Equal(t, caughtUp, pending, "runs are caught up until they go through")
+
+	for _, mr := range again {
+		tR.recordFire(mr.Task.ID, mr.ScheduledAt)
+	}
+
+	again, err = tR.MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, again, "caught up runs are not reported again")
+}
+
+func Test_ItLooksBackNoFurtherThanTheLookback(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 6, 1, 20, 30, 0, 0, time.Local)
+	ctbE := hourlyTask(t, crontab.MISFIRE_RUN_ALL, now.AddDate(0, 0, -7))
+
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	require.NoError(t, fires.Record(ctbE.ID, now.AddDate(0, 0, -2)))
+
+	missed, err := newMisfireTaskResource(t, fires, ctbE).MissedRuns(now, 2*time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, missed, 2)
+}
+
+func Test_ItStartsTrackingWithoutReportingMisses(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 6, 1, 20, 30, 0, 0, time.Local)
+	ctbE := hourlyTask(t, crontab.MISFIRE_RUN_ALL, now.AddDate(0, 0, -7))
+
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	tR := newMisfireTaskResource(t, fires, ctbE)
+
+	missed, err := tR.MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, missed, "there is no last fire to compare with yet")
+
+	missed, err = tR.MissedRuns(now.Add(time.Hour), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, missed, 1)
+}
+
+func Test_ItDoesNotCountRunsMissedBeforeATaskWasCreated(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 6, 1, 20, 30, 0, 0, time.Local)
+	ctbE := hourlyTask(t, crontab.MISFIRE_RUN_ALL, now.Add(-90*time.Minute))
+
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	require.NoError(t, fires.Update(func(map[uuid.UUID]time.Time, bool) error { return nil }))
+
+	missed, err := newMisfireTaskResource(t, fires, ctbE).MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, missed, 1)
+	assert.Equal(t, 20, missed[0].ScheduledAt.Hour())
+}
+
+func Test_ItOnlyTracksFiresThatRanSuccessfully(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	tR := newRunsTaskResource(t, ctbE)
+	tR.fires = fires
+
+	scheduledAt := time.Now().Truncate(time.Minute)
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_CLI, scheduledAt, func(ctx context.Context) (string, error) {
+		return "", errors.New("rabbitmq unavailable")
+	})
+	require.Error(t, err)
+
+	_, ok, err := fires.All()
+	require.NoError(t, err)
+	assert.False(t, ok, "a failed run still counts as missed")
+
+	err = tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_CLI, scheduledAt, func(ctx context.Context) (string, error) {
+		return "", nil
+	})
+	require.NoError(t, err)
+
+	last, _, err := fires.All()
+	require.NoError(t, err)
+	assert.True(t, scheduledAt.Equal(last[ctbE.ID]))
+}
+
+func Test_ItKeepsFiresRecordedAfterTheCheck(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 6, 1, 20, 30, 0, 0, time.Local)
+	ctbE := hourlyTask(t, crontab.MISFIRE_RUN_ALL, now.AddDate(0, 0, -7))
+	gone, _ := uuid.NewV7()
+
+	// A run the cli recorded while the check was going
+	fires := crontab.NewFireLog(filepath.Join(t.TempDir(), "fires.json"))
+	require.NoError(t, fires.Record(ctbE.ID, now.Add(time.Minute)))
+	require.NoError(t, fires.Record(gone, now))
+
+	missed, err := newMisfireTaskResource(t, fires, ctbE).MissedRuns(now, 24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, missed)
+
+	last, _, err := fires.All()
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Minute).Equal(last[ctbE.ID]))
+	assert.NotContains(t, last, gone, "removed tasks are dropped")
+}
+
+func Test_ItValidatesMisfirePolicies(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTaskEntry("* * * * *", "cli start-game 1", WithMisfirePolicy("catch_up"))
+	assert.ErrorIs(t, err, crontab.ErrInvalidMisfirePolicy)
+
+	ctbE, err := newTaskEntry("* * * * *", "cli start-game 1", WithMisfirePolicy(crontab.MISFIRE_RUN_ONCE))
+	require.NoError(t, err)
+	assert.Equal(t, crontab.MISFIRE_RUN_ONCE, ctbE.MisfirePolicy)
+}
@@ -35,6 +35,49 @@ func (mch *MockCrontabHandler) RemoveCrontabEntryByID(id uuid.UUID) error {
 	return args.Error(0)
 }
 
+func (mch *MockCrontabHandler) ReplaceCrontabEntries(entries []crontab.CrontabEntry) error {
+	args := mch.Called(entries)
+	return args.Error(0)
+}
+
+func (mch *MockCrontabHandler) PauseCrontabEntryByID(id uuid.UUID) error {
+	args := mch.Called(id)
+	return args.Error(0)
+}
+
+func (mch *MockCrontabHandler) ResumeCrontabEntryByID(id uuid.UUID) error {
+	args := mch.Called(id)
+	return args.Error(0)
+}
+
+// Runs fn on the entry the test returns for id, so the test can look at it
+// afterwards
+func (mch *MockCrontabHandler) UpdateCrontabEntryByID(id uuid.UUID, fn func(ctbE *crontab.CrontabEntry) error) error {
+	args := mch.Called(id)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+
+	return fn(args.Get(0).(*crontab.CrontabEntry))
+}
+
+// Runs fn on the entries the test returns, so the test can look at them
+// afterwards. Return a pointer to also see entries fn adds or removes.
+func (mch *MockCrontabHandler) UpdateCrontabEntries(fn func(entries *[]crontab.CrontabEntry) error) error {
+	args := mch.Called()
+	if err := args.Error(1); err != nil {
+		return err
+	}
+
+	if entries, ok := args.Get(0).(*[]crontab.CrontabEntry); ok {
+		return fn(entries)
+	}
+
+	entries := args.Get(0).([]crontab.CrontabEntry)
+
+	return fn(&entries)
+}
+
 // Mock of AdvancedMessageQueueHandler interface. Used only in tests
 type MockQueueHandler struct {
 	mock.Mock
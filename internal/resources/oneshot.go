@@ -0,0 +1,36 @@
+package resources
+
+import (
+	"time"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+func WithRunAt(at time.Time) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		runAt := at.UTC()
+		ctbE.RunAt = &runAt
+
+		return nil
+	}
+}
+
+func (t TaskResource) ScheduleOneShotTask(when, task string, opts ...ScheduleOptFn) (crontab.CrontabEntry, error) {
+	ctbE, _, err := t.ScheduleOneShotTaskOnce("", when, task, opts...)
+	return ctbE, err
+}
+
+// Schedules a task that fires once, at an RFC 3339 time or a relative one
+// such as "in 15m", and is removed after it has fired
+func (t TaskResource) ScheduleOneShotTaskOnce(key, when, task string, opts ...ScheduleOptFn) (crontab.CrontabEntry, bool, error) {
+	request := idempotencyRecord{RunAt: when, Cmd: task}
+
+	return t.scheduleOnce(key, request, func() (crontab.CrontabEntry, error) {
+		runAt, err := crontab.ParseRunAt(when, t.now())
+		if err != nil {
+			return crontab.CrontabEntry{}, err
+		}
+
+		return newTaskEntry(crontab.OneShotCron(runAt), task, append(opts, WithRunAt(runAt))...)
+	})
+}
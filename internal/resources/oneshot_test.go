@@ -0,0 +1,38 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItSchedulesAOneShotTask(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	var written []crontab.CrontabEntry
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			written = args.Get(0).([]crontab.CrontabEntry)
+		})
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	ctbE, err := tR.ScheduleOneShotTask("in 15m", "cli start-game 1")
+
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	require.True(t, written[0].IsOneShot())
+	assert.Equal(t, ctbE.ID, written[0].ID)
+	assert.Equal(t, crontab.OneShotCron(*written[0].RunAt), written[0].Cron.String())
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), *written[0].RunAt, time.Minute)
+}
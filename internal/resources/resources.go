@@ -2,28 +2,158 @@ package resources
 
 import (
 	"log/slog"
+	"path/filepath"
 
 	"github.com/captainmango/coco-cron-parser/internal/config"
 	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/events"
 	"github.com/captainmango/coco-cron-parser/internal/msq"
+	"github.com/captainmango/coco-cron-parser/internal/tasklock"
+	"github.com/captainmango/coco-cron-parser/internal/tasklog"
+	"github.com/captainmango/coco-cron-parser/internal/watcher"
+)
+
+const (
+	SCHEDULE_BACKEND_CRONTAB    = "crontab"
+	SCHEDULE_BACKEND_SYSTEMD    = "systemd"
+	SCHEDULE_BACKEND_KUBERNETES = "kubernetes"
 )
 
 type Resources struct {
 	TaskResource TaskResource
+	Events       *events.Bus
+	Watcher      *watcher.Watcher
+	Logs         *tasklog.Logs
 }
 
 func CreateResources() Resources {
 	queueHandler, err := msq.NewRabbitMQHandler(msq.WithConnStr(config.Config.RabbitMQHost))
-	crontabHandler := crontab.NewCrontabManager()
-
 	if err != nil {
 		slog.Error(err.Error())
 	}
 
+	bus := events.NewBus()
+	taskStore := crontab.NewFileTaskStore(filepath.Join(config.Config.StateDir, "tasks.json"))
+	crontabHandler, taskWatcher := createCrontabHandler(taskStore, bus)
+
+	taskResource := CreateTaskResource(
+		crontabHandler,
+		queueHandler,
+		WithMaintenance(
+			filepath.Join(config.Config.StateDir, "maintenance.json"),
+			config.Config.MaintenanceSchedulePolicy,
+		),
+		WithRevisionHistory(crontab.NewRevisionHistory(
+			filepath.Join(config.Config.StateDir, "revisions.json"),
+			config.Config.RevisionLimit,
+		)),
+		WithIdempotency(
+			filepath.Join(config.Config.StateDir, "idempotency.json"),
+			config.Config.IdempotencyWindow,
+		),
+		WithDuplicatePolicy(config.Config.DuplicatePolicy),
+		WithArchive(
+			filepath.Join(config.Config.StateDir, "archive.json"),
+			config.Config.ArchiveRetention,
+		),
+		WithRunHistory(crontab.NewRunHistory(
+			filepath.Join(config.Config.StateDir, "runs.json"),
+			config.Config.RunHistoryLimit,
+			config.Config.RunHistoryRetention,
+		)),
+		WithTaskLocks(tasklock.NewLocker(
+			filepath.Join(config.Config.StateDir, "locks"),
+			tasklock.WithReplaceGrace(config.Config.ReplaceGracePeriod),
+		)),
+		WithFireLog(crontab.NewFireLog(filepath.Join(config.Config.StateDir, "fires.json"))),
+	)
+
+	if err = taskResource.RecordBaselineRevision(); err != nil {
+		slog.Error("unable to record baseline crontab revision",
+			slog.String("error", err.Error()),
+		)
+	}
+
 	return Resources{
-		CreateTaskResource(
-			crontabHandler,
-			queueHandler,
+		TaskResource: taskResource,
+		Events:       bus,
+		Watcher:      taskWatcher,
+		Logs: tasklog.NewLogs(config.Config.TaskLogDir,
+			tasklog.WithMaxSize(config.Config.TaskLogMaxSize),
+			tasklog.WithMaxFiles(config.Config.TaskLogMaxFiles),
+			tasklog.WithMaxAge(config.Config.TaskLogMaxAge),
 		),
 	}
 }
+
+// Picks the backend tasks are scheduled with. Only the crontab can be
+// watched for outside changes, so the watcher is nil for the others.
+func createCrontabHandler(taskStore crontab.TaskStore, bus *events.Bus) (crontab.CrontabHandler, *watcher.Watcher) {
+	switch config.Config.ScheduleBackend {
+	case SCHEDULE_BACKEND_SYSTEMD:
+		opts := []crontab.SystemdManagerOptFn{
+			crontab.WithSystemdTaskStore(taskStore),
+			crontab.WithSystemdTaskLogDir(config.Config.TaskLogDir),
+		}
+		if config.Config.SystemdReload {
+			opts = append(opts, crontab.WithSystemctl(crontab.RunSystemctl))
+		}
+
+		return crontab.NewSystemdManager(config.Config.SystemdUnitDir, opts...), nil
+	case SCHEDULE_BACKEND_KUBERNETES:
+		return crontab.NewKubernetesManager(config.Config.KubernetesManifestDir,
+			crontab.WithKubernetesTaskStore(taskStore),
+			crontab.WithImage(config.Config.KubernetesImage),
+			crontab.WithNamespace(config.Config.KubernetesNamespace),
+			crontab.WithTimeZone(config.Config.KubernetesTimeZone),
+			crontab.WithConcurrencyPolicy(config.Config.KubernetesConcurrencyPolicy),
+			crontab.WithSecretName(config.Config.KubernetesSecretName),
+		), nil
+	case SCHEDULE_BACKEND_CRONTAB, "":
+	default:
+		slog.Error("unknown schedule backend, using the crontab",
+			slog.String("backend", config.Config.ScheduleBackend),
+		)
+	}
+
+	crontabManager := crontab.NewCrontabManager(
+		crontab.WithTaskStore(taskStore),
+		crontab.WithShards(config.Config.CrontabShardBy),
+		crontab.WithTaskLogDir(config.Config.TaskLogDir),
+		crontab.WithQuarantine(
+			filepath.Join(config.Config.StateDir, "quarantine.json"),
+			func(line crontab.TamperedLine) {
+				bus.Publish(events.Event{
+					Type:   events.TASK_TAMPERED,
+					TaskID: line.ID,
+					At:     line.FoundAt,
+					Source: "signature check",
+				})
+			},
+		),
+	)
+
+	// First run against the task store, adopt whatever is already scheduled
+	if tasks, err := taskStore.All(); err == nil && len(tasks) == 0 {
+		if _, err = crontabManager.ImportCrontabFile(); err != nil {
+			slog.Error("unable to import crontab into task store",
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	watcherOpts := []watcher.WatcherOptFn{
+		watcher.WithDebounce(config.Config.WatchDebounce),
+		watcher.WithPollInterval(config.Config.WatchPollInterval),
+	}
+
+	if config.Config.WatchPolling {
+		watcherOpts = append(watcherOpts, watcher.WithPolling())
+	}
+
+	if config.Config.CrontabShardBy != "" {
+		watcherOpts = append(watcherOpts, watcher.WithPattern(crontab.SHARD_FILE_PATTERN))
+	}
+
+	return crontabManager, watcher.NewWatcher(config.Config.CrontabFile, crontabManager, bus, watcherOpts...)
+}
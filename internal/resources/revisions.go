@@ -0,0 +1,228 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+var errRevisionsNotConfigured = errors.New("revision history not configured")
+
+func WithRevisionHistory(rh *crontab.RevisionHistory) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.revisions = rh
+	}
+}
+
+// Returns a copy of the resource that records changes against the given
+// author in the revision history
+func (t TaskResource) As(author string) TaskResource {
+	if author != "" {
+		t.author = author
+	}
+
+	return t
+}
+
+// Snapshots the entries as they are now. Failing to record a revision does
+// not undo the change that was just made, so it is only logged.
+func (t TaskResource) recordRevision(reason string) {
+	if t.revisions == nil {
+		return
+	}
+
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err == nil {
+		_, err = t.revisions.Record(t.author, reason, entries)
+	}
+
+	if err != nil {
+		slog.Error("unable to record crontab revision",
+			slog.String("reason", reason),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Gives an empty history a starting point to roll back to
+func (t TaskResource) RecordBaselineRevision() error {
+	if t.revisions == nil {
+		return nil
+	}
+
+	revs, err := t.revisions.List()
+	if err != nil {
+		return err
+	}
+
+	if len(revs) > 0 {
+		return nil
+	}
+
+	// An empty crontab has nothing worth rolling back to
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	t.recordRevision("baseline")
+
+	return nil
+}
+
+func (t TaskResource) GetRevisions() ([]crontab.Revision, error) {
+	if t.revisions == nil {
+		return nil, errRevisionsNotConfigured
+	}
+
+	return t.revisions.List()
+}
+
+func (t TaskResource) GetRevision(id int) (crontab.Revision, error) {
+	if t.revisions == nil {
+		return crontab.Revision{}, errRevisionsNotConfigured
+	}
+
+	return t.revisions.Get(id)
+}
+
+func (t TaskResource) DiffRevisions(from, to int) ([]crontab.DiffLine, error) {
+	fromRev, err := t.GetRevision(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toRev, err := t.GetRevision(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return crontab.DiffLines(fromRev.Lines(), toRev.Lines()), nil
+}
+
+// Writes the entries from the chosen revision back in a single crontab
+// rewrite. The rollback itself becomes a new revision. It is refused while
+// maintenance mode is on, since it would resume the tasks maintenance mode
+// suspended. Tasks keep the runs they have used up since the revision, and
+// ones that can never fire again, such as one-shot tasks that have fired
+// since, are left out. Snapshots hold no secret values, so they are taken
+// from the task as it is now or as it was archived. A task whose secrets are
+// no longer known is left out too.
+func (t TaskResource) RollbackToRevision(id int, reason string) (crontab.Revision, error) {
+	rev, err := t.GetRevision(id)
+	if err != nil {
+		return crontab.Revision{}, err
+	}
+
+	var dropped []uuid.UUID
+	err = t.outsideMaintenance(func() error {
+		archived, err := t.archivedEntries()
+		if err != nil {
+			return err
+		}
+
+		return t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+			*entries, dropped = rollbackEntries(rev.Entries, *entries, archived, time.Now())
+			return nil
+		})
+	})
+
+	if err != nil {
+		return crontab.Revision{}, err
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("rollback to revision %d", id)
+	}
+
+	t.recordRevision(reason)
+
+	slog.Info("rolled back crontab",
+		slog.Int("revision", id),
+		slog.String("author", t.author),
+		slog.Int("dropped", len(dropped)),
+	)
+
+	return rev, nil
+}
+
+// The revision's entries with the runs each task has used up since and its
+// secret values carried over, from the store or from the archive for tasks
+// removed since. Entries that can never fire again or whose secrets are no
+// longer known are dropped and their IDs returned.
+func rollbackEntries(revision, current []crontab.CrontabEntry, archived map[uuid.UUID]crontab.CrontabEntry, now time.Time) ([]crontab.CrontabEntry, []uuid.UUID) {
+	known := maps.Clone(archived)
+	if known == nil {
+		known = make(map[uuid.UUID]crontab.CrontabEntry, len(current))
+	}
+
+	for _, ctbE := range current {
+		if prev, ok := known[ctbE.ID]; ok {
+			ctbE.Runs = max(ctbE.Runs, prev.Runs)
+		}
+
+		known[ctbE.ID] = ctbE
+	}
+
+	out := make([]crontab.CrontabEntry, 0, len(revision))
+	var dropped []uuid.UUID
+	for _, ctbE := range revision {
+		ctbE.Runs = max(ctbE.Runs, known[ctbE.ID].Runs)
+
+		reason := expiredReason(ctbE, now)
+		if reason == "" && !restoreSecrets(&ctbE, known[ctbE.ID]) {
+			reason = "its secret values are no longer known"
+		}
+
+		if reason != "" {
+			slog.Info("leaving task out of rollback",
+				slog.String("id", ctbE.ID.String()),
+				slog.String("reason", reason),
+			)
+
+			dropped = append(dropped, ctbE.ID)
+			continue
+		}
+
+		out = append(out, ctbE)
+	}
+
+	return out, dropped
+}
+
+// Replaces the redacted secret values of a snapshot entry with the values of
+// the same variables in from. Reports false if any is missing.
+func restoreSecrets(ctbE *crontab.CrontabEntry, from crontab.CrontabEntry) bool {
+	if !slices.ContainsFunc(ctbE.Env, func(ev crontab.EnvVar) bool { return ev.Secret }) {
+		return true
+	}
+
+	env := slices.Clone(ctbE.Env)
+	for i, ev := range env {
+		// Snapshots taken before they were redacted still hold the value
+		if !ev.Secret || ev.Value != crontab.REDACTED {
+			continue
+		}
+
+		idx := slices.IndexFunc(from.Env, func(known crontab.EnvVar) bool {
+			return known.Name == ev.Name
+		})
+
+		if idx == -1 {
+			return false
+		}
+
+		env[i].Value = from.Env[idx].Value
+	}
+
+	ctbE.Env = env
+
+	return true
+}
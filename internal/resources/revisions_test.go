@@ -0,0 +1,194 @@
+package resources
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func Test_ItRecordsARevisionPerChange(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	entries := []crontab.CrontabEntry{
+		{ID: id, Cron: exampleTestCron(), Cmd: "test-command"},
+	}
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("PauseCrontabEntryByID", id).Return(nil)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(entries, nil)
+
+	tR := CreateTaskResource(
+		mockCrontabHandler,
+		mockQueueHandler,
+		WithRevisionHistory(crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)),
+	).As("ops")
+
+	require.NoError(t, tR.PauseTaskByID(id))
+
+	revs, err := tR.GetRevisions()
+
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	assert.Equal(t, "ops", revs[0].Author)
+	assert.Equal(t, "pause task "+id.String(), revs[0].Reason)
+	require.Len(t, revs[0].Entries, 1)
+	assert.Equal(t, id, revs[0].Entries[0].ID)
+}
+
+func Test_ItRollsBackToARevision(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	before := []crontab.CrontabEntry{
+		{ID: id, Cron: exampleTestCron(), Cmd: "test-command"},
+	}
+
+	rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+	baseline, err := rh.Record("ops", "baseline", before)
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	var written []crontab.CrontabEntry
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(before, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithRevisionHistory(rh))
+
+	rev, err := tR.RollbackToRevision(baseline.ID, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, baseline.ID, rev.ID)
+	require.Len(t, written, 1)
+	assert.Equal(t, id, written[0].ID)
+
+	revs, err := tR.GetRevisions()
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "rollback to revision 1", revs[0].Reason)
+
+	_, err = tR.RollbackToRevision(42, "")
+	assert.ErrorIs(t, err, crontab.ErrRevisionNotFound)
+}
+
+func Test_ItRefusesToRollBackDuringMaintenance(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+	before := []crontab.CrontabEntry{
+		{ID: id, Cron: exampleTestCron(), Cmd: "test-command"},
+	}
+
+	rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+	baseline, err := rh.Record("ops", "baseline", before)
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithRevisionHistory(rh),
+		WithMaintenance(filepath.Join(t.TempDir(), "maintenance.json"), MAINTENANCE_QUEUE),
+	)
+	require.NoError(t, tR.maintenance.Save(MaintenanceState{Enabled: true, EnabledBy: "ops", SuspendedIDs: []uuid.UUID{id}}))
+
+	_, err = tR.RollbackToRevision(baseline.ID, "")
+
+	assert.ErrorIs(t, err, ErrMaintenanceActive)
+	mockCrontabHandler.AssertNotCalled(t, "UpdateCrontabEntries")
+}
+
+func Test_ItKeepsTheLifecycleOfTasksWhenRollingBack(t *testing.T) {
+	t.Parallel()
+
+	limited, _ := uuid.NewV7()
+	fired, _ := uuid.NewV7()
+	used, _ := uuid.NewV7()
+
+	runAt := time.Now().Add(-time.Hour).UTC()
+	snapshot := []crontab.CrontabEntry{
+		{ID: limited, Cron: exampleTestCron(), Cmd: "test-command", MaxRuns: 5, Runs: 1},
+		{ID: fired, Cron: exampleTestCron(), Cmd: "test-command", RunAt: &runAt},
+		{ID: used, Cron: exampleTestCron(), Cmd: "test-command", MaxRuns: 2},
+	}
+
+	rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+	baseline, err := rh.Record("ops", "baseline", snapshot)
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	// The one-shot task fired and the limited one ran twice more since. The
+	// other one used up its runs and was archived.
+	current := snapshot[0]
+	current.Runs = 3
+	written := []crontab.CrontabEntry{current}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithRevisionHistory(rh),
+		WithArchive(filepath.Join(t.TempDir(), "archive.json"), time.Hour),
+	)
+
+	archivedUsed := snapshot[2]
+	archivedUsed.Runs = 2
+	_, err = tR.archive.add([]crontab.CrontabEntry{snapshot[1], archivedUsed}, "system", "completed")
+	require.NoError(t, err)
+
+	_, err = tR.RollbackToRevision(baseline.ID, "")
+	require.NoError(t, err)
+
+	require.Len(t, written, 1, "tasks that can never fire again are left out")
+	assert.Equal(t, limited, written[0].ID)
+	assert.Equal(t, 3, written[0].Runs, "runs used since the revision are kept")
+}
+
+func Test_ItRestoresSecretValuesWhenRollingBack(t *testing.T) {
+	t.Parallel()
+
+	kept, _ := uuid.NewV7()
+	removed, _ := uuid.NewV7()
+
+	secret := func(id uuid.UUID, value string) crontab.CrontabEntry {
+		return crontab.CrontabEntry{
+			ID:   id,
+			Cron: exampleTestCron(),
+			Cmd:  "test-command",
+			Env:  []crontab.EnvVar{{Name: "API_TOKEN", Value: value, Secret: true}},
+		}
+	}
+
+	rh := crontab.NewRevisionHistory(filepath.Join(t.TempDir(), "revisions.json"), 10)
+	baseline, err := rh.Record("ops", "baseline", []crontab.CrontabEntry{secret(kept, "s3cret"), secret(removed, "gone")})
+	require.NoError(t, err)
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	// The other task was removed since without an archive to keep it in
+	written := []crontab.CrontabEntry{secret(kept, "rotated")}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler, WithRevisionHistory(rh))
+
+	_, err = tR.RollbackToRevision(baseline.ID, "")
+	require.NoError(t, err)
+
+	require.Len(t, written, 1, "a task whose secrets are no longer known is left out")
+	assert.Equal(t, kept, written[0].ID)
+	assert.Equal(t, "rotated", written[0].Env[0].Value)
+}
@@ -0,0 +1,125 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+const ARG_ROOM_ID = "room_id"
+
+// Returned inside a crontab update to skip writing entries that didn't change
+var errArgsUnchanged = errors.New("task arguments unchanged")
+
+// Stores the named arguments of the command alongside the task, so it can be
+// found by them later
+func WithArgs(args map[string]string) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if len(args) > 0 {
+			ctbE.Args = maps.Clone(args)
+		}
+
+		return nil
+	}
+}
+
+// Fills in the arguments of tasks scheduled before they were stored, which
+// only have them in their command, by naming them with argsFor. Tasks that
+// already have arguments are left alone. Returns how many were filled in.
+func (t TaskResource) BackfillArgs(argsFor func(cmd string) map[string]string) (int, error) {
+	filled := 0
+
+	err := t.crontabManager.UpdateCrontabEntries(func(entries *[]crontab.CrontabEntry) error {
+		for idx := range *entries {
+			ctbE := &(*entries)[idx]
+			if ctbE.Args != nil {
+				continue
+			}
+
+			if args := argsFor(ctbE.Cmd); len(args) > 0 {
+				ctbE.Args = args
+				filled++
+			}
+		}
+
+		if filled == 0 {
+			return errArgsUnchanged
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errArgsUnchanged) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	t.recordRevision("backfill task arguments")
+
+	return filled, nil
+}
+
+// Finds every task scheduled with the given argument value
+func (t TaskResource) GetTasksByArg(key, value string) ([]crontab.CrontabEntry, error) {
+	entries, err := t.crontabManager.GetAllCrontabEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []crontab.CrontabEntry
+	for _, ctbE := range entries {
+		if hasArg(ctbE, key, value) {
+			out = append(out, ctbE)
+		}
+	}
+
+	return out, nil
+}
+
+func (t TaskResource) GetRoomTasks(roomID string) ([]crontab.CrontabEntry, error) {
+	return t.GetTasksByArg(ARG_ROOM_ID, roomID)
+}
+
+// Removes every task for the room in a single crontab write. Tasks are
+// matched again under the store's lock, so one removed meanwhile is skipped.
+func (t TaskResource) CancelRoomTasks(roomID string) (BatchResult, error) {
+	result := BatchResult{Items: []BatchItemResult{}}
+
+	entries, err := t.GetRoomTasks(roomID)
+	if err != nil || len(entries) == 0 {
+		return result, err
+	}
+
+	removed, err := t.removeMatching(func(ctbE crontab.CrontabEntry) bool {
+		return hasArg(ctbE, ARG_ROOM_ID, roomID)
+	})
+
+	if err != nil || len(removed) == 0 {
+		return result, err
+	}
+
+	for i, ctbE := range removed {
+		result.Items = append(result.Items, BatchItemResult{Op: BATCH_REMOVE, Index: i, ID: ctbE.ID, Entry: ctbE})
+	}
+	result.Committed = true
+
+	t.recordRevision(fmt.Sprintf("cancel tasks for room %s", roomID))
+
+	slog.Info("cancelled room tasks",
+		slog.String("room_id", roomID),
+		slog.Int("removed", len(removed)),
+	)
+
+	return result, nil
+}
+
+func hasArg(ctbE crontab.CrontabEntry, key, value string) bool {
+	v, ok := ctbE.Args[key]
+	return ok && v == value
+}
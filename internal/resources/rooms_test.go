@@ -0,0 +1,164 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+)
+
+func roomEntries() (crontab.CrontabEntry, crontab.CrontabEntry, crontab.CrontabEntry) {
+	first, _ := uuid.NewV7()
+	second, _ := uuid.NewV7()
+	other, _ := uuid.NewV7()
+
+	return crontab.CrontabEntry{ID: first, Cmd: "cli start-game 123", Args: map[string]string{ARG_ROOM_ID: "123"}},
+		crontab.CrontabEntry{ID: second, Cmd: "cli start-game 123", Args: map[string]string{ARG_ROOM_ID: "123"}},
+		crontab.CrontabEntry{ID: other, Cmd: "cli start-game 1234", Args: map[string]string{ARG_ROOM_ID: "1234"}}
+}
+
+func Test_ItFindsTasksByRoom(t *testing.T) {
+	t.Parallel()
+
+	first, second, other := roomEntries()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetAllCrontabEntries").
+		Return([]crontab.CrontabEntry{first, other, second, {ID: uuid.New(), Cmd: "cli start-game 123"}}, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	entries, err := tR.GetRoomTasks("123")
+
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, first.ID, entries[0].ID)
+	assert.Equal(t, second.ID, entries[1].ID)
+}
+
+func Test_ItCancelsEveryTaskForARoomInOneWrite(t *testing.T) {
+	t.Parallel()
+
+	first, second, other := roomEntries()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetAllCrontabEntries").
+		Return([]crontab.CrontabEntry{first, other, second}, nil)
+
+	written := []crontab.CrontabEntry{first, other, second}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil).Once()
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.CancelRoomTasks("123")
+
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	assert.Len(t, result.Items, 2)
+	require.Len(t, written, 1)
+	assert.Equal(t, other.ID, written[0].ID)
+}
+
+func Test_ItCancelsTheRestOfARoomWhenATaskIsRemovedMeanwhile(t *testing.T) {
+	t.Parallel()
+
+	first, second, other := roomEntries()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetAllCrontabEntries").
+		Return([]crontab.CrontabEntry{first, other, second}, nil)
+
+	// The first task is removed before the cancellation takes the store's lock
+	written := []crontab.CrontabEntry{other, second}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil).Once()
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.CancelRoomTasks("123")
+
+	require.NoError(t, err)
+	assert.True(t, result.Committed)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, second.ID, result.Items[0].ID)
+	assert.Equal(t, []crontab.CrontabEntry{other}, written)
+}
+
+func Test_ItDoesNotWriteWhenARoomHasNoTasks(t *testing.T) {
+	t.Parallel()
+
+	_, _, other := roomEntries()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	mockCrontabHandler.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{other}, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	result, err := tR.CancelRoomTasks("123")
+
+	require.NoError(t, err)
+	assert.Empty(t, result.Items)
+	mockCrontabHandler.AssertNotCalled(t, "UpdateCrontabEntries")
+}
+
+func Test_ItStoresTheArgsOfANewTask(t *testing.T) {
+	t.Parallel()
+
+	args := map[string]string{ARG_ROOM_ID: "123"}
+
+	ctbE, err := newTaskEntry("* * * * *", "cli start-game 123", WithArgs(args))
+	require.NoError(t, err)
+
+	args[ARG_ROOM_ID] = "changed"
+	assert.Equal(t, "123", ctbE.Args[ARG_ROOM_ID])
+}
+
+func Test_ItBackfillsArgsOfTasksScheduledWithoutThem(t *testing.T) {
+	t.Parallel()
+
+	first, _, _ := roomEntries()
+	legacy := crontab.CrontabEntry{ID: uuid.New(), Cmd: "cli start-game 123"}
+	script := crontab.CrontabEntry{ID: uuid.New(), Cmd: "/usr/local/bin/backup.sh"}
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	written := []crontab.CrontabEntry{first, legacy, script}
+	mockCrontabHandler.On("UpdateCrontabEntries").Return(&written, nil)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return(written, nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	argsFor := func(cmd string) map[string]string {
+		roomID, ok := strings.CutPrefix(cmd, "cli start-game ")
+		if !ok {
+			return nil
+		}
+
+		return map[string]string{ARG_ROOM_ID: roomID}
+	}
+
+	filled, err := tR.BackfillArgs(argsFor)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, filled)
+	assert.Equal(t, map[string]string{ARG_ROOM_ID: "123"}, written[1].Args)
+	assert.Nil(t, written[2].Args)
+
+	filled, err = tR.BackfillArgs(argsFor)
+
+	require.NoError(t, err)
+	assert.Zero(t, filled, "tasks are only backfilled once")
+}
@@ -0,0 +1,277 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/tasklock"
+)
+
+var errRunHistoryNotConfigured = errors.New("run history not configured")
+
+func WithRunHistory(rh *crontab.RunHistory) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.runs = rh
+	}
+}
+
+// Retries failed runs of the task as the policy says. A nil policy leaves
+// each fire with a single attempt.
+func WithRetryPolicy(policy *crontab.RetryPolicy) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if policy == nil {
+			return nil
+		}
+
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+
+		retry := *policy
+		ctbE.Retry = &retry
+
+		return nil
+	}
+}
+
+// Keeps runs of the same task apart as their concurrency policy says
+func WithTaskLocks(locks *tasklock.Locker) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.locks = locks
+	}
+}
+
+// What happens when the task fires while its last run is still going: Allow,
+// Forbid or Replace. Empty allows overlapping runs.
+func WithConcurrencyPolicy(policy string) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if err := crontab.ValidateConcurrencyPolicy(policy); err != nil {
+			return err
+		}
+
+		ctbE.ConcurrencyPolicy = policy
+
+		return nil
+	}
+}
+
+// Runs a task that has fired, whether crond's CLI or the in-process scheduler
+// fired it. Checks the run may go ahead, retries failed attempts as the
+// task's policy allows, records every attempt in the run history and cleans
+// up after the task. Retries stop before the task's next regular fire time.
+// A run that overlaps the last one is skipped or replaces it, as the task's
+// concurrency policy says. Returns the error of the last attempt.
+func (t TaskResource) ExecuteTaskRun(ctx context.Context, id uuid.UUID, source string, scheduledAt time.Time, attempt crontab.AttemptFn) error {
+	logger := slog.With(slog.String("id", id.String()))
+
+	var policy crontab.RetryPolicy
+	var nextFire time.Time
+
+	ctbE, err := t.crontabManager.GetCrontabEntryByID(id)
+	if err == nil {
+		if ctbE.Retry != nil {
+			policy = *ctbE.Retry
+		}
+
+		nextFire, _ = ctbE.Cron.Next(scheduledAt)
+	}
+
+	// Only a cli process runs a single task, so only it may be signalled
+	holder := tasklock.HOLDER_SHARED
+	if source == crontab.RUN_SOURCE_CLI {
+		holder = tasklock.HOLDER_ONE_RUN
+	}
+
+	runCtx, lock, err := t.lockTaskRun(ctx, id, ctbE.ConcurrencyPolicy, holder)
+	switch {
+	case errors.Is(err, tasklock.ErrLocked):
+		logger.Info("skipping task run, the last one is still going")
+
+		record := crontab.NewRun(id, source, scheduledAt)
+		record.Skip("last run still going")
+		t.recordTaskRun(record)
+
+		return nil
+	case err != nil && ctx.Err() != nil:
+		return err
+	case err != nil:
+		logger.Error("unable to lock task run", slog.String("error", err.Error()))
+	}
+
+	if lock != nil {
+		defer lock.Unlock()
+		ctx = runCtx
+	}
+
+	// Kept through retries and clean up, so the sweeper can't archive the
+	// task mid-run
+	defer t.markTaskRunning(id)()
+
+	run, err := t.BeginTaskRun(id)
+	switch {
+	case errors.Is(err, crontab.ErrCrontabEntryNotFound):
+		logger.Info("skipping task run, the task no longer exists")
+
+		record := crontab.NewRun(id, source, scheduledAt)
+		record.Skip("task not found")
+		t.recordTaskRun(record)
+
+		return nil
+	case err != nil:
+		// Better to fire than to drop a run because the store was unavailable
+		logger.Error("unable to begin task run", slog.String("error", err.Error()))
+		run = true
+	}
+
+	if !run {
+		record := crontab.NewRun(id, source, scheduledAt)
+		record.Skip(ctbE.SuppressedReason(time.Now()))
+		t.recordTaskRun(record)
+
+		return nil
+	}
+
+	var attemptErr error
+	for n := 1; ; n++ {
+		record := crontab.NewRun(id, source, scheduledAt)
+		record.Attempt = n
+
+		var output string
+		output, attemptErr = attempt(ctx)
+
+		// Say why the command was cut short rather than how it noticed
+		if cause := context.Cause(ctx); attemptErr != nil && errors.Is(cause, tasklock.ErrReplaced) {
+			attemptErr = cause
+		}
+
+		record.Finish(attemptErr, output)
+		t.recordTaskRun(record)
+
+		if attemptErr == nil || ctx.Err() != nil {
+			break
+		}
+
+		delay, ok := policy.Delay(n)
+		if !ok {
+			break
+		}
+
+		if !nextFire.IsZero() && !time.Now().Add(delay).Before(nextFire) {
+			logger.Warn("not retrying task, its next run is due first",
+				slog.Int("attempt", n),
+				slog.Time("next_run", nextFire),
+			)
+
+			break
+		}
+
+		logger.Info("retrying failed task run",
+			slog.Int("attempt", n),
+			slog.Duration("delay", delay),
+			slog.String("error", attemptErr.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return attemptErr
+		case <-time.After(delay):
+		}
+	}
+
+	// Only a run that went through stops it counting as missed
+	if attemptErr == nil {
+		t.recordFire(id, scheduledAt)
+	}
+
+	if err = t.FinishTaskRun(id, attemptErr); err != nil {
+		logger.Error("unable to finish task run", slog.String("error", err.Error()))
+	}
+
+	return attemptErr
+}
+
+// Takes the task's lock unless its runs may overlap, in which case the lock
+// is nil. The returned context is cancelled if a newer run replaces this one.
+func (t TaskResource) lockTaskRun(ctx context.Context, id uuid.UUID, policy string, holder tasklock.Holder) (context.Context, *tasklock.Lock, error) {
+	if t.locks == nil {
+		return ctx, nil, nil
+	}
+
+	switch policy {
+	case crontab.CONCURRENCY_FORBID:
+		return t.locks.TryLock(ctx, id, holder)
+	case crontab.CONCURRENCY_REPLACE:
+		return t.locks.Replace(ctx, id, holder)
+	}
+
+	return ctx, nil, nil
+}
+
+// Marks a run of the task as in progress until the returned func is called
+func (t TaskResource) markTaskRunning(id uuid.UUID) func() {
+	if t.locks == nil {
+		return func() {}
+	}
+
+	done, err := t.locks.MarkRunning(id)
+	if err != nil {
+		slog.Error("unable to mark task run as in progress",
+			slog.String("id", id.String()),
+			slog.String("error", err.Error()),
+		)
+
+		return func() {}
+	}
+
+	return done
+}
+
+// Whether a run of the task is going or waiting to retry, in any process
+func (t TaskResource) taskRunning(id uuid.UUID) bool {
+	if t.locks == nil {
+		return false
+	}
+
+	running, err := t.locks.Running(id)
+	if err != nil {
+		slog.Error("unable to check for a task run in progress",
+			slog.String("id", id.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return running
+}
+
+func (t TaskResource) recordTaskRun(run crontab.Run) {
+	if err := t.RecordTaskRun(run); err != nil {
+		slog.Error("unable to record task run",
+			slog.String("id", run.TaskID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Adds a run to the history. Runs are normally recorded by ExecuteTaskRun.
+func (t TaskResource) RecordTaskRun(run crontab.Run) error {
+	if t.runs == nil {
+		return nil
+	}
+
+	return t.runs.Record(run)
+}
+
+// Newest first. Runs are kept after the task is removed, so one-shot tasks
+// can still be looked up once they have fired.
+func (t TaskResource) GetTaskRuns(id uuid.UUID) ([]crontab.Run, error) {
+	if t.runs == nil {
+		return nil, errRunHistoryNotConfigured
+	}
+
+	return t.runs.List(id)
+}
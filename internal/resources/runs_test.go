@@ -0,0 +1,285 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/resources/mocks"
+	"github.com/captainmango/coco-cron-parser/internal/tasklock"
+)
+
+func newRunsTaskResource(t *testing.T, ctbE crontab.CrontabEntry) TaskResource {
+	t.Helper()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("GetCrontabEntryByID", ctbE.ID).Return(ctbE, nil)
+	mockCrontabHandler.On("UpdateCrontabEntryByID", ctbE.ID).Return(&ctbE, nil)
+
+	return CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithRunHistory(crontab.NewRunHistory(filepath.Join(t.TempDir(), "runs.json"), 10, 0)),
+		WithTaskLocks(tasklock.NewLocker(t.TempDir(), tasklock.WithPollInterval(time.Millisecond))),
+	)
+}
+
+func everyMinuteTask(t *testing.T) crontab.CrontabEntry {
+	t.Helper()
+
+	var cron parser.Cron
+	require.NoError(t, cron.UnmarshalText([]byte("* * * * *")))
+
+	id, _ := uuid.NewV7()
+
+	return crontab.CrontabEntry{ID: id, Cron: cron, Cmd: "cli start-game 1"}
+}
+
+func Test_ItRetriesFailedRunsAndRecordsEachAttempt(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	ctbE.Retry = &crontab.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	tR := newRunsTaskResource(t, ctbE)
+
+	attempts := 0
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_CLI, time.Now(), func(ctx context.Context) (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "connection refused\n", errors.New("rabbitmq unavailable")
+		}
+
+		return "pushed\n", nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	runs, err := tR.GetTaskRuns(ctbE.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.Equal(t, 3, runs[0].Attempt)
+	assert.Equal(t, crontab.RUN_SUCCEEDED, runs[0].Outcome)
+	assert.Equal(t, "pushed\n", runs[0].Output)
+	assert.Equal(t, 1, runs[2].Attempt)
+	assert.Equal(t, crontab.RUN_FAILED, runs[2].Outcome)
+	assert.Equal(t, "rabbitmq unavailable", runs[2].Error)
+	assert.Equal(t, runs[0].ScheduledAt, runs[2].ScheduledAt)
+}
+
+func Test_ItStopsRetryingBeforeTheNextFire(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	ctbE.Retry = &crontab.RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour}
+	tR := newRunsTaskResource(t, ctbE)
+
+	attempts := 0
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_IN_PROCESS, time.Now().Truncate(time.Minute), func(ctx context.Context) (string, error) {
+		attempts++
+		return "", errors.New("rabbitmq unavailable")
+	})
+
+	assert.EqualError(t, err, "rabbitmq unavailable")
+	assert.Equal(t, 1, attempts)
+}
+
+func Test_ItRecordsSkippedRuns(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	ctbE.MaxRuns, ctbE.Runs = 1, 1
+	tR := newRunsTaskResource(t, ctbE)
+
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_CLI, time.Now(), func(ctx context.Context) (string, error) {
+		t.Fatal("a task out of runs must not run")
+		return "", nil
+	})
+	require.NoError(t, err)
+
+	runs, err := tR.GetTaskRuns(ctbE.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, crontab.RUN_SKIPPED, runs[0].Outcome)
+	assert.Equal(t, "max_runs reached", runs[0].Reason)
+}
+
+func Test_ItSkipsRunsOfTasksThatNoLongerExist(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("GetCrontabEntryByID", id).
+		Return(crontab.CrontabEntry{}, crontab.ErrCrontabEntryNotFound)
+	mockCrontabHandler.On("UpdateCrontabEntryByID", id).
+		Return((*crontab.CrontabEntry)(nil), crontab.ErrCrontabEntryNotFound)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler,
+		WithRunHistory(crontab.NewRunHistory(filepath.Join(t.TempDir(), "runs.json"), 10, 0)),
+	)
+
+	err := tR.ExecuteTaskRun(context.Background(), id, crontab.RUN_SOURCE_CLI, time.Now(), func(ctx context.Context) (string, error) {
+		t.Fatal("a removed task must not run")
+		return "", nil
+	})
+	require.NoError(t, err)
+
+	runs, err := tR.GetTaskRuns(id)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, crontab.RUN_SKIPPED, runs[0].Outcome)
+	assert.Equal(t, "task not found", runs[0].Reason)
+}
+
+// Starts a run of the task that holds on until it is cancelled or let go
+func startLongRun(t *testing.T, tR TaskResource, id uuid.UUID) (release func(), done <-chan error) {
+	t.Helper()
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- tR.ExecuteTaskRun(context.Background(), id, crontab.RUN_SOURCE_IN_PROCESS, time.Now(), func(ctx context.Context) (string, error) {
+			close(started)
+
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-finish:
+				return "", nil
+			}
+		})
+	}()
+
+	<-started
+
+	return func() { close(finish) }, errs
+}
+
+func Test_ItSkipsOverlappingRunsWhenForbidden(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	ctbE.ConcurrencyPolicy = crontab.CONCURRENCY_FORBID
+	tR := newRunsTaskResource(t, ctbE)
+
+	release, done := startLongRun(t, tR, ctbE.ID)
+
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_IN_PROCESS, time.Now(), func(ctx context.Context) (string, error) {
+		t.Fatal("an overlapping run must not start")
+		return "", nil
+	})
+	require.NoError(t, err)
+
+	release()
+	require.NoError(t, <-done)
+
+	runs, err := tR.GetTaskRuns(ctbE.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, crontab.RUN_SUCCEEDED, runs[0].Outcome)
+	assert.Equal(t, crontab.RUN_SKIPPED, runs[1].Outcome)
+	assert.Equal(t, "last run still going", runs[1].Reason)
+}
+
+func Test_ItCancelsTheRunningTaskWhenReplacing(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	ctbE.ConcurrencyPolicy = crontab.CONCURRENCY_REPLACE
+	ctbE.Retry = &crontab.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	tR := newRunsTaskResource(t, ctbE)
+
+	_, done := startLongRun(t, tR, ctbE.ID)
+
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_IN_PROCESS, time.Now(), func(ctx context.Context) (string, error) {
+		return "", nil
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-done, tasklock.ErrReplaced)
+
+	runs, err := tR.GetTaskRuns(ctbE.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 2, "a replaced run is not retried")
+	assert.Equal(t, crontab.RUN_SUCCEEDED, runs[0].Outcome)
+	assert.Equal(t, crontab.RUN_FAILED, runs[1].Outcome)
+	assert.Equal(t, tasklock.ErrReplaced.Error(), runs[1].Error)
+}
+
+func Test_ItLetsRunsOverlapWhenAllowed(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	tR := newRunsTaskResource(t, ctbE)
+
+	release, done := startLongRun(t, tR, ctbE.ID)
+
+	ran := false
+	err := tR.ExecuteTaskRun(context.Background(), ctbE.ID, crontab.RUN_SOURCE_IN_PROCESS, time.Now(), func(ctx context.Context) (string, error) {
+		ran = true
+		return "", nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	release()
+	require.NoError(t, <-done)
+}
+
+func Test_ItDoesNotSweepTasksWithARunInProgress(t *testing.T) {
+	t.Parallel()
+
+	ctbE := everyMinuteTask(t)
+	runAt := time.Now().Add(-10 * time.Minute)
+	ctbE.RunAt = &runAt
+	tR := newRunsTaskResource(t, ctbE)
+
+	mockCrontabHandler := tR.crontabManager.(*mocks.MockCrontabHandler)
+	mockCrontabHandler.On("GetAllCrontabEntries").Return([]crontab.CrontabEntry{ctbE}, nil)
+	mockCrontabHandler.On("RemoveCrontabEntryByID", ctbE.ID).Return(nil)
+
+	release, done := startLongRun(t, tR, ctbE.ID)
+
+	require.NoError(t, tR.SweepExpiredTasks())
+	mockCrontabHandler.AssertNotCalled(t, "UpdateCrontabEntries")
+
+	release()
+	require.NoError(t, <-done)
+	mockCrontabHandler.AssertCalled(t, "RemoveCrontabEntryByID", ctbE.ID)
+}
+
+func Test_ItValidatesConcurrencyPolicies(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTaskEntry("* * * * *", "cli start-game 1", WithConcurrencyPolicy("Queue"))
+	assert.ErrorIs(t, err, crontab.ErrInvalidConcurrencyPolicy)
+
+	ctbE, err := newTaskEntry("* * * * *", "cli start-game 1", WithConcurrencyPolicy(crontab.CONCURRENCY_FORBID))
+	require.NoError(t, err)
+	assert.Equal(t, crontab.CONCURRENCY_FORBID, ctbE.ConcurrencyPolicy)
+}
+
+func Test_ItValidatesRetryPolicies(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTaskEntry("* * * * *", "cli start-game 1",
+		WithRetryPolicy(&crontab.RetryPolicy{MaxAttempts: 3}),
+	)
+	assert.ErrorIs(t, err, crontab.ErrInvalidRetryPolicy)
+
+	ctbE, err := newTaskEntry("* * * * *", "cli start-game 1",
+		WithRetryPolicy(&crontab.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 3, ctbE.Retry.MaxAttempts)
+}
@@ -3,7 +3,11 @@ package resources
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -11,19 +15,60 @@ import (
 	"github.com/captainmango/coco-cron-parser/internal/crontab"
 	"github.com/captainmango/coco-cron-parser/internal/msq"
 	"github.com/captainmango/coco-cron-parser/internal/parser"
+	"github.com/captainmango/coco-cron-parser/internal/store"
+	"github.com/captainmango/coco-cron-parser/internal/tasklock"
 )
 
 type TaskResource struct {
-	crontabManager  crontab.CrontabHandler
-	msgQueueHandler msq.AdvancedMessageQueueHandler
+	crontabManager    crontab.CrontabHandler
+	msgQueueHandler   msq.AdvancedMessageQueueHandler
+	maintenance       *store.JSONFile[MaintenanceState]
+	maintenancePolicy string
+	revisions         *crontab.RevisionHistory
+	author            string
+	idempotency       *idempotencyStore
+	duplicatePolicy   string
+	archive           *taskArchive
+	runs              *crontab.RunHistory
+	locks             *tasklock.Locker
+	fires             *crontab.FireLog
+	now               func() time.Time
 }
 
+type TaskResourceOptFn func(t *TaskResource)
+
 func CreateTaskResource(
 	ctbeManager crontab.CrontabHandler,
 	msgQueueHandler msq.AdvancedMessageQueueHandler,
+	opts ...TaskResourceOptFn,
 ) TaskResource {
 
-	return TaskResource{crontabManager: ctbeManager, msgQueueHandler: msgQueueHandler}
+	t := TaskResource{
+		crontabManager:    ctbeManager,
+		msgQueueHandler:   msgQueueHandler,
+		maintenancePolicy: MAINTENANCE_REJECT,
+		author:            "system",
+		duplicatePolicy:   DUPLICATE_ALLOW,
+		now:               time.Now,
+	}
+
+	for _, fn := range opts {
+		fn(&t)
+	}
+
+	return t
+}
+
+// Persists maintenance mode at the given path and sets what happens to new
+// schedules while it is on
+func WithMaintenance(path, policy string) TaskResourceOptFn {
+	return func(t *TaskResource) {
+		t.maintenance = store.NewJSONFile[MaintenanceState](path)
+
+		if policy != "" {
+			t.maintenancePolicy = policy
+		}
+	}
 }
 
 func (t TaskResource) GetAllCrontabEntries() ([]crontab.CrontabEntry, error) {
@@ -40,22 +85,180 @@ func (t TaskResource) GetAllCrontabEntries() ([]crontab.CrontabEntry, error) {
 	return entries, nil
 }
 
-func (t TaskResource) ScheduleTask(cron, task string) (uuid.UUID, error) {
-	p, err := parser.NewParser(parser.WithInput(cron, true))
+type ScheduleOptFn func(ctbE *crontab.CrontabEntry) error
+
+// Attaches descriptive metadata to the task. Timestamps are always set by
+// the resource and are ignored here.
+func WithMetadata(meta crontab.Metadata) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		ctbE.Meta.Name = meta.Name
+		ctbE.Meta.Description = meta.Description
+		ctbE.Meta.Owner = meta.Owner
+		ctbE.Meta.Tags = meta.Tags
+
+		return nil
+	}
+}
 
+// Sets environment variables for the command, checked so they can be
+// rendered into the crontab safely
+func WithEnv(env []crontab.EnvVar) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if err := crontab.ValidateEnv(env); err != nil {
+			return err
+		}
+
+		if len(env) > 0 {
+			ctbE.Env = slices.Clone(env)
+		}
+
+		return nil
+	}
+}
+
+// Runs the command from dir, which must be an absolute path
+func WithWorkDir(dir string) ScheduleOptFn {
+	return func(ctbE *crontab.CrontabEntry) error {
+		if err := crontab.ValidateWorkDir(dir); err != nil {
+			return err
+		}
+
+		ctbE.WorkDir = dir
+
+		return nil
+	}
+}
+
+func (t TaskResource) ScheduleTask(cron, task string, opts ...ScheduleOptFn) (uuid.UUID, error) {
+	ctbE, err := t.ScheduleTaskEntry(cron, task, opts...)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
+	return ctbE.ID, nil
+}
+
+// Same as ScheduleTask but hands back the entry as it was written
+func (t TaskResource) ScheduleTaskEntry(cron, task string, opts ...ScheduleOptFn) (crontab.CrontabEntry, error) {
+	ctbE, _, err := t.ScheduleTaskOnce("", cron, task, opts...)
+	return ctbE, err
+}
+
+// Schedules the task unless the idempotency key has been seen before, or the
+// duplicate policy hands back an identical task that is already scheduled.
+// created reports whether a new task was written. An empty key skips the
+// idempotency check.
+func (t TaskResource) ScheduleTaskOnce(key, cron, task string, opts ...ScheduleOptFn) (ctbE crontab.CrontabEntry, created bool, err error) {
+	request := idempotencyRecord{Cron: cron, Cmd: task}
+
+	return t.scheduleOnce(key, request, func() (crontab.CrontabEntry, error) {
+		return newTaskEntry(cron, task, opts...)
+	})
+}
+
+// Checks the idempotency key against request before build makes the entry,
+// so a retry is matched on what was asked for rather than on what it
+// resolved to
+func (t TaskResource) scheduleOnce(key string, request idempotencyRecord, build func() (crontab.CrontabEntry, error)) (crontab.CrontabEntry, bool, error) {
+	if key != "" && t.idempotency != nil {
+		t.idempotency.mu.Lock()
+		defer t.idempotency.mu.Unlock()
+
+		prev, seen, err := t.idempotency.lookup(key, request)
+		if err != nil || seen {
+			return t.currentEntry(prev), false, err
+		}
+	}
+
+	ctbEntry, err := build()
+	if err != nil {
+		return crontab.CrontabEntry{}, false, err
+	}
+
+	if t.duplicatePolicy != DUPLICATE_ALLOW {
+		existing, found, err := t.findDuplicate(ctbEntry)
+		if err != nil {
+			return crontab.CrontabEntry{}, false, err
+		}
+
+		if found && t.duplicatePolicy == DUPLICATE_EXISTING {
+			return existing, false, nil
+		}
+
+		if found {
+			return crontab.CrontabEntry{}, false, fmt.Errorf("%w with ID of %s", ErrDuplicateTask, existing.ID)
+		}
+	}
+
+	err = t.underMaintenancePolicy(func(apply func(*crontab.CrontabEntry) error) error {
+		if err := apply(&ctbEntry); err != nil {
+			return err
+		}
+
+		return t.crontabManager.WriteCrontabEntries([]crontab.CrontabEntry{ctbEntry})
+	})
+
+	if err != nil {
+		return crontab.CrontabEntry{}, false, err
+	}
+
+	t.recordRevision(fmt.Sprintf("schedule task %s", ctbEntry.ID))
+
+	if key != "" && t.idempotency != nil {
+		if err = t.idempotency.remember(key, request, ctbEntry); err != nil {
+			slog.Error("unable to remember idempotency key",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	return ctbEntry, true, nil
+}
+
+// The task as it is scheduled now, so a replay reflects edits made since.
+// A task removed since comes back as it was first scheduled.
+func (t TaskResource) currentEntry(ctbE crontab.CrontabEntry) crontab.CrontabEntry {
+	if ctbE.ID == uuid.Nil {
+		return ctbE
+	}
+
+	current, err := t.crontabManager.GetCrontabEntryByID(ctbE.ID)
+	if err != nil {
+		if !errors.Is(err, crontab.ErrCrontabEntryNotFound) {
+			slog.Error("unable to read task for idempotent replay",
+				slog.String("id", ctbE.ID.String()),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		return ctbE
+	}
+
+	return current
+}
+
+// Builds a new entry with a fresh ID without writing it anywhere
+func newTaskEntry(cron, task string, opts ...ScheduleOptFn) (crontab.CrontabEntry, error) {
+	p, err := parser.NewParser(parser.WithInput(cron, true))
+
+	if err != nil {
+		return crontab.CrontabEntry{}, err
+	}
+
 	parsedExpr, err := p.Parse()
 	if err != nil {
-		return uuid.UUID{}, err
+		return crontab.CrontabEntry{}, err
+	}
+
+	if err = parsedExpr.Validate(); err != nil {
+		return crontab.CrontabEntry{}, err
 	}
 
 	id, err := uuid.NewV7()
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return crontab.CrontabEntry{}, err
 	}
 
 	ctbEntry := crontab.CrontabEntry{
@@ -64,11 +267,17 @@ func (t TaskResource) ScheduleTask(cron, task string) (uuid.UUID, error) {
 		Cmd:  task,
 	}
 
-	if err = t.crontabManager.WriteCrontabEntries([]crontab.CrontabEntry{ctbEntry}); err != nil {
-		return uuid.UUID{}, err
+	for _, fn := range opts {
+		if err = fn(&ctbEntry); err != nil {
+			return crontab.CrontabEntry{}, err
+		}
 	}
 
-	return id, nil
+	now := time.Now().UTC()
+	ctbEntry.Meta.CreatedAt = now
+	ctbEntry.Meta.UpdatedAt = now
+
+	return ctbEntry, nil
 }
 
 func (t TaskResource) GetTaskByID(id uuid.UUID) (crontab.CrontabEntry, error) {
@@ -81,9 +290,75 @@ func (t TaskResource) GetTaskByID(id uuid.UUID) (crontab.CrontabEntry, error) {
 	return ctbE, nil
 }
 
+// Removes the task, keeping it in the archive when one is configured
 func (t TaskResource) RemoveTaskByID(id uuid.UUID) error {
-	err := t.crontabManager.RemoveCrontabEntryByID(id)
-	return err
+	var removing []crontab.CrontabEntry
+	if t.archive != nil {
+		ctbE, err := t.crontabManager.GetCrontabEntryByID(id)
+		if err != nil {
+			return err
+		}
+
+		removing = append(removing, ctbE)
+	}
+
+	err := t.archiving(removing, "", func() error {
+		return t.crontabManager.RemoveCrontabEntryByID(id)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.recordRevision(fmt.Sprintf("remove task %s", id))
+
+	return nil
+}
+
+// Copies managed lines from the crontab file into the task store
+func (t TaskResource) MigrateCrontab() (int, error) {
+	importer, ok := t.crontabManager.(crontab.CrontabImporter)
+	if !ok {
+		return 0, errors.New("crontab handler does not support importing")
+	}
+
+	imported, err := importer.ImportCrontabFile()
+	if err != nil {
+		return 0, err
+	}
+
+	if imported > 0 {
+		t.recordRevision("migrate crontab")
+	}
+
+	return imported, nil
+}
+
+func (t TaskResource) PauseTaskByID(id uuid.UUID) error {
+	err := t.overridingMaintenance(id, func() error {
+		return t.crontabManager.PauseCrontabEntryByID(id)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	t.recordRevision(fmt.Sprintf("pause task %s", id))
+
+	return nil
+}
+
+func (t TaskResource) ResumeTaskByID(id uuid.UUID) error {
+	err := t.overridingMaintenance(id, func() error {
+		return t.crontabManager.ResumeCrontabEntryByID(id)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	t.recordRevision(fmt.Sprintf("resume task %s", id))
+
+	return nil
 }
 
 func (t TaskResource) PushStartGameMessage(p msq.StartGamePayload) error {
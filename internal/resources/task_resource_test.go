@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -144,6 +145,38 @@ func Test_ItCanWriteTasksToFile(t *testing.T) {
 	assert.Equal(t, capturedID, taskId)
 }
 
+func Test_ItWritesMetadataWithTasks(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	var captured crontab.CrontabEntry
+	mockCrontabHandler.On("WriteCrontabEntries", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			captured = args.Get(0).([]crontab.CrontabEntry)[0]
+		})
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	ctbE, err := tR.ScheduleTaskEntry("* * * * *", "test-command", WithMetadata(crontab.Metadata{
+		Name:      "Final table",
+		Owner:     "tournaments",
+		Tags:      []string{"finals"},
+		CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}))
+
+	mockCrontabHandler.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, captured, ctbE)
+	assert.Equal(t, "Final table", ctbE.Meta.Name)
+	assert.Equal(t, "tournaments", ctbE.Meta.Owner)
+	assert.Equal(t, []string{"finals"}, ctbE.Meta.Tags)
+	assert.WithinDuration(t, time.Now(), ctbE.Meta.CreatedAt, time.Minute)
+	assert.Equal(t, ctbE.Meta.CreatedAt, ctbE.Meta.UpdatedAt)
+}
+
 func Test_ItHandlesErrorsWhenWriting(t *testing.T) {
 	t.Parallel()
 
@@ -161,6 +194,20 @@ func Test_ItHandlesErrorsWhenWriting(t *testing.T) {
 	assert.Error(t, err, "error writing")
 }
 
+func Test_ItRejectsCronFieldsOutOfBounds(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	_, err := tR.ScheduleTask("61 * * * *", "test-command")
+
+	assert.Error(t, err)
+	mockCrontabHandler.AssertNotCalled(t, "WriteCrontabEntries", mock.Anything)
+}
+
 func Test_ItCanRemoveCrontabFromFile(t *testing.T) {
 	t.Parallel()
 
@@ -198,6 +245,57 @@ func Test_ItHandlesErrorWhenRemovingFromFile(t *testing.T) {
 	assert.Error(t, err, "error removing")
 }
 
+func Test_ItErrorsMigratingWithoutAnImporter(t *testing.T) {
+	t.Parallel()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	_, err := tR.MigrateCrontab()
+
+	assert.Error(t, err)
+}
+
+func Test_ItCanPauseAndResumeTasks(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("PauseCrontabEntryByID", id).
+		Return(nil)
+	mockCrontabHandler.On("ResumeCrontabEntryByID", id).
+		Return(nil)
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	assert.Nil(t, tR.PauseTaskByID(id))
+	assert.Nil(t, tR.ResumeTaskByID(id))
+
+	mockCrontabHandler.AssertExpectations(t)
+}
+
+func Test_ItHandlesErrorWhenPausingTask(t *testing.T) {
+	t.Parallel()
+
+	id, _ := uuid.NewV7()
+
+	mockCrontabHandler := new(mocks.MockCrontabHandler)
+	mockQueueHandler := new(mocks.MockQueueHandler)
+	mockCrontabHandler.On("PauseCrontabEntryByID", id).
+		Return(errors.New("error pausing"))
+
+	tR := CreateTaskResource(mockCrontabHandler, mockQueueHandler)
+
+	err := tR.PauseTaskByID(id)
+
+	mockCrontabHandler.AssertExpectations(t)
+	assert.Error(t, err, "error pausing")
+}
+
 func Test_ItCanPushMessages(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v3"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+)
+
+const (
+	// Tasks are fired by crond, which runs a new cli process each time
+	MODE_CROND = "crond"
+	// Tasks are fired by the API server, which runs the command's action itself
+	MODE_IN_PROCESS = "in-process"
+)
+
+var (
+	ErrNotACommand = errors.New("task is not a registered cli command")
+	// A goroutine can't have an environment or working directory of its own
+	ErrNeedsOwnProcess = errors.New("task sets an environment or working directory, which only runs under crond")
+)
+
+// Lets tests decide what time it is
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type TaskSource interface {
+	GetAllCrontabEntries() ([]crontab.CrontabEntry, error)
+}
+
+type CommandFinder interface {
+	Find(string) (*cli.Command, error)
+}
+
+// Decides whether a task may run, keeps it from overlapping its last run as
+// its concurrency policy says, retries it, records every attempt and
+// cleans up after it, as the CLI does when cron starts it
+type RunTracker interface {
+	ExecuteTaskRun(context.Context, uuid.UUID, string, time.Time, crontab.AttemptFn) error
+}
+
+// Fires scheduled tasks from inside the process instead of leaving it to
+// crond. Every minute the tasks are loaded and the ones whose cron matches
+// have their command's action run.
+type Scheduler struct {
+	tasks    TaskSource
+	commands CommandFinder
+	tracker  RunTracker
+	clock    Clock
+
+	wg sync.WaitGroup
+}
+
+type SchedulerOptFn func(s *Scheduler)
+
+func NewScheduler(tasks TaskSource, commands CommandFinder, opts ...SchedulerOptFn) *Scheduler {
+	s := &Scheduler{
+		tasks:    tasks,
+		commands: commands,
+		clock:    systemClock{},
+	}
+
+	for _, fn := range opts {
+		fn(s)
+	}
+
+	return s
+}
+
+func WithClock(clock Clock) SchedulerOptFn {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+func WithRunTracker(tracker RunTracker) SchedulerOptFn {
+	return func(s *Scheduler) {
+		s.tracker = tracker
+	}
+}
+
+// Blocks until the context is cancelled, then waits for the tasks still
+// running to finish
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.Wait()
+
+	last := s.clock.Now().Truncate(time.Minute)
+	for {
+		next := last.Add(time.Minute)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(next.Sub(s.clock.Now())):
+		}
+
+		now := s.clock.Now().Truncate(time.Minute)
+		if now.Before(next) {
+			continue
+		}
+
+		s.Tick(ctx, now)
+		last = now
+	}
+}
+
+// Starts every task due in the minute now falls in. It does not wait for them
+// to finish.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) {
+	entries, err := s.tasks.GetAllCrontabEntries()
+	if err != nil {
+		slog.Error("unable to load tasks for the scheduler",
+			slog.String("error", err.Error()),
+		)
+
+		return
+	}
+
+	for _, ctbE := range entries {
+		if ctbE.Paused || !ctbE.Cron.Matches(now) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runTask(ctx, ctbE, now, crontab.RUN_SOURCE_IN_PROCESS)
+		}()
+	}
+}
+
+// Runs the missed runs that are to be caught up. Tasks catch up alongside
+// each other, each one's runs oldest first. It does not wait for them to
+// finish.
+func (s *Scheduler) CatchUp(ctx context.Context, missed []crontab.MissedRun) {
+	due := map[uuid.UUID][]crontab.MissedRun{}
+	for _, mr := range missed {
+		if mr.CatchUp {
+			due[mr.Task.ID] = append(due[mr.Task.ID], mr)
+		}
+	}
+
+	for _, runs := range due {
+		slices.SortFunc(runs, func(a, b crontab.MissedRun) int {
+			return a.ScheduledAt.Compare(b.ScheduledAt)
+		})
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			for _, mr := range runs {
+				if ctx.Err() != nil {
+					return
+				}
+
+				s.runTask(ctx, mr.Task, mr.ScheduledAt, crontab.RUN_SOURCE_CATCH_UP)
+			}
+		}()
+	}
+}
+
+// Waits for the tasks that have been started to finish
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) runTask(ctx context.Context, ctbE crontab.CrontabEntry, scheduledAt time.Time, source string) {
+	logger := slog.With(
+		slog.String("task_id", ctbE.ID.String()),
+		slog.String("cmd", ctbE.Cmd),
+		slog.String("source", source),
+	)
+
+	if _, _, err := s.command(ctbE.Cmd); err != nil {
+		logger.Error("unable to run task", slog.String("error", err.Error()))
+		return
+	}
+
+	attempt := func(ctx context.Context) (string, error) {
+		if len(ctbE.Env) > 0 || ctbE.WorkDir != "" {
+			logger.Error("unable to run task", slog.String("error", ErrNeedsOwnProcess.Error()))
+			return "", ErrNeedsOwnProcess
+		}
+
+		logger.Info("running task")
+
+		// Each run gets its own command, as it keeps the arguments it parsed
+		cmd, args, err := s.command(ctbE.Cmd)
+		if err != nil {
+			return "", err
+		}
+
+		output := &crontab.OutputExcerpt{}
+		err = s.execute(ctx, cmd, args, output)
+		if err != nil {
+			logger.Error("task failed", slog.String("error", err.Error()))
+		}
+
+		return output.String(), err
+	}
+
+	if s.tracker == nil {
+		_, _ = attempt(ctx)
+		return
+	}
+
+	_ = s.tracker.ExecuteTaskRun(ctx, ctbE.ID, source, scheduledAt, attempt)
+}
+
+// Finds the registered command a task line such as "cli start-game 123" runs
+func (s *Scheduler) command(line string) (*cli.Command, []string, error) {
+	args := strings.Fields(line)
+	if len(args) < 2 || args[0] != "cli" {
+		return nil, nil, ErrNotACommand
+	}
+
+	cmd, err := s.commands.Find(args[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotACommand, args[1])
+	}
+
+	return cmd, args, nil
+}
+
+// Anything the command writes to its own output, such as usage errors, is
+// copied to output
+func (s *Scheduler) execute(ctx context.Context, cmd *cli.Command, args []string, output io.Writer) error {
+	root := &cli.Command{
+		Name:      "cli",
+		Commands:  []*cli.Command{cmd},
+		Writer:    output,
+		ErrWriter: output,
+		// cli.Exit would otherwise take the whole server down with it
+		ExitErrHandler: func(context.Context, *cli.Command, error) {},
+	}
+
+	return root.Run(ctx, args)
+}
@@ -0,0 +1,319 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/parser"
+)
+
+type taskList []crontab.CrontabEntry
+
+func (tl taskList) GetAllCrontabEntries() ([]crontab.CrontabEntry, error) {
+	return tl, nil
+}
+
+type commandList []*cli.Command
+
+func (cl commandList) Find(name string) (*cli.Command, error) {
+	for _, cmd := range cl {
+		if cmd.Name == name {
+			return cmd, nil
+		}
+	}
+
+	return nil, errors.New("command not found")
+}
+
+// Builds a new command for every run, like the cli's registry
+type commandBuilder func() *cli.Command
+
+func (build commandBuilder) Find(name string) (*cli.Command, error) {
+	cmd := build()
+	if cmd.Name != name {
+		return nil, errors.New("command not found")
+	}
+
+	return cmd, nil
+}
+
+// Records the room of every start-game run
+type recorder struct {
+	mu    sync.Mutex
+	rooms []string
+}
+
+func (r *recorder) command() *cli.Command {
+	return &cli.Command{
+		Name:      "start-game",
+		Arguments: []cli.Argument{&cli.StringArg{Name: "room_id"}},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.rooms = append(r.rooms, c.StringArg("room_id"))
+
+			return nil
+		},
+	}
+}
+
+func (r *recorder) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.rooms...)
+}
+
+// Lets runs through when allowed and keeps the error of each one
+type tracker struct {
+	mu        sync.Mutex
+	allow     bool
+	executed  []uuid.UUID
+	results   map[uuid.UUID][]error
+	sources   []string
+	scheduled []time.Time
+}
+
+func (t *tracker) ExecuteTaskRun(ctx context.Context, id uuid.UUID, source string, scheduledAt time.Time, attempt crontab.AttemptFn) error {
+	t.mu.Lock()
+	t.executed = append(t.executed, id)
+	t.sources = append(t.sources, source)
+	t.scheduled = append(t.scheduled, scheduledAt)
+	allow := t.allow
+	t.mu.Unlock()
+
+	if !allow {
+		return nil
+	}
+
+	_, err := attempt(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.results == nil {
+		t.results = map[uuid.UUID][]error{}
+	}
+	t.results[id] = append(t.results[id], err)
+
+	return err
+}
+
+// Only moves when the test says so
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	waiter chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiter: make(chan time.Time)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.now
+}
+
+func (fc *fakeClock) After(time.Duration) <-chan time.Time {
+	return fc.waiter
+}
+
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.mu.Lock()
+	fc.now = fc.now.Add(d)
+	now := fc.now
+	fc.mu.Unlock()
+
+	fc.waiter <- now
+}
+
+func task(t *testing.T, expr, cmd string) crontab.CrontabEntry {
+	t.Helper()
+
+	var cron parser.Cron
+	require.NoError(t, cron.UnmarshalText([]byte(expr)))
+
+	id, _ := uuid.NewV7()
+
+	return crontab.CrontabEntry{ID: id, Cron: cron, Cmd: cmd}
+}
+
+func Test_ItRunsTheTasksDueThisMinute(t *testing.T) {
+	rec := &recorder{}
+	paused := task(t, "* * * * *", "cli start-game paused")
+	paused.Paused = true
+
+	s := NewScheduler(taskList{
+		task(t, "30 9 * * *", "cli start-game due"),
+		task(t, "0 10 * * *", "cli start-game later"),
+		paused,
+		task(t, "* * * * *", "./not-ours.sh"),
+	}, commandList{rec.command()})
+
+	s.Tick(context.Background(), time.Date(2026, 6, 1, 9, 30, 0, 0, time.Local))
+	s.Wait()
+
+	assert.Equal(t, []string{"due"}, rec.ran())
+}
+
+func Test_ItDoesNotExitOnCommandErrors(t *testing.T) {
+	failing := &cli.Command{
+		Name: "start-game",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			return cli.Exit("room_id argument is required", 1)
+		},
+	}
+
+	failingTask := task(t, "* * * * *", "cli start-game")
+	tr := &tracker{allow: true}
+	s := NewScheduler(taskList{failingTask}, commandList{failing}, WithRunTracker(tr))
+
+	s.Tick(context.Background(), time.Now())
+	s.Wait()
+
+	require.Len(t, tr.results[failingTask.ID], 1)
+	assert.EqualError(t, tr.results[failingTask.ID][0], "room_id argument is required")
+}
+
+func Test_ItRunsTheSameCommandForTwoTasksAtOnce(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(2)
+
+	rec := &recorder{}
+	build := func() *cli.Command {
+		cmd := rec.command()
+		record := cmd.Action
+		cmd.Action = func(ctx context.Context, c *cli.Command) error {
+			// Neither run finishes until both have started
+			started.Done()
+			started.Wait()
+
+			return record(ctx, c)
+		}
+
+		return cmd
+	}
+
+	s := NewScheduler(taskList{
+		task(t, "* * * * *", "cli start-game 1"),
+		task(t, "* * * * *", "cli start-game 2"),
+	}, commandBuilder(build))
+
+	s.Tick(context.Background(), time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runs of the same command did not overlap")
+	}
+
+	assert.ElementsMatch(t, []string{"1", "2"}, rec.ran())
+}
+
+func Test_ItFailsTasksThatNeedTheirOwnProcess(t *testing.T) {
+	rec := &recorder{}
+	withEnv := task(t, "* * * * *", "cli start-game env")
+	withEnv.Env = []crontab.EnvVar{{Name: "REGION", Value: "eu"}}
+	withWorkDir := task(t, "* * * * *", "cli start-game workdir")
+	withWorkDir.WorkDir = "/srv/coco"
+
+	tr := &tracker{allow: true}
+	s := NewScheduler(taskList{withEnv, withWorkDir}, commandList{rec.command()}, WithRunTracker(tr))
+
+	s.Tick(context.Background(), time.Now())
+	s.Wait()
+
+	assert.Empty(t, rec.ran())
+	assert.Equal(t, []error{ErrNeedsOwnProcess}, tr.results[withEnv.ID])
+	assert.Equal(t, []error{ErrNeedsOwnProcess}, tr.results[withWorkDir.ID])
+}
+
+func Test_ItLetsTheTrackerDecideWhetherTasksRun(t *testing.T) {
+	rec := &recorder{}
+	due := task(t, "* * * * *", "cli start-game due")
+
+	tr := &tracker{allow: false}
+	s := NewScheduler(taskList{due}, commandList{rec.command()}, WithRunTracker(tr))
+
+	s.Tick(context.Background(), time.Now())
+	s.Wait()
+
+	assert.Empty(t, rec.ran())
+	assert.Equal(t, []uuid.UUID{due.ID}, tr.executed)
+	assert.Equal(t, []string{crontab.RUN_SOURCE_IN_PROCESS}, tr.sources)
+
+	tr.allow = true
+	s.Tick(context.Background(), time.Now())
+	s.Wait()
+
+	assert.Equal(t, []string{"due"}, rec.ran())
+	assert.Equal(t, []error{nil}, tr.results[due.ID])
+}
+
+func Test_ItCatchesUpMissedRunsOldestFirst(t *testing.T) {
+	rec := &recorder{}
+	due := task(t, "0 * * * *", "cli start-game due")
+	skipped := task(t, "0 * * * *", "cli start-game skipped")
+	at := time.Date(2026, 6, 1, 18, 0, 0, 0, time.Local)
+
+	tr := &tracker{allow: true}
+	s := NewScheduler(taskList{}, commandList{rec.command()}, WithRunTracker(tr))
+
+	s.CatchUp(context.Background(), []crontab.MissedRun{
+		{Task: due, ScheduledAt: at.Add(time.Hour), CatchUp: true},
+		{Task: skipped, ScheduledAt: at},
+		{Task: due, ScheduledAt: at, CatchUp: true},
+	})
+	s.Wait()
+
+	assert.Equal(t, []string{"due", "due"}, rec.ran())
+	assert.Equal(t, []uuid.UUID{due.ID, due.ID}, tr.executed)
+	assert.Equal(t, []string{crontab.RUN_SOURCE_CATCH_UP, crontab.RUN_SOURCE_CATCH_UP}, tr.sources)
+	assert.Equal(t, []time.Time{at, at.Add(time.Hour)}, tr.scheduled)
+}
+
+func Test_ItFiresOnEachMinuteOfTheClock(t *testing.T) {
+	rec := &recorder{}
+	clock := newFakeClock(time.Date(2026, 6, 1, 9, 28, 30, 0, time.Local))
+
+	s := NewScheduler(taskList{task(t, "*/2 * * * *", "cli start-game even")},
+		commandList{rec.command()},
+		WithClock(clock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// 9:29 is odd, 9:30 is even, and waking early changes nothing
+	clock.advance(30 * time.Second)
+	clock.advance(30 * time.Second)
+	clock.advance(10 * time.Second)
+	clock.advance(50 * time.Second)
+	cancel()
+	<-done
+
+	assert.Equal(t, []string{"even"}, rec.ran())
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// For files that may hold secret environment values, such as the crontab and
+// the state files. cron accepts root-owned files with this mode in cron.d.
+const PRIVATE_FILE_MODE os.FileMode = 0600
+
+// Writes data to a temporary file next to path and renames it into place, so
+// readers only ever see the old or the new content. The temporary file is
+// dot-prefixed because cron ignores hidden files in /etc/cron.d.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	defer os.Remove(tmpName) // no-op once the rename has happened
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
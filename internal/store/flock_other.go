@@ -0,0 +1,10 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// Without flock only the in-process lock keeps writers apart
+func lockFile(f *os.File) error {
+	return nil
+}
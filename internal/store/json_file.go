@@ -0,0 +1,129 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A single JSON document on disk. Used for small bits of state that need to
+// survive restarts and be shared between the API and the CLI. Writes hold an
+// flock on a sibling .lock file, so the cli processes cron starts and the API
+// server do not overwrite each other's changes. Reads need no lock, since
+// the document is only ever replaced whole.
+type JSONFile[T any] struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONFile[T any](path string) *JSONFile[T] {
+	return &JSONFile[T]{path: path}
+}
+
+func (jf *JSONFile[T]) Path() string {
+	return jf.path
+}
+
+// Returns the zero value of T when the file does not exist yet
+func (jf *JSONFile[T]) Load() (T, error) {
+	jf.mu.Lock()
+	defer jf.mu.Unlock()
+
+	return jf.load()
+}
+
+func (jf *JSONFile[T]) Save(v T) error {
+	return jf.locked(func() error {
+		return jf.save(v)
+	})
+}
+
+// Loads, mutates and saves the document while holding the lock. Nothing is
+// written if fn returns an error.
+func (jf *JSONFile[T]) Update(fn func(v *T) error) error {
+	return jf.locked(func() error {
+		v, err := jf.load()
+		if err != nil {
+			return err
+		}
+
+		if err = fn(&v); err != nil {
+			return err
+		}
+
+		return jf.save(v)
+	})
+}
+
+// Loads the document and hands it to fn while holding the lock, so nothing
+// changes it until fn returns. fn must not use the file itself.
+func (jf *JSONFile[T]) View(fn func(v T) error) error {
+	return jf.locked(func() error {
+		v, err := jf.load()
+		if err != nil {
+			return err
+		}
+
+		return fn(v)
+	})
+}
+
+// Runs fn holding both the in-process lock and the flock shared with other
+// processes
+func (jf *JSONFile[T]) locked(fn func() error) error {
+	jf.mu.Lock()
+	defer jf.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(jf.path), 0755); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(jf.path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Closing the file releases the flock
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+func (jf *JSONFile[T]) load() (T, error) {
+	var v T
+
+	data, err := os.ReadFile(jf.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+
+	if err != nil {
+		return v, err
+	}
+
+	if len(data) == 0 {
+		return v, nil
+	}
+
+	if err = json.Unmarshal(data, &v); err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+func (jf *JSONFile[T]) save(v T) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(jf.path, append(data, '\n'), PRIVATE_FILE_MODE)
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDoc struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func Test_ItLoadsZeroValueWhenFileMissing(t *testing.T) {
+	t.Parallel()
+
+	jf := NewJSONFile[testDoc](filepath.Join(t.TempDir(), "missing.json"))
+
+	doc, err := jf.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, testDoc{}, doc)
+}
+
+func Test_ItKeepsTheDocumentPrivateToItsOwner(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "doc.json")
+	require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0644))
+
+	jf := NewJSONFile[testDoc](path)
+	require.NoError(t, jf.Save(testDoc{Name: "coco"}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, PRIVATE_FILE_MODE, info.Mode().Perm())
+}
+
+func Test_ItSavesAndLoads(t *testing.T) {
+	t.Parallel()
+
+	jf := NewJSONFile[testDoc](filepath.Join(t.TempDir(), "nested", "doc.json"))
+
+	err := jf.Save(testDoc{Name: "coco", Count: 2})
+	require.NoError(t, err)
+
+	doc, err := jf.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, testDoc{Name: "coco", Count: 2}, doc)
+}
+
+func Test_ItDoesNotWriteWhenUpdateFails(t *testing.T) {
+	t.Parallel()
+
+	jf := NewJSONFile[testDoc](filepath.Join(t.TempDir(), "doc.json"))
+	require.NoError(t, jf.Save(testDoc{Count: 1}))
+
+	err := jf.Update(func(d *testDoc) error {
+		d.Count = 5
+		return errors.New("nope")
+	})
+
+	assert.Error(t, err, "nope")
+
+	doc, _ := jf.Load()
+	assert.Equal(t, 1, doc.Count)
+}
+
+func Test_ItLeavesNoTemporaryFilesBehind(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crontab")
+
+	require.NoError(t, WriteFileAtomic(path, []byte("one\n"), 0644))
+	require.NoError(t, WriteFileAtomic(path, []byte("two\n"), 0644))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "two\n", string(data))
+}
+
+func Test_ItKeepsUpdatesFromSeparateHandlesApart(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "doc.json")
+
+	// Each handle has its own in-process lock, like separate cli processes,
+	// so only the flock keeps them from losing each other's writes
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			jf := NewJSONFile[testDoc](path)
+			assert.NoError(t, jf.Update(func(d *testDoc) error {
+				d.Count++
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+
+	doc, err := NewJSONFile[testDoc](path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, 20, doc.Count)
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package tasklock
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func lockFileShared(f *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func signalProcess(pid int, force bool) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package tasklock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func tryLockFile(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return errWouldBlock
+	}
+
+	return err
+}
+
+// Blocks until the shared lock is free of exclusive holders
+func lockFileShared(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_SH)
+}
+
+func signalProcess(pid int, force bool) error {
+	sig := unix.SIGTERM
+	if force {
+		sig = unix.SIGKILL
+	}
+
+	return unix.Kill(pid, sig)
+}
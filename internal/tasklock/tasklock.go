@@ -0,0 +1,299 @@
+package tasklock
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	lockExt    = ".lock"
+	runningExt = ".running"
+)
+
+var (
+	ErrLocked   = errors.New("task is already running")
+	ErrReplaced = errors.New("replaced by a newer run of the task")
+
+	errWouldBlock = errors.New("lock is held")
+)
+
+// Who holds a task's lock. Only a process started for a single run can be
+// stopped with a signal; one running many tasks, like the API server, is
+// left alone.
+type Holder string
+
+const (
+	HOLDER_ONE_RUN Holder = "one-run"
+	HOLDER_SHARED  Holder = "shared"
+)
+
+// Keeps runs of the same task apart with an flock on a file per task, so it
+// works between the cli processes crond starts as well as inside the API
+// server. The holder writes its pid and Holder into the file so a newer run
+// can tell whether it may stop it.
+type Locker struct {
+	dir          string
+	grace        time.Duration
+	pollInterval time.Duration
+
+	mu sync.Mutex
+	// Cancels the runs that hold a lock in this process
+	held map[uuid.UUID]context.CancelCauseFunc
+}
+
+type LockerOptFn func(l *Locker)
+
+func NewLocker(dir string, opts ...LockerOptFn) *Locker {
+	l := &Locker{
+		dir:          dir,
+		grace:        30 * time.Second,
+		pollInterval: 100 * time.Millisecond,
+		held:         map[uuid.UUID]context.CancelCauseFunc{},
+	}
+
+	for _, fn := range opts {
+		fn(l)
+	}
+
+	return l
+}
+
+// How long a run gets to stop before it is sent SIGKILL, or before Replace
+// gives up on a run it may not kill
+func WithReplaceGrace(d time.Duration) LockerOptFn {
+	return func(l *Locker) {
+		l.grace = d
+	}
+}
+
+// How often Replace checks whether the old run has let go
+func WithPollInterval(d time.Duration) LockerOptFn {
+	return func(l *Locker) {
+		l.pollInterval = d
+	}
+}
+
+// A task's lock, held until Unlock
+type Lock struct {
+	locker *Locker
+	id     uuid.UUID
+	file   *os.File
+	cancel context.CancelCauseFunc
+}
+
+// Takes the task's lock, or fails with ErrLocked while another run holds it.
+// The returned context is cancelled with ErrReplaced if a newer run replaces
+// this one.
+func (l *Locker) TryLock(ctx context.Context, id uuid.UUID, holder Holder) (context.Context, *Lock, error) {
+	f, err := l.open(id)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	if err = tryLockFile(f); err != nil {
+		f.Close()
+
+		if errors.Is(err, errWouldBlock) {
+			return ctx, nil, ErrLocked
+		}
+
+		return ctx, nil, err
+	}
+
+	return l.hold(ctx, id, f, holder)
+}
+
+// Takes the task's lock, stopping the run that holds it first. A run in this
+// process has its context cancelled. A one-run process is sent SIGTERM, then
+// SIGKILL if it still holds the lock after the grace period. Fails with
+// ErrLocked if the holder can't be stopped within the grace period.
+func (l *Locker) Replace(ctx context.Context, id uuid.UUID, holder Holder) (context.Context, *Lock, error) {
+	f, err := l.open(id)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	var stoppedAt time.Time
+	killed := false
+	for {
+		err = tryLockFile(f)
+		if err == nil {
+			return l.hold(ctx, id, f, holder)
+		}
+
+		if !errors.Is(err, errWouldBlock) {
+			f.Close()
+			return ctx, nil, err
+		}
+
+		switch {
+		case stoppedAt.IsZero():
+			if l.stop(id, f, false) {
+				stoppedAt = time.Now()
+			}
+		case !killed && time.Since(stoppedAt) >= l.grace:
+			if !l.stop(id, f, true) {
+				f.Close()
+				return ctx, nil, ErrLocked
+			}
+
+			killed = true
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return ctx, nil, ctx.Err()
+		case <-time.After(l.pollInterval):
+		}
+	}
+}
+
+// Lets the next run of the task go ahead
+func (lk *Lock) Unlock() error {
+	lk.locker.mu.Lock()
+	delete(lk.locker.held, lk.id)
+	lk.locker.mu.Unlock()
+
+	lk.cancel(nil)
+
+	// Cleared so a pid left behind is never mistaken for a later holder's
+	_ = lk.file.Truncate(0)
+
+	// Closing the file releases the flock
+	return lk.file.Close()
+}
+
+// Marks a run of the task as in progress until done is called, whatever its
+// concurrency policy. Runs share the mark, so they never wait on each other,
+// and the mark goes away with the process if it dies mid-run.
+func (l *Locker) MarkRunning(id uuid.UUID) (done func(), err error) {
+	f, err := l.openFile(id, runningExt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = lockFileShared(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Closing the file releases the flock
+	return func() { f.Close() }, nil
+}
+
+// Whether a run of the task is in progress in any process, see MarkRunning
+func (l *Locker) Running(id uuid.UUID) (bool, error) {
+	f, err := l.openFile(id, runningExt)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	err = tryLockFile(f)
+	if errors.Is(err, errWouldBlock) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func (l *Locker) open(id uuid.UUID) (*os.File, error) {
+	return l.openFile(id, lockExt)
+}
+
+func (l *Locker) openFile(id uuid.UUID, ext string) (*os.File, error) {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(filepath.Join(l.dir, id.String()+ext), os.O_RDWR|os.O_CREATE, 0644)
+}
+
+func (l *Locker) hold(ctx context.Context, id uuid.UUID, f *os.File, holder Holder) (context.Context, *Lock, error) {
+	content := []byte(strconv.Itoa(os.Getpid()) + " " + string(holder))
+
+	err := f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt(content, 0)
+	}
+
+	if err != nil {
+		f.Close()
+		return ctx, nil, err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+
+	l.mu.Lock()
+	l.held[id] = cancel
+	l.mu.Unlock()
+
+	return runCtx, &Lock{locker: l, id: id, file: f, cancel: cancel}, nil
+}
+
+// Asks the run holding the task's lock to stop, or kills it when force is
+// set. Reports whether it did, so Replace knows to keep waiting. A holder
+// that may not be signalled is only waited on, up to the grace period.
+func (l *Locker) stop(id uuid.UUID, f *os.File, force bool) bool {
+	l.mu.Lock()
+	cancel, ok := l.held[id]
+	l.mu.Unlock()
+
+	if ok {
+		// A goroutine can't be killed, so cancelling is all there is
+		cancel(ErrReplaced)
+		return !force
+	}
+
+	// The holder may not have written its pid yet, in which case the next
+	// poll tries again
+	raw, err := os.ReadFile(f.Name())
+	if err != nil {
+		return false
+	}
+
+	pidField, holder, _ := strings.Cut(strings.TrimSpace(string(raw)), " ")
+	pid, err := strconv.Atoi(pidField)
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+
+	if Holder(holder) != HOLDER_ONE_RUN {
+		if !force {
+			slog.Info("waiting for running task, its process runs other tasks too",
+				slog.String("id", id.String()),
+				slog.Int("pid", pid),
+			)
+		}
+
+		return !force
+	}
+
+	slog.Info("replacing running task",
+		slog.String("id", id.String()),
+		slog.Int("pid", pid),
+		slog.Bool("kill", force),
+	)
+
+	if err = signalProcess(pid, force); err != nil {
+		slog.Error("unable to stop running task",
+			slog.String("id", id.String()),
+			slog.Int("pid", pid),
+			slog.String("error", err.Error()),
+		)
+
+		return false
+	}
+
+	return true
+}
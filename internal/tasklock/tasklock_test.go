@@ -0,0 +1,216 @@
+//go:build unix
+
+package tasklock
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run by Test_ItStopsRunsInOtherProcesses as the other process. It takes the
+// lock and holds it until it is killed.
+func Test_HelperHoldsTheLock(t *testing.T) {
+	dir := os.Getenv("TASKLOCK_HELPER_DIR")
+	if dir == "" {
+		t.Skip("only run as a helper process")
+	}
+
+	if os.Getenv("TASKLOCK_HELPER_IGNORE_TERM") != "" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+
+	holder := Holder(os.Getenv("TASKLOCK_HELPER_HOLDER"))
+	_, _, err := NewLocker(dir).TryLock(context.Background(), uuid.MustParse(os.Getenv("TASKLOCK_HELPER_ID")), holder)
+	require.NoError(t, err)
+
+	select {}
+}
+
+func Test_ItForbidsASecondHolder(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocker(t.TempDir())
+	id, _ := uuid.NewV7()
+
+	_, lock, err := l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+	require.NoError(t, err)
+
+	_, _, err = l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	other, _ := uuid.NewV7()
+	_, otherLock, err := l.TryLock(context.Background(), other, HOLDER_ONE_RUN)
+	require.NoError(t, err, "other tasks have their own lock")
+	require.NoError(t, otherLock.Unlock())
+
+	require.NoError(t, lock.Unlock())
+
+	_, lock, err = l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock())
+}
+
+func Test_ItReportsRunsInProgress(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocker(t.TempDir())
+	id, _ := uuid.NewV7()
+
+	running, err := l.Running(id)
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	done, err := l.MarkRunning(id)
+	require.NoError(t, err)
+
+	other, err := l.MarkRunning(id)
+	require.NoError(t, err, "overlapping runs share the mark")
+
+	running, err = l.Running(id)
+	require.NoError(t, err)
+	assert.True(t, running)
+
+	done()
+	other()
+
+	running, err = l.Running(id)
+	require.NoError(t, err)
+	assert.False(t, running)
+}
+
+func Test_ItCancelsTheRunItReplaces(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocker(t.TempDir(), WithPollInterval(time.Millisecond))
+	id, _ := uuid.NewV7()
+
+	oldCtx, oldLock, err := l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+	require.NoError(t, err)
+
+	go func() {
+		<-oldCtx.Done()
+		oldLock.Unlock()
+	}()
+
+	_, lock, err := l.Replace(context.Background(), id, HOLDER_ONE_RUN)
+	require.NoError(t, err)
+	defer lock.Unlock()
+
+	assert.ErrorIs(t, context.Cause(oldCtx), ErrReplaced)
+}
+
+func Test_ItGivesUpReplacingWhenTheContextEnds(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocker(t.TempDir(), WithPollInterval(time.Millisecond))
+	id, _ := uuid.NewV7()
+
+	// Never lets go
+	_, lock, err := l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+	require.NoError(t, err)
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err = l.Replace(ctx, id, HOLDER_ONE_RUN)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// Starts a process that takes the task's lock as holder, returning once it
+// has. The returned channel closes when the process exits.
+func startHelper(t *testing.T, dir string, id uuid.UUID, holder Holder, ignoreTerm bool) <-chan struct{} {
+	t.Helper()
+
+	helper := exec.Command(os.Args[0], "-test.run=^Test_HelperHoldsTheLock$")
+	helper.Env = append(os.Environ(),
+		"TASKLOCK_HELPER_DIR="+dir,
+		"TASKLOCK_HELPER_ID="+id.String(),
+		"TASKLOCK_HELPER_HOLDER="+string(holder),
+	)
+	if ignoreTerm {
+		helper.Env = append(helper.Env, "TASKLOCK_HELPER_IGNORE_TERM=1")
+	}
+
+	require.NoError(t, helper.Start())
+	exited := make(chan struct{})
+	go func() {
+		helper.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		helper.Process.Kill()
+		<-exited
+	})
+
+	l := NewLocker(dir)
+	require.Eventually(t, func() bool {
+		_, lock, err := l.TryLock(context.Background(), id, HOLDER_ONE_RUN)
+		if err == nil {
+			lock.Unlock()
+		}
+
+		return err != nil
+	}, 10*time.Second, 10*time.Millisecond, "helper never took the lock")
+
+	return exited
+}
+
+func Test_ItStopsRunsInOtherProcesses(t *testing.T) {
+	for name, ignoreTerm := range map[string]bool{
+		"with SIGTERM":                        false,
+		"with SIGKILL after the grace period": true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			id, _ := uuid.NewV7()
+
+			exited := startHelper(t, dir, id, HOLDER_ONE_RUN, ignoreTerm)
+
+			l := NewLocker(dir, WithPollInterval(5*time.Millisecond), WithReplaceGrace(100*time.Millisecond))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, lock, err := l.Replace(ctx, id, HOLDER_ONE_RUN)
+			require.NoError(t, err)
+			defer lock.Unlock()
+
+			select {
+			case <-exited:
+			case <-time.After(5 * time.Second):
+				t.Fatal("helper was not stopped")
+			}
+		})
+	}
+}
+
+func Test_ItNeverSignalsASharedProcess(t *testing.T) {
+	dir := t.TempDir()
+	id, _ := uuid.NewV7()
+
+	exited := startHelper(t, dir, id, HOLDER_SHARED, false)
+
+	l := NewLocker(dir, WithPollInterval(5*time.Millisecond), WithReplaceGrace(100*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _, err := l.Replace(ctx, id, HOLDER_ONE_RUN)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	select {
+	case <-exited:
+		t.Fatal("shared process was signalled")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
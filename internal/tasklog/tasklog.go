@@ -0,0 +1,331 @@
+package tasklog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const logExt = ".log"
+
+var ErrNoLogs = errors.New("no logs for task")
+
+// Path of the file a task's output is appended to
+func Path(dir string, id uuid.UUID) string {
+	return filepath.Join(dir, id.String()+logExt)
+}
+
+// Reads and rotates the per task logs in a directory. Cron appends to the
+// logs, so rotating only ever renames or deletes files between writes.
+type Logs struct {
+	dir          string
+	maxSize      int64
+	maxFiles     int
+	maxAge       time.Duration
+	pollInterval time.Duration
+}
+
+type LogsOptFn func(l *Logs)
+
+func NewLogs(dir string, opts ...LogsOptFn) *Logs {
+	l := &Logs{
+		dir:          dir,
+		maxSize:      10 << 20,
+		maxFiles:     5,
+		maxAge:       30 * 24 * time.Hour,
+		pollInterval: 250 * time.Millisecond,
+	}
+
+	for _, fn := range opts {
+		fn(l)
+	}
+
+	return l
+}
+
+// Logs bigger than this are rotated, 0 disables it
+func WithMaxSize(bytes int64) LogsOptFn {
+	return func(l *Logs) {
+		l.maxSize = bytes
+	}
+}
+
+// How many rotated files to keep per task
+func WithMaxFiles(n int) LogsOptFn {
+	return func(l *Logs) {
+		l.maxFiles = n
+	}
+}
+
+// Logs not written to for this long are deleted, 0 keeps them forever
+func WithMaxAge(d time.Duration) LogsOptFn {
+	return func(l *Logs) {
+		l.maxAge = d
+	}
+}
+
+// How often Follow checks the log for new output
+func WithPollInterval(d time.Duration) LogsOptFn {
+	return func(l *Logs) {
+		l.pollInterval = d
+	}
+}
+
+func (l *Logs) Dir() string {
+	return l.dir
+}
+
+func (l *Logs) path(id uuid.UUID) string {
+	return Path(l.dir, id)
+}
+
+func rotatedPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// Rotates every log that has grown past the size limit and deletes the ones
+// that have not been written to within the age limit
+func (l *Logs) Rotate() error {
+	entries, err := os.ReadDir(l.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		removed, err := l.expire(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		id, ok := strings.CutSuffix(entry.Name(), logExt)
+		if removed || !ok || uuid.Validate(id) != nil {
+			continue
+		}
+
+		if err = l.rotate(filepath.Join(l.dir, entry.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Deletes the log or rotated log if it is past the age limit
+func (l *Logs) expire(entry fs.DirEntry) (bool, error) {
+	if l.maxAge <= 0 || !strings.Contains(entry.Name(), logExt) {
+		return false, nil
+	}
+
+	info, err := entry.Info()
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if time.Since(info.ModTime()) <= l.maxAge {
+		return false, nil
+	}
+
+	err = os.Remove(filepath.Join(l.dir, entry.Name()))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (l *Logs) rotate(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if l.maxSize <= 0 || info.Size() <= l.maxSize {
+		return nil
+	}
+
+	if l.maxFiles <= 0 {
+		return os.Remove(path)
+	}
+
+	if err = os.Remove(rotatedPath(path, l.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for n := l.maxFiles - 1; n >= 1; n-- {
+		err = os.Rename(rotatedPath(path, n), rotatedPath(path, n+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	slog.Info("rotated task log", slog.String("path", path), slog.Int64("size", info.Size()))
+
+	return os.Rename(path, rotatedPath(path, 1))
+}
+
+// The last n lines the task wrote, oldest first. Rotated files are read too
+// when the current one is shorter than n.
+func (l *Logs) Tail(id uuid.UUID, n int) ([]string, error) {
+	path := l.path(id)
+	files := []string{path}
+	for i := 1; i <= l.maxFiles; i++ {
+		files = append(files, rotatedPath(path, i))
+	}
+
+	var out []string
+	found := false
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		found = true
+		out = append(splitLines(data), out...)
+
+		if n > 0 && len(out) >= n {
+			break
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w with ID of %s", ErrNoLogs, id)
+	}
+
+	if n > 0 && len(out) > n {
+		out = out[len(out)-n:]
+	}
+
+	return out, nil
+}
+
+func splitLines(data []byte) []string {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return nil
+	}
+
+	return strings.Split(string(data), "\n")
+}
+
+// Calls fn with every line the task writes from now on, until ctx is done or
+// fn fails. Keeps following the log across rotations.
+func (l *Logs) Follow(ctx context.Context, id uuid.UUID, fn func(line string) error) error {
+	path := l.path(id)
+
+	var file *os.File
+	var reader *bufio.Reader
+	var partial string
+
+	// Only new output is wanted from the log as it is now. A log created or
+	// rotated in while following is read from the start.
+	fromStart := false
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		fromStart = true
+	}
+
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if file == nil {
+			f, err := os.Open(path)
+			switch {
+			case err == nil:
+				if !fromStart {
+					if _, err = f.Seek(0, io.SeekEnd); err != nil {
+						f.Close()
+						return err
+					}
+				}
+
+				file, reader, fromStart = f, bufio.NewReader(f), true
+			case !errors.Is(err, fs.ErrNotExist):
+				return err
+			}
+		}
+
+		if file != nil {
+			for {
+				chunk, err := reader.ReadString('\n')
+				partial += chunk
+
+				if err != nil {
+					break
+				}
+
+				if err = fn(strings.TrimSuffix(partial, "\n")); err != nil {
+					return err
+				}
+				partial = ""
+			}
+
+			if l.replaced(file) {
+				file.Close()
+				file = nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Whether the open file is no longer the one at its path, i.e. it was rotated
+func (l *Logs) replaced(file *os.File) bool {
+	current, err := os.Stat(file.Name())
+	if err != nil {
+		return true
+	}
+
+	open, err := file.Stat()
+	if err != nil {
+		return true
+	}
+
+	return !os.SameFile(current, open)
+}
@@ -0,0 +1,157 @@
+package tasklog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range lines {
+		_, err = fmt.Fprintln(f, line)
+		require.NoError(t, err)
+	}
+}
+
+func Test_ItRotatesLogsPastTheSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	id, _ := uuid.NewV7()
+	path := Path(dir, id)
+	logs := NewLogs(dir, WithMaxSize(10), WithMaxFiles(2))
+
+	for _, run := range []string{"run one", "run two", "run three"} {
+		appendLines(t, path, run+" output")
+		require.NoError(t, logs.Rotate())
+	}
+
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, path+".1")
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3")
+
+	appendLines(t, path, "run four")
+	require.NoError(t, logs.Rotate(), "small logs are left alone")
+	assert.FileExists(t, path)
+
+	lines, err := logs.Tail(id, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run two output", "run three output", "run four"}, lines)
+}
+
+func Test_ItDeletesLogsPastTheAgeLimit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	staleID, _ := uuid.NewV7()
+	freshID, _ := uuid.NewV7()
+	logs := NewLogs(dir, WithMaxAge(time.Hour))
+
+	appendLines(t, Path(dir, staleID), "old")
+	appendLines(t, Path(dir, staleID)+".1", "older")
+	appendLines(t, Path(dir, freshID), "new")
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(Path(dir, staleID), old, old))
+	require.NoError(t, os.Chtimes(Path(dir, staleID)+".1", old, old))
+
+	require.NoError(t, logs.Rotate())
+
+	assert.NoFileExists(t, Path(dir, staleID))
+	assert.NoFileExists(t, Path(dir, staleID)+".1")
+	assert.FileExists(t, Path(dir, freshID))
+}
+
+func Test_ItTailsTheLastLines(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	id, _ := uuid.NewV7()
+	logs := NewLogs(dir)
+
+	_, err := logs.Tail(id, 10)
+	assert.ErrorIs(t, err, ErrNoLogs)
+
+	appendLines(t, Path(dir, id)+".1", "one", "two")
+	appendLines(t, Path(dir, id), "three", "four")
+
+	lines, err := logs.Tail(id, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"two", "three", "four"}, lines)
+
+	lines, err = logs.Tail(id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"four"}, lines)
+}
+
+func Test_ItFollowsNewOutputAcrossRotations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	id, _ := uuid.NewV7()
+	path := Path(dir, id)
+	logs := NewLogs(dir, WithMaxSize(1), WithPollInterval(10*time.Millisecond))
+
+	appendLines(t, path, "before following")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- logs.Follow(ctx, id, func(line string) error {
+			got <- line
+			return nil
+		})
+	}()
+
+	next := func() string {
+		select {
+		case line := <-got:
+			return line
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for log output")
+			return ""
+		}
+	}
+
+	// Give Follow a moment to open the log and skip what is already there
+	time.Sleep(50 * time.Millisecond)
+
+	appendLines(t, path, "first")
+	assert.Equal(t, "first", next())
+
+	require.NoError(t, logs.Rotate())
+	appendLines(t, path, "after rotation")
+	assert.Equal(t, "after rotation", next())
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func Test_ItIgnoresFilesThatAreNotTaskLogs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	other := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(other, []byte(strings.Repeat("x", 100)), 0644))
+
+	require.NoError(t, NewLogs(dir, WithMaxSize(10)).Rotate())
+	assert.FileExists(t, other)
+}
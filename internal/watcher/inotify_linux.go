@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"context"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// How long a single wait on the inotify descriptor lasts before the context
+// is checked again
+const inotifyPollMillis = 500
+
+// Watches the directory rather than the file, the crontab is replaced by a
+// rename on every write so a watch on the file itself would go stale
+func (w *Watcher) startInotify(ctx context.Context, notify func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+
+	mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY)
+	if _, err = unix.InotifyAddWatch(fd, filepath.Dir(w.path), mask); err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	go func() {
+		defer unix.Close(fd)
+
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.PathMax))
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+		for ctx.Err() == nil {
+			n, err := unix.Poll(fds, inotifyPollMillis)
+			if err != nil || n == 0 {
+				continue
+			}
+
+			read, err := unix.Read(fd, buf)
+			if err != nil || read <= 0 {
+				continue
+			}
+
+			if touchesFile(buf[:read], w.watches) {
+				notify()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func touchesFile(buf []byte, watches func(name string) bool) bool {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		if nameEnd > len(buf) {
+			return false
+		}
+
+		if watches(cString(buf[nameStart:nameEnd])) {
+			return true
+		}
+
+		offset = nameEnd
+	}
+
+	return false
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watcher
+
+import "context"
+
+func (w *Watcher) startInotify(ctx context.Context, notify func()) error {
+	return errInotifyUnsupported
+}
@@ -0,0 +1,285 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/events"
+)
+
+const eventSource = "crontab watcher"
+
+var (
+	errInotifyUnsupported = errors.New("inotify is not supported on this platform")
+	errPollingRequested   = errors.New("polling was requested")
+)
+
+type CrontabReader interface {
+	ReadCrontabLines() ([]string, error)
+}
+
+// Watches the crontab file for changes made outside the service and
+// publishes an event for every entry that was created, removed or modified
+type Watcher struct {
+	path         string
+	reader       CrontabReader
+	bus          *events.Bus
+	debounce     time.Duration
+	pollInterval time.Duration
+	forcePoll    bool
+	// Other files next to the crontab to watch, e.g. its shards
+	pattern string
+	last    map[uuid.UUID]crontab.CrontabEntry
+}
+
+type WatcherOptFn func(w *Watcher)
+
+func NewWatcher(path string, reader CrontabReader, bus *events.Bus, opts ...WatcherOptFn) *Watcher {
+	w := &Watcher{
+		path:         path,
+		reader:       reader,
+		bus:          bus,
+		debounce:     500 * time.Millisecond,
+		pollInterval: 5 * time.Second,
+	}
+
+	for _, fn := range opts {
+		fn(w)
+	}
+
+	return w
+}
+
+// Waits this long after the last change before re-reading, so a burst of
+// writes produces a single set of events
+func WithDebounce(d time.Duration) WatcherOptFn {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.debounce = d
+		}
+	}
+}
+
+func WithPollInterval(d time.Duration) WatcherOptFn {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.pollInterval = d
+		}
+	}
+}
+
+// Skips inotify and always polls
+func WithPolling() WatcherOptFn {
+	return func(w *Watcher) {
+		w.forcePoll = true
+	}
+}
+
+// Also watches the files next to the crontab whose names match the glob
+// pattern, e.g. "coco-*" for a sharded crontab
+func WithPattern(pattern string) WatcherOptFn {
+	return func(w *Watcher) {
+		w.pattern = pattern
+	}
+}
+
+// Whether a change to the named file in the crontab's directory matters
+func (w *Watcher) watches(name string) bool {
+	if name == filepath.Base(w.path) {
+		return true
+	}
+
+	if w.pattern == "" {
+		return false
+	}
+
+	matched, _ := filepath.Match(w.pattern, name)
+
+	return matched
+}
+
+// Blocks until the context is cancelled. Inotify is used where available,
+// otherwise the file is polled.
+func (w *Watcher) Run(ctx context.Context) {
+	w.last = w.snapshot()
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	var err error
+	if w.forcePoll {
+		err = errPollingRequested
+	} else {
+		err = w.startInotify(ctx, notify)
+	}
+
+	if err != nil {
+		slog.Info("watching crontab by polling",
+			slog.String("path", w.path),
+			slog.String("reason", err.Error()),
+		)
+
+		go w.poll(ctx, notify)
+	}
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			settle = time.After(w.debounce)
+		case <-settle:
+			settle = nil
+			w.refresh()
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context, notify func()) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	prev := w.stat()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if cur := w.stat(); cur != prev {
+				prev = cur
+				notify()
+			}
+		}
+	}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	files   int
+}
+
+// Folds the watched files into one stamp. Any file being added, removed or
+// rewritten changes it.
+func (w *Watcher) stat() fileStamp {
+	paths := []string{w.path}
+	if w.pattern != "" {
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(w.path), w.pattern))
+		paths = append(paths, matches...)
+	}
+
+	var stamp fileStamp
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().After(stamp.modTime) {
+			stamp.modTime = info.ModTime()
+		}
+
+		stamp.size += info.Size()
+		stamp.files++
+	}
+
+	return stamp
+}
+
+// Reads the managed entries in the crontab. Lines that are not managed
+// entries, such as ones added by hand, are skipped so they don't hide
+// changes to the rest.
+func (w *Watcher) snapshot() map[uuid.UUID]crontab.CrontabEntry {
+	lines, err := w.reader.ReadCrontabLines()
+	if err != nil {
+		slog.Error("unable to read crontab for watcher",
+			slog.String("path", w.path),
+			slog.String("error", err.Error()),
+		)
+
+		return nil
+	}
+
+	out := make(map[uuid.UUID]crontab.CrontabEntry, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		ctbE, err := crontab.NewCrontabEntryFromString(line)
+		if err != nil {
+			slog.Debug("skipping unmanaged crontab line",
+				slog.String("path", w.path),
+				slog.String("error", err.Error()),
+			)
+
+			continue
+		}
+
+		out[ctbE.ID] = ctbE
+	}
+
+	return out
+}
+
+// Re-reads the crontab and publishes the differences from the last read.
+// A file that cannot be read is skipped until it is readable again. When
+// the reader signs its lines, tampered ones are quarantined first.
+func (w *Watcher) refresh() {
+	if checker, ok := w.reader.(crontab.SignatureChecker); ok {
+		if _, err := checker.CheckSignatures(); err != nil {
+			slog.Error("unable to check crontab signatures",
+				slog.String("path", w.path),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	current := w.snapshot()
+	if current == nil {
+		return
+	}
+
+	for _, e := range changeEvents(w.last, current) {
+		w.bus.Publish(e)
+	}
+
+	w.last = current
+}
+
+func changeEvents(prev, cur map[uuid.UUID]crontab.CrontabEntry) []events.Event {
+	var out []events.Event
+
+	for id, ctbE := range cur {
+		old, ok := prev[id]
+		switch {
+		case !ok:
+			out = append(out, events.Event{Type: events.TASK_CREATED, TaskID: id, Source: eventSource, Entry: ctbE})
+		case old.String() != ctbE.String():
+			out = append(out, events.Event{Type: events.TASK_MODIFIED, TaskID: id, Source: eventSource, Entry: ctbE, Previous: old})
+		}
+	}
+
+	for id, old := range prev {
+		if _, ok := cur[id]; !ok {
+			out = append(out, events.Event{Type: events.TASK_REMOVED, TaskID: id, Source: eventSource, Previous: old})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,213 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/captainmango/coco-cron-parser/internal/crontab"
+	"github.com/captainmango/coco-cron-parser/internal/events"
+)
+
+type fileReader struct {
+	path string
+}
+
+func (fr fileReader) ReadCrontabLines() ([]string, error) {
+	raw, err := os.ReadFile(fr.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(raw), "\n"), nil
+}
+
+// Counts the signature checks the watcher asks for
+type checkingReader struct {
+	fileReader
+	checks atomic.Int32
+}
+
+func (cr *checkingReader) CheckSignatures() ([]crontab.TamperedLine, error) {
+	cr.checks.Add(1)
+	return nil, nil
+}
+
+func line(cron string, id uuid.UUID) string {
+	return fmt.Sprintf("%s root /app/cli start-game 1 2>&1 | tee -a /tmp/log # %s\n", cron, id)
+}
+
+func Test_ItPublishesCrontabChanges(t *testing.T) {
+	for name, opts := range map[string][]WatcherOptFn{
+		"inotify": nil,
+		"polling": {WithPolling()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "crontab")
+			keptID, _ := uuid.NewV7()
+			removedID, _ := uuid.NewV7()
+			createdID, _ := uuid.NewV7()
+
+			require.NoError(t, os.WriteFile(path, []byte(line("* * * * *", keptID)+line("* * * * *", removedID)), 0644))
+
+			bus := events.NewBus()
+			evts, unsubscribe := bus.Subscribe()
+			defer unsubscribe()
+
+			opts = append(opts, WithDebounce(20*time.Millisecond), WithPollInterval(20*time.Millisecond))
+			w := NewWatcher(path, fileReader{path}, bus, opts...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go w.Run(ctx)
+
+			// Give the watcher time to take its first snapshot
+			time.Sleep(100 * time.Millisecond)
+			require.NoError(t, os.WriteFile(path, []byte(line("*/5 * * * *", keptID)+line("* * * * *", createdID)), 0644))
+
+			got := map[events.EventType]uuid.UUID{}
+			timeout := time.After(2 * time.Second)
+			for len(got) < 3 {
+				select {
+				case e := <-evts:
+					got[e.Type] = e.TaskID
+				case <-timeout:
+					t.Fatalf("timed out waiting for events, got %v", got)
+				}
+			}
+
+			assert.Equal(t, createdID, got[events.TASK_CREATED])
+			assert.Equal(t, removedID, got[events.TASK_REMOVED])
+			assert.Equal(t, keptID, got[events.TASK_MODIFIED])
+		})
+	}
+}
+
+func Test_ItPublishesChangesToMatchingFiles(t *testing.T) {
+	for name, opts := range map[string][]WatcherOptFn{
+		"inotify": nil,
+		"polling": {WithPolling()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			keptID, _ := uuid.NewV7()
+			createdID, _ := uuid.NewV7()
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "coco-a"), []byte(line("* * * * *", keptID)), 0644))
+
+			bus := events.NewBus()
+			evts, unsubscribe := bus.Subscribe()
+			defer unsubscribe()
+
+			opts = append(opts, WithPattern("coco-*"), WithDebounce(20*time.Millisecond), WithPollInterval(20*time.Millisecond))
+			w := NewWatcher(filepath.Join(dir, "root"), shardReader{dir}, bus, opts...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go w.Run(ctx)
+
+			time.Sleep(100 * time.Millisecond)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "coco-b"), []byte(line("* * * * *", createdID)), 0644))
+
+			select {
+			case e := <-evts:
+				assert.Equal(t, events.TASK_CREATED, e.Type)
+				assert.Equal(t, createdID, e.TaskID)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for an event")
+			}
+		})
+	}
+}
+
+type shardReader struct {
+	dir string
+}
+
+func (sr shardReader) ReadCrontabLines() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(sr.dir, "coco-*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, path := range paths {
+		lines, err := fileReader{path}.ReadCrontabLines()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, lines...)
+	}
+
+	return out, nil
+}
+
+func Test_ItChecksSignaturesWhenTheCrontabChanges(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "crontab")
+	id, _ := uuid.NewV7()
+	require.NoError(t, os.WriteFile(path, []byte(line("* * * * *", id)), 0644))
+
+	reader := &checkingReader{fileReader: fileReader{path}}
+	w := NewWatcher(path, reader, events.NewBus(), WithPolling(), WithDebounce(20*time.Millisecond), WithPollInterval(20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// Give the watcher time to take its first snapshot
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, reader.checks.Load())
+
+	require.NoError(t, os.WriteFile(path, []byte(line("*/5 * * * *", id)), 0644))
+
+	assert.Eventually(t, func() bool {
+		return reader.checks.Load() > 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func Test_ItSkipsLinesItDoesNotManage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "crontab")
+	keptID, _ := uuid.NewV7()
+	createdID, _ := uuid.NewV7()
+	handWritten := "0 3 * * * root /usr/local/bin/backup.sh\n"
+	require.NoError(t, os.WriteFile(path, []byte(handWritten+line("* * * * *", keptID)), 0644))
+
+	bus := events.NewBus()
+	evts, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	w := NewWatcher(path, fileReader{path}, bus, WithPolling(), WithDebounce(20*time.Millisecond), WithPollInterval(20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte(handWritten+line("* * * * *", keptID)+line("* * * * *", createdID)), 0644))
+
+	select {
+	case e := <-evts:
+		assert.Equal(t, events.TASK_CREATED, e.Type)
+		assert.Equal(t, createdID, e.TaskID)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+}